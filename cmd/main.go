@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
 	"fiber-gorm/internal/config"
 	"fiber-gorm/internal/database"
 	"fiber-gorm/internal/handlers"
+	"fiber-gorm/internal/keys"
 	"fiber-gorm/internal/logger"
+	"fiber-gorm/internal/mailer"
 	"fiber-gorm/internal/middleware"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/notifier"
+	"fiber-gorm/internal/passhash"
 	"fiber-gorm/internal/repository"
 	"fiber-gorm/internal/services"
 	"fmt"
@@ -35,22 +41,113 @@ func main() {
 	log.Info().Msg("Starting application...")
 
 	// Connect to database
-	db, err := database.Connect()
+	db, err := database.Connect(cfg)
 	if err != nil {
 		logger.Fatal(err, "Failed to connect to database")
 	}
 	log.Info().Msg("Database connected successfully")
 
+	// Setup token signing keys
+	keyManager, err := keys.NewManager(cfg)
+	if err != nil {
+		logger.Fatal(err, "Failed to load signing keys")
+	}
+
 	// Setup repositories
 	userRepo := repository.NewUserRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
+	oneTimeTokenRepo := repository.NewOneTimeTokenRepository(db)
+	recoveryCodeRepo := repository.NewRecoveryCodeRepository(db)
+	auditLogRepo := repository.NewAuditLogRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	oauthRepo := repository.NewOAuthRepository(db)
+	passwordHistoryRepo := repository.NewPasswordHistoryRepository(db)
+	taskRepo := repository.NewTaskRepository(db)
+	denylist, err := repository.NewTokenDenylist(cfg.TokenDenylistStore, db)
+	if err != nil {
+		logger.Fatal(err, "Failed to setup token denylist")
+	}
+	loginAttempts, err := repository.NewLoginAttemptStore(cfg.LoginAttemptStore, db)
+	if err != nil {
+		logger.Fatal(err, "Failed to setup login attempt store")
+	}
+	serviceNonces, err := repository.NewNonceStore(cfg.ServiceNonceStore, db)
+	if err != nil {
+		logger.Fatal(err, "Failed to setup service nonce store")
+	}
+
+	// Setup mailer
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		logger.Fatal(err, "Failed to setup mailer")
+	}
+
+	// Setup password hashing and policy
+	hasher, err := passhash.New(cfg)
+	if err != nil {
+		logger.Fatal(err, "Failed to setup password hashing")
+	}
+	passwordPolicy, err := services.NewPasswordPolicy(cfg, passwordHistoryRepo, hasher)
+	if err != nil {
+		logger.Fatal(err, "Failed to setup password policy")
+	}
 
 	// Setup services
-	userService := services.NewUserService(userRepo)
-	authService := services.NewAuthService(cfg, userRepo)
+	tokenVerifier := services.NewTokenVerifier(keyManager, cfg.JWTIssuer, denylist)
+	userService := services.NewUserService(userRepo, roleRepo)
+	auditService := services.NewAuditService(auditLogRepo)
+	loginThrottle := services.NewLoginThrottle(cfg, loginAttempts, auditService)
+	verificationService := services.NewEmailVerificationService(cfg, tokenVerifier, userRepo, oneTimeTokenRepo, mail)
+	authService := services.NewAuthService(cfg, tokenVerifier, userRepo, refreshTokenRepo, sessionRepo, roleRepo, hasher, passwordPolicy, verificationService, loginThrottle)
+	passwordService := services.NewPasswordService(cfg, authService, userRepo, refreshTokenRepo, oneTimeTokenRepo, mail)
+	magicLinkService := services.NewMagicLinkService(cfg, authService, userRepo, oneTimeTokenRepo, mail)
+	mfaService := services.NewMFAService(cfg, authService, userRepo, recoveryCodeRepo)
+	apiKeyService := services.NewAPIKeyService(cfg, apiKeyRepo, userRepo)
+	sessionService := services.NewSessionService(tokenVerifier, sessionRepo, refreshTokenRepo)
+	oidcService := services.NewOIDCService(cfg, authService, identityRepo)
+	oauthService := services.NewOAuthService(authService, oauthRepo)
+	serviceAuth := services.NewServiceAuthenticator(cfg, serviceNonces)
+	taskWorkflow, err := services.NewTaskWorkflow(cfg)
+	if err != nil {
+		logger.Fatal(err, "Failed to setup task workflow")
+	}
+	taskService := services.NewTaskService(taskRepo, taskWorkflow)
+	reminderNotifier, err := notifier.New(cfg, mail)
+	if err != nil {
+		logger.Fatal(err, "Failed to setup task reminder notifier")
+	}
+	reminderScheduler := services.NewTaskReminderScheduler(cfg, taskRepo, userRepo, reminderNotifier)
+
+	// Seed the initial administrator
+	if cfg.AdminEmail != "" {
+		if _, err := authService.EnsureAdmin(cfg.AdminName, cfg.AdminEmail, cfg.AdminPassword); err != nil {
+			logger.Fatal(err, "Failed to seed admin user")
+		}
+		log.Info().Str("email", cfg.AdminEmail).Msg("Admin user ensured")
+	}
+
+	// Send task reminders in the background
+	if cfg.TaskRemindersEnabled {
+		go reminderScheduler.Run(context.Background())
+	}
 
 	// Setup handlers
-	userHandler := handlers.NewUserHandler(userService)
+	userHandler := handlers.NewUserHandler(userService, passwordPolicy.Rules)
 	authHandler := handlers.NewAuthHandler(authService)
+	adminHandler := handlers.NewAdminHandler(userService, authService, auditService)
+	keysHandler := handlers.NewKeysHandler(keyManager)
+	verificationHandler := handlers.NewVerificationHandler(verificationService)
+	passwordHandler := handlers.NewPasswordHandler(passwordService)
+	magicLinkHandler := handlers.NewMagicLinkHandler(magicLinkService)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
+	oidcHandler := handlers.NewOIDCHandler(oidcService)
+	oauthHandler := handlers.NewOAuthHandler(oauthService)
+	taskHandler := handlers.NewTaskHandler(taskService)
 
 	// Create Fiber app with custom error handler
 	app := fiber.New(fiber.Config{
@@ -75,6 +172,9 @@ func main() {
 		},
 	}))
 
+	// Publish token verification keys
+	app.Get("/.well-known/jwks.json", keysHandler.JWKS)
+
 	// Setup API routes
 	api := app.Group("/api")
 
@@ -85,12 +185,82 @@ func main() {
 	auth := api.Group("/auth")
 	auth.Post("/register", authHandler.Register)
 	auth.Post("/login", authHandler.Login)
+	auth.Post("/login/mfa", mfaHandler.VerifyLogin)
 	auth.Post("/refresh-token", authHandler.RefreshToken)
-	auth.Post("/logout", authHandler.Logout)
+	auth.Post("/verify-email", verificationHandler.VerifyEmail)
+	auth.Post("/resend-verification", verificationHandler.ResendVerification)
+	auth.Post("/forgot-password", passwordHandler.ForgotPassword)
+	auth.Post("/reset-password", passwordHandler.ResetPassword)
+	auth.Post("/magic-link", magicLinkHandler.RequestLink)
+	auth.Post("/magic-link/consume", magicLinkHandler.ConsumeLink)
+	auth.Post("/logout", middleware.JWTAuthMiddleware(tokenVerifier, nil), authHandler.Logout)
+	auth.Get("/oidc", oidcHandler.Providers)
+	auth.Get("/oidc/:provider/login", oidcHandler.Login)
+	auth.Get("/oidc/:provider/callback", oidcHandler.Callback)
+	auth.Get("/service", middleware.ServiceAuthMiddleware(serviceAuth, nil), authHandler.ServiceIdentity)
 
-	// Profile routes
-	profile := api.Group("/profile", middleware.JWTAuthMiddleware(&cfg))
+	// OAuth authorization server routes for third-party apps. The consent step
+	// needs the user's own login; the other endpoints authenticate the client.
+	oauth := api.Group("/oauth")
+	oauth.Get("/authorize", middleware.JWTAuthMiddleware(tokenVerifier, nil), middleware.DenyAPIKeys(), oauthHandler.Authorize)
+	oauth.Post("/authorize", middleware.JWTAuthMiddleware(tokenVerifier, nil), middleware.DenyAPIKeys(), oauthHandler.Consent)
+	oauth.Post("/token", oauthHandler.Token)
+	oauth.Post("/introspect", oauthHandler.Introspect)
+	oauth.Post("/revoke", oauthHandler.Revoke)
+
+	// Profile routes, account management requires an interactive login rather than an API key
+	profile := api.Group("/profile", middleware.JWTAuthMiddleware(tokenVerifier, apiKeyService))
+	interactive := middleware.DenyAPIKeys()
 	profile.Get("/", authHandler.Me)
+	profile.Delete("/impersonation", authHandler.StopImpersonation)
+	profile.Put("/password", interactive, passwordHandler.ChangePassword)
+	profile.Post("/mfa/totp", interactive, mfaHandler.EnrollTOTP)
+	profile.Post("/mfa/totp/confirm", interactive, mfaHandler.ConfirmTOTP)
+	profile.Delete("/mfa/totp", interactive, mfaHandler.DisableTOTP)
+	profile.Post("/mfa/recovery-codes", interactive, mfaHandler.RegenerateRecoveryCodes)
+	profile.Get("/tokens", interactive, apiKeyHandler.ListAPIKeys)
+	profile.Post("/tokens", interactive, apiKeyHandler.CreateAPIKey)
+	profile.Delete("/tokens/:id", interactive, apiKeyHandler.RevokeAPIKey)
+	profile.Get("/sessions", interactive, sessionHandler.ListSessions)
+	profile.Delete("/sessions", interactive, sessionHandler.RevokeOtherSessions)
+	profile.Delete("/sessions/:sessionId", interactive, sessionHandler.RevokeSession)
+	profile.Get("/identities", interactive, oidcHandler.ListIdentities)
+	profile.Post("/identities/:provider", interactive, oidcHandler.LinkIdentity)
+	profile.Delete("/identities/:id", interactive, oidcHandler.UnlinkIdentity)
+
+	// Task routes, every user only sees their own tasks
+	tasks := api.Group("/tasks", middleware.JWTAuthMiddleware(tokenVerifier, apiKeyService), middleware.RequireUser())
+	tasks.Get("/", middleware.RequirePermission(models.PermissionTasksRead), taskHandler.ListTasks)
+	tasks.Post("/", middleware.RequirePermission(models.PermissionTasksWrite), taskHandler.CreateTask)
+	tasks.Get("/plan", middleware.RequirePermission(models.PermissionTasksRead), taskHandler.PlanTasks)
+	tasks.Get("/:id", middleware.RequirePermission(models.PermissionTasksRead), taskHandler.GetTask)
+	tasks.Put("/:id", middleware.RequirePermission(models.PermissionTasksWrite), taskHandler.UpdateTask)
+	tasks.Delete("/:id", middleware.RequirePermission(models.PermissionTasksWrite), taskHandler.DeleteTask)
+	tasks.Get("/:id/transitions", middleware.RequirePermission(models.PermissionTasksRead), taskHandler.ListTransitions)
+	tasks.Post("/:id/transitions", middleware.RequirePermission(models.PermissionTasksWrite), taskHandler.TransitionTask)
+	tasks.Get("/:id/subtasks", middleware.RequirePermission(models.PermissionTasksRead), taskHandler.ListSubtasks)
+	tasks.Get("/:id/dependencies", middleware.RequirePermission(models.PermissionTasksRead), taskHandler.ListDependencies)
+	tasks.Post("/:id/dependencies", middleware.RequirePermission(models.PermissionTasksWrite), taskHandler.AddDependency)
+	tasks.Delete("/:id/dependencies/:blockerId", middleware.RequirePermission(models.PermissionTasksWrite), taskHandler.RemoveDependency)
+
+	// Admin routes, admins must have completed two-factor authentication.
+	// Signed internal services can use them with their configured
+	// permissions, except for the routes that act as an admin.
+	admin := api.Group("/admin",
+		middleware.ServiceAuthMiddleware(serviceAuth, middleware.JWTAuthMiddleware(tokenVerifier, nil)),
+		middleware.ForUsers(middleware.RequireRole(models.RoleAdmin)),
+		middleware.ForUsers(middleware.RequireMFA()))
+	adminOnly := middleware.RequireUser()
+	admin.Get("/users", middleware.RequirePermission(models.PermissionUsersRead), adminHandler.ListUsers)
+	admin.Put("/users/:id/role", adminOnly, middleware.RequirePermission(models.PermissionUsersWrite), adminHandler.AssignRole)
+	admin.Post("/users/:id/unlock", middleware.RequirePermission(models.PermissionUsersWrite), adminHandler.UnlockUser)
+	admin.Post("/users/:id/impersonate", adminOnly, middleware.RequirePermission(models.PermissionUsersWrite), adminHandler.Impersonate)
+	admin.Get("/users/:id/sessions", middleware.RequirePermission(models.PermissionUsersRead), sessionHandler.ListUserSessions)
+	admin.Delete("/users/:id/sessions/:sessionId", middleware.RequirePermission(models.PermissionUsersWrite), sessionHandler.RevokeUserSession)
+	admin.Get("/audit-logs", middleware.RequirePermission(models.PermissionUsersRead), adminHandler.ListAuditLogs)
+	admin.Get("/oauth/clients", middleware.RequirePermission(models.PermissionUsersRead), oauthHandler.ListClients)
+	admin.Post("/oauth/clients", adminOnly, middleware.RequirePermission(models.PermissionUsersWrite), oauthHandler.CreateClient)
+	admin.Delete("/oauth/clients/:id", adminOnly, middleware.RequirePermission(models.PermissionUsersWrite), oauthHandler.DeleteClient)
 
 	// Add health check endpoint
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -0,0 +1,123 @@
+// Package breached checks passwords against an offline list of breached
+// password hashes, such as the "ordered by hash" SHA-1 download of Have I
+// Been Pwned.
+//
+// The list is a text file with one uppercase or lowercase SHA-1 hex digest per
+// line, optionally followed by ":<count>", sorted by hash. When opened, only
+// the byte range of every 5-character hash prefix is indexed; a lookup reads
+// the range of the password's prefix and compares the remaining suffixes, so
+// the file is never loaded into memory.
+package breached
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const prefixLength = 5
+
+// List is an indexed breached password file. It is safe for concurrent use.
+type List struct {
+	file   *os.File
+	ranges map[string]span
+}
+
+type span struct {
+	offset int64
+	length int64
+}
+
+// Open indexes the breached password file at path
+func Open(path string) (*List, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+
+	list := &List{file: file, ranges: make(map[string]span)}
+	if err := list.index(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (l *List) index() error {
+	reader := bufio.NewReader(l.file)
+	var offset int64
+	var previous string
+
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			hash := strings.TrimSpace(line)
+			if hash != "" {
+				if len(hash) < sha1.Size*2 {
+					return fmt.Errorf("breached password list line %d is not a SHA-1 hash", lineNumber)
+				}
+
+				prefix := strings.ToUpper(hash[:prefixLength])
+				if prefix < previous {
+					return fmt.Errorf("breached password list is not sorted at line %d", lineNumber)
+				}
+				previous = prefix
+
+				r, ok := l.ranges[prefix]
+				if !ok {
+					r.offset = offset
+				}
+				r.length = offset + int64(len(line)) - r.offset
+				l.ranges[prefix] = r
+			}
+			offset += int64(len(line))
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read breached password list: %w", err)
+		}
+	}
+}
+
+// Contains reports whether the password appears in the list
+func (l *List) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	r, ok := l.ranges[hash[:prefixLength]]
+	if !ok {
+		return false, nil
+	}
+
+	buf := make([]byte, r.length)
+	if _, err := l.file.ReadAt(buf, r.offset); err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	suffix := []byte(hash[prefixLength:])
+	for _, line := range bytes.Split(buf, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) < sha1.Size*2 {
+			continue
+		}
+		if bytes.EqualFold(line[prefixLength:sha1.Size*2], suffix) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Close releases the list file
+func (l *List) Close() error {
+	return l.file.Close()
+}
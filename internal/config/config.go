@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -15,6 +16,121 @@ type Config struct {
 	ServerPort  string `mapstructure:"SERVER_PORT"`
 	LogLevel    string `mapstructure:"LOG_LEVEL"`
 	JWTSecret   string `mapstructure:"JWT_SECRET"`
+	JWTIssuer   string `mapstructure:"JWT_ISSUER"`
+
+	// Asymmetric signing keys. When JWTKeysDir is empty tokens are signed with JWTSecret (HS256).
+	JWTKeysDir       string `mapstructure:"JWT_KEYS_DIR"`
+	JWTActiveKeyID   string `mapstructure:"JWT_ACTIVE_KEY_ID"`
+	JWTRetiredKeyIDs string `mapstructure:"JWT_RETIRED_KEY_IDS"`
+
+	// TokenDenylistStore selects where revoked access tokens are kept ("memory" or "database")
+	TokenDenylistStore string `mapstructure:"TOKEN_DENYLIST_STORE"`
+
+	// Cookie auth mode for browser clients. When enabled, login and refresh
+	// deliver tokens in HttpOnly cookies instead of the response body.
+	AuthCookieMode     bool   `mapstructure:"AUTH_COOKIE_MODE"`
+	AuthCookieDomain   string `mapstructure:"AUTH_COOKIE_DOMAIN"`
+	AuthCookieSecure   bool   `mapstructure:"AUTH_COOKIE_SECURE"`
+	AuthCookieSameSite string `mapstructure:"AUTH_COOKIE_SAMESITE"`
+
+	// AppBaseURL is the public URL of the client application, used in email links
+	AppBaseURL string `mapstructure:"APP_BASE_URL"`
+
+	// EmailVerificationPolicy controls unverified accounts: "none", "restrict" (no permissions) or "block" (no login)
+	EmailVerificationPolicy string `mapstructure:"EMAIL_VERIFICATION_POLICY"`
+
+	// Outgoing mail. MailDriver is "log", "file" or "smtp".
+	MailDriver   string `mapstructure:"MAIL_DRIVER"`
+	MailFrom     string `mapstructure:"MAIL_FROM"`
+	MailDir      string `mapstructure:"MAIL_DIR"`
+	SMTPHost     string `mapstructure:"SMTP_HOST"`
+	SMTPPort     string `mapstructure:"SMTP_PORT"`
+	SMTPUsername string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword string `mapstructure:"SMTP_PASSWORD"`
+
+	// Failed login throttling. LoginAttemptStore is "memory" or "database".
+	LoginAttemptStore    string        `mapstructure:"LOGIN_ATTEMPT_STORE"`
+	LoginMaxFailures     int           `mapstructure:"LOGIN_MAX_FAILURES"`
+	LoginIPMaxFailures   int           `mapstructure:"LOGIN_IP_MAX_FAILURES"`
+	LoginFailureWindow   time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	LoginLockoutDuration time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	LoginDelayBase       time.Duration `mapstructure:"LOGIN_DELAY_BASE"`
+
+	// Password hashing. PasswordHashAlgorithm ("argon2id" or "bcrypt") is used
+	// for new hashes; stored hashes of either kind are upgraded on login.
+	PasswordHashAlgorithm string `mapstructure:"PASSWORD_HASH_ALGORITHM"`
+	Argon2Memory          int    `mapstructure:"ARGON2_MEMORY_KIB"`
+	Argon2Iterations      int    `mapstructure:"ARGON2_ITERATIONS"`
+	Argon2Parallelism     int    `mapstructure:"ARGON2_PARALLELISM"`
+	BcryptCost            int    `mapstructure:"BCRYPT_COST"`
+
+	// Rules for new passwords. Login does not apply them, so tightening the
+	// policy never locks out existing users.
+	PasswordMinLength            int  `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordMaxLength            int  `mapstructure:"PASSWORD_MAX_LENGTH"`
+	PasswordRequireUpper         bool `mapstructure:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireLower         bool `mapstructure:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireDigit         bool `mapstructure:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSymbol        bool `mapstructure:"PASSWORD_REQUIRE_SYMBOL"`
+	PasswordDisallowPersonalInfo bool `mapstructure:"PASSWORD_DISALLOW_PERSONAL_INFO"`
+
+	// PasswordHistorySize is how many previous passwords cannot be reused (0 disables the check)
+	PasswordHistorySize int `mapstructure:"PASSWORD_HISTORY_SIZE"`
+
+	// PasswordBreachedFile is a sorted file of SHA-1 hashes of breached passwords (empty disables the check)
+	PasswordBreachedFile string `mapstructure:"PASSWORD_BREACHED_FILE"`
+
+	// MagicLinkEnabled allows passwordless login with links sent by email
+	MagicLinkEnabled bool `mapstructure:"MAGIC_LINK_ENABLED"`
+
+	// OpenID Connect login providers, a comma-separated list of names. Each name
+	// is configured with OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and _SCOPES.
+	OIDCProviderNames string               `mapstructure:"OIDC_PROVIDERS"`
+	OIDCProviders     []OIDCProviderConfig `mapstructure:"-"`
+
+	// Internal services that call the API with HMAC-signed requests, a
+	// comma-separated list of key IDs. Each is configured with
+	// SERVICE_<ID>_SECRET and SERVICE_<ID>_PERMISSIONS (space-separated).
+	ServiceCredentialNames string                    `mapstructure:"SERVICE_CREDENTIALS"`
+	ServiceCredentials     []ServiceCredentialConfig `mapstructure:"-"`
+	// ServiceAuthMaxSkew is how far a signed request's timestamp may be from the server clock
+	ServiceAuthMaxSkew time.Duration `mapstructure:"SERVICE_AUTH_MAX_SKEW"`
+	// ServiceNonceStore selects where used request nonces are kept ("memory" or "database")
+	ServiceNonceStore string `mapstructure:"SERVICE_NONCE_STORE"`
+
+	// TaskTransitions is the task workflow, a comma-separated list of "from>to"
+	// status transitions. Empty uses the built-in workflow.
+	TaskTransitions string `mapstructure:"TASK_TRANSITIONS"`
+
+	// Due date reminders. TaskReminderNotifier is "log", "email" or "webhook".
+	TaskRemindersEnabled      bool          `mapstructure:"TASK_REMINDERS_ENABLED"`
+	TaskReminderBefore        time.Duration `mapstructure:"TASK_REMINDER_BEFORE"`
+	TaskReminderPollInterval  time.Duration `mapstructure:"TASK_REMINDER_POLL_INTERVAL"`
+	TaskReminderNotifier      string        `mapstructure:"TASK_REMINDER_NOTIFIER"`
+	TaskReminderWebhookURL    string        `mapstructure:"TASK_REMINDER_WEBHOOK_URL"`
+	TaskReminderWebhookSecret string        `mapstructure:"TASK_REMINDER_WEBHOOK_SECRET"`
+
+	// Initial administrator, created or promoted on startup when AdminEmail is set
+	AdminName     string `mapstructure:"ADMIN_NAME"`
+	AdminEmail    string `mapstructure:"ADMIN_EMAIL"`
+	AdminPassword string `mapstructure:"ADMIN_PASSWORD"`
+}
+
+// OIDCProviderConfig configures one OpenID Connect identity provider
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// ServiceCredentialConfig is the shared secret and permissions of one internal service
+type ServiceCredentialConfig struct {
+	KeyID       string
+	Secret      string
+	Permissions []string
 }
 
 // LoadConfig reads configuration from file or environment variables
@@ -26,6 +142,59 @@ func LoadConfig() (config Config, err error) {
 	viper.SetDefault("SERVER_PORT", "3000")
 	viper.SetDefault("LOG_LEVEL", "info")
 	viper.SetDefault("JWT_SECRET", "very-secret")
+	viper.SetDefault("JWT_ISSUER", "fiber-gorm")
+	viper.SetDefault("JWT_KEYS_DIR", "")
+	viper.SetDefault("JWT_ACTIVE_KEY_ID", "")
+	viper.SetDefault("JWT_RETIRED_KEY_IDS", "")
+	viper.SetDefault("TOKEN_DENYLIST_STORE", "database")
+	viper.SetDefault("AUTH_COOKIE_MODE", false)
+	viper.SetDefault("AUTH_COOKIE_DOMAIN", "")
+	viper.SetDefault("AUTH_COOKIE_SECURE", true)
+	viper.SetDefault("AUTH_COOKIE_SAMESITE", "Strict")
+	viper.SetDefault("APP_BASE_URL", "http://localhost:3000")
+	viper.SetDefault("EMAIL_VERIFICATION_POLICY", "none")
+	viper.SetDefault("MAIL_DRIVER", "log")
+	viper.SetDefault("MAIL_FROM", "no-reply@example.com")
+	viper.SetDefault("MAIL_DIR", "mail")
+	viper.SetDefault("SMTP_HOST", "")
+	viper.SetDefault("SMTP_PORT", "587")
+	viper.SetDefault("SMTP_USERNAME", "")
+	viper.SetDefault("SMTP_PASSWORD", "")
+	viper.SetDefault("LOGIN_ATTEMPT_STORE", "database")
+	viper.SetDefault("LOGIN_MAX_FAILURES", 5)
+	viper.SetDefault("LOGIN_IP_MAX_FAILURES", 20)
+	viper.SetDefault("LOGIN_FAILURE_WINDOW", "15m")
+	viper.SetDefault("LOGIN_LOCKOUT_DURATION", "15m")
+	viper.SetDefault("LOGIN_DELAY_BASE", "1s")
+	viper.SetDefault("PASSWORD_HASH_ALGORITHM", "argon2id")
+	viper.SetDefault("ARGON2_MEMORY_KIB", 65536)
+	viper.SetDefault("ARGON2_ITERATIONS", 3)
+	viper.SetDefault("ARGON2_PARALLELISM", 2)
+	viper.SetDefault("BCRYPT_COST", 12)
+	viper.SetDefault("PASSWORD_MIN_LENGTH", 8)
+	viper.SetDefault("PASSWORD_MAX_LENGTH", 128)
+	viper.SetDefault("PASSWORD_REQUIRE_UPPER", true)
+	viper.SetDefault("PASSWORD_REQUIRE_LOWER", false)
+	viper.SetDefault("PASSWORD_REQUIRE_DIGIT", true)
+	viper.SetDefault("PASSWORD_REQUIRE_SYMBOL", true)
+	viper.SetDefault("PASSWORD_DISALLOW_PERSONAL_INFO", true)
+	viper.SetDefault("PASSWORD_HISTORY_SIZE", 5)
+	viper.SetDefault("PASSWORD_BREACHED_FILE", "")
+	viper.SetDefault("MAGIC_LINK_ENABLED", false)
+	viper.SetDefault("OIDC_PROVIDERS", "")
+	viper.SetDefault("SERVICE_CREDENTIALS", "")
+	viper.SetDefault("SERVICE_AUTH_MAX_SKEW", "5m")
+	viper.SetDefault("SERVICE_NONCE_STORE", "database")
+	viper.SetDefault("TASK_TRANSITIONS", "")
+	viper.SetDefault("TASK_REMINDERS_ENABLED", true)
+	viper.SetDefault("TASK_REMINDER_BEFORE", "1h")
+	viper.SetDefault("TASK_REMINDER_POLL_INTERVAL", "1m")
+	viper.SetDefault("TASK_REMINDER_NOTIFIER", "log")
+	viper.SetDefault("TASK_REMINDER_WEBHOOK_URL", "")
+	viper.SetDefault("TASK_REMINDER_WEBHOOK_SECRET", "")
+	viper.SetDefault("ADMIN_NAME", "Administrator")
+	viper.SetDefault("ADMIN_EMAIL", "")
+	viper.SetDefault("ADMIN_PASSWORD", "")
 
 	// Look for .env file
 	viper.SetConfigName(".env")
@@ -45,6 +214,57 @@ func LoadConfig() (config Config, err error) {
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
 	// Unmarshal config
-	err = viper.Unmarshal(&config)
+	if err = viper.Unmarshal(&config); err != nil {
+		return
+	}
+
+	if config.TaskReminderPollInterval <= 0 {
+		return config, fmt.Errorf("TASK_REMINDER_POLL_INTERVAL must be positive, got %s", config.TaskReminderPollInterval)
+	}
+
+	config.OIDCProviders = loadOIDCProviders(config.OIDCProviderNames)
+	config.ServiceCredentials = loadServiceCredentials(config.ServiceCredentialNames)
 	return
 }
+
+// loadOIDCProviders reads the per-provider settings of each named provider
+func loadOIDCProviders(names string) []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range SplitList(names) {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, OIDCProviderConfig{
+			Name:         strings.ToLower(name),
+			Issuer:       viper.GetString(prefix + "ISSUER"),
+			ClientID:     viper.GetString(prefix + "CLIENT_ID"),
+			ClientSecret: viper.GetString(prefix + "CLIENT_SECRET"),
+			RedirectURL:  viper.GetString(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(viper.GetString(prefix + "SCOPES")),
+		})
+	}
+	return providers
+}
+
+// loadServiceCredentials reads the secret and permissions of each named service
+func loadServiceCredentials(keyIDs string) []ServiceCredentialConfig {
+	var credentials []ServiceCredentialConfig
+	for _, keyID := range SplitList(keyIDs) {
+		prefix := "SERVICE_" + strings.ToUpper(keyID) + "_"
+		credentials = append(credentials, ServiceCredentialConfig{
+			KeyID:       keyID,
+			Secret:      viper.GetString(prefix + "SECRET"),
+			Permissions: strings.Fields(viper.GetString(prefix + "PERMISSIONS")),
+		})
+	}
+	return credentials
+}
+
+// SplitList splits a comma-separated configuration value, dropping empty entries
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
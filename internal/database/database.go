@@ -3,6 +3,7 @@ package database
 import (
 	"fiber-gorm/internal/config"
 	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/repository"
 	"fmt"
 
 	"gorm.io/driver/sqlite"
@@ -10,13 +11,8 @@ import (
 	"gorm.io/gorm/logger"
 )
 
-func Connect() (*gorm.DB, error) {
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load config: %w", err)
-	}
-
+// Connect opens the database described by cfg and migrates all models
+func Connect(cfg config.Config) (*gorm.DB, error) {
 	// Configure GORM logger based on environment
 	logLevel := logger.Silent
 	if cfg.Environment == "development" {
@@ -30,6 +26,7 @@ func Connect() (*gorm.DB, error) {
 
 	// Connect to database based on driver
 	var db *gorm.DB
+	var err error
 	switch cfg.DBDriver {
 	case "sqlite":
 		db, err = gorm.Open(sqlite.Open(cfg.DBSource), gormConfig)
@@ -41,12 +38,47 @@ func Connect() (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	// Every connection to ":memory:" opens a new empty database, so keep one
+	if cfg.DBSource == ":memory:" {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("failed to access database handle: %w", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
 	// Auto migrate models
 	if err := db.AutoMigrate(
+		&models.Permission{},
+		&models.Role{},
 		&models.User{},
+		&models.RefreshToken{},
+		&models.Session{},
+		&models.RevokedToken{},
+		&models.OneTimeToken{},
+		&models.RecoveryCode{},
+		&models.LoginAttempt{},
+		&models.AuditLog{},
+		&models.APIKey{},
+		&models.Identity{},
+		&models.OIDCState{},
+		&models.OAuthClient{},
+		&models.OAuthAuthorizationCode{},
+		&models.OAuthConsent{},
+		&models.PasswordHistory{},
+		&models.UsedNonce{},
+		&models.TaskSeries{},
+		&models.Task{},
+		&models.TaskTransition{},
+		&models.TaskDependency{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	// Seed built-in roles and permissions
+	if err := repository.NewRoleRepository(db).SeedDefaultRoles(); err != nil {
+		return nil, fmt.Errorf("failed to seed roles: %w", err)
+	}
+
 	return db, nil
 }
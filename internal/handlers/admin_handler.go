@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"errors"
+	"fiber-gorm/internal/middleware"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/services"
+	"fiber-gorm/internal/validators"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// AdminHandler handles administrative routes
+type AdminHandler struct {
+	UserSvc  *services.UserService
+	AuthSvc  *services.AuthService
+	AuditSvc *services.AuditService
+}
+
+func NewAdminHandler(userSvc *services.UserService, authSvc *services.AuthService, auditSvc *services.AuditService) *AdminHandler {
+	return &AdminHandler{
+		UserSvc:  userSvc,
+		AuthSvc:  authSvc,
+		AuditSvc: auditSvc,
+	}
+}
+
+// ListUsers returns every user with their role
+func (h *AdminHandler) ListUsers(c *fiber.Ctx) error {
+	users, err := h.UserSvc.FindAllUsers()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list users")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list users",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(users)
+}
+
+// AssignRole changes the role of a user
+func (h *AdminHandler) AssignRole(c *fiber.Ctx) error {
+	var payload models.AssignRolePayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	user, err := h.UserSvc.AssignRole(c.Params("id"), payload.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, services.ErrRoleNotFound):
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		log.Error().Err(err).Str("userID", c.Params("id")).Msg("Failed to assign role")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to assign role",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(user)
+}
+
+// UnlockUser lifts a failed-login lockout on a user's account
+func (h *AdminHandler) UnlockUser(c *fiber.Ctx) error {
+	var actorID *uuid.UUID
+	if id, err := uuid.Parse(middleware.GetUserID(c)); err == nil {
+		actorID = &id
+	}
+
+	user, err := h.AuthSvc.UnlockUser(c.Params("id"), actorID, c.IP())
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		log.Error().Err(err).Str("userID", c.Params("id")).Msg("Failed to unlock user")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to unlock user",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Account unlocked",
+		"user":    user,
+	})
+}
+
+// ListAuditLogs returns recent audit events, optionally filtered with ?action= and ?limit=
+func (h *AdminHandler) ListAuditLogs(c *fiber.Ctx) error {
+	entries, err := h.AuditSvc.List(c.Query("action"), c.QueryInt("limit"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list audit logs")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list audit logs",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(entries)
+}
+
+// Impersonate issues a short-lived access token that lets the admin act as a user
+func (h *AdminHandler) Impersonate(c *fiber.Ctx) error {
+	var payload models.ImpersonatePayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	user, accessToken, expiresAt, err := h.AuthSvc.Impersonate(middleware.GetClaims(c), c.Params("id"), payload.Reason, c.IP())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, services.ErrImpersonateSelf), errors.Is(err, services.ErrImpersonateAdmin), errors.Is(err, services.ErrNestedImpersonation):
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		log.Error().Err(err).Str("userID", c.Params("id")).Msg("Failed to start impersonation")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start impersonation",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"token": TokenResponse{
+			AccessToken: accessToken,
+			TokenType:   "bearer",
+			ExpiresAt:   expiresAt,
+		},
+		"user": user,
+	})
+}
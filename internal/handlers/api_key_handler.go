@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"fiber-gorm/internal/middleware"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/services"
+	"fiber-gorm/internal/validators"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// APIKeyHandler handles the personal access token routes
+type APIKeyHandler struct {
+	APIKeySvc *services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeySvc *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		APIKeySvc: apiKeySvc,
+	}
+}
+
+// CreateAPIKey creates a key. The full key is only returned in this response.
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	var payload models.CreateAPIKeyPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	userID := middleware.GetUserID(c)
+	raw, key, err := h.APIKeySvc.CreateAPIKey(userID, &payload)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidScope) || errors.Is(err, services.ErrInvalidKeyExpiry) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		log.Error().Err(err).Str("userID", userID).Msg("Failed to create API key")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create API key",
+		})
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"key":     raw,
+		"api_key": key,
+	})
+}
+
+// ListAPIKeys returns the authenticated user's active keys
+func (h *APIKeyHandler) ListAPIKeys(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	keys, err := h.APIKeySvc.ListAPIKeys(userID)
+	if err != nil {
+		log.Error().Err(err).Str("userID", userID).Msg("Failed to list API keys")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list API keys",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(keys)
+}
+
+// RevokeAPIKey revokes one of the authenticated user's keys
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	if err := h.APIKeySvc.RevokeAPIKey(userID, c.Params("id")); err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		log.Error().Err(err).Str("userID", userID).Msg("Failed to revoke API key")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke API key",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "API key revoked",
+	})
+}
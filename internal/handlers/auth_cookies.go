@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"fiber-gorm/internal/config"
+	"fiber-gorm/internal/middleware"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// refreshCookiePath limits the refresh token cookie to the auth routes
+	// that use it (refresh and logout)
+	refreshCookiePath = "/api/auth"
+	// refreshCookieTTL matches the lifetime of refresh tokens
+	refreshCookieTTL = 7 * 24 * time.Hour
+)
+
+// deliverTokens prepares the token part of a login or refresh response. In
+// cookie auth mode the tokens are set as HttpOnly cookies, together with a
+// fresh CSRF token cookie, and left out of the body so scripts never see them.
+func deliverTokens(c *fiber.Ctx, cfg config.Config, token TokenResponse) TokenResponse {
+	if !cfg.AuthCookieMode {
+		return token
+	}
+
+	setAuthCookie(c, cfg, middleware.AccessTokenCookie, token.AccessToken, "/", true, token.ExpiresAt)
+	if token.RefreshToken != "" {
+		expiresAt := time.Now().Add(refreshCookieTTL)
+		setAuthCookie(c, cfg, middleware.RefreshTokenCookie, token.RefreshToken, refreshCookiePath, true, expiresAt)
+		setAuthCookie(c, cfg, middleware.CSRFCookie, rand.Text(), "/", false, expiresAt)
+	}
+
+	token.AccessToken = ""
+	token.RefreshToken = ""
+	token.TokenType = "cookie"
+	return token
+}
+
+// clearAuthCookies removes the cookies set by deliverTokens
+func clearAuthCookies(c *fiber.Ctx, cfg config.Config) {
+	if !cfg.AuthCookieMode {
+		return
+	}
+
+	expired := time.Unix(0, 0)
+	setAuthCookie(c, cfg, middleware.AccessTokenCookie, "", "/", true, expired)
+	setAuthCookie(c, cfg, middleware.RefreshTokenCookie, "", refreshCookiePath, true, expired)
+	setAuthCookie(c, cfg, middleware.CSRFCookie, "", "/", false, expired)
+}
+
+func setAuthCookie(c *fiber.Ctx, cfg config.Config, name, value, path string, httpOnly bool, expiresAt time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   cfg.AuthCookieDomain,
+		Expires:  expiresAt,
+		Secure:   cfg.AuthCookieSecure,
+		HTTPOnly: httpOnly,
+		SameSite: cfg.AuthCookieSameSite,
+	})
+}
@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"fiber-gorm/internal/middleware"
 	"fiber-gorm/internal/models"
 	"fiber-gorm/internal/services"
 	"fiber-gorm/internal/validators"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,12 +17,18 @@ import (
 
 // TokenResponse represents the response for token generation endpoints
 type TokenResponse struct {
-	AccessToken  string    `json:"access_token"`
-	RefreshToken string    `json:"refresh_token"`
+	AccessToken  string    `json:"access_token,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
 	TokenType    string    `json:"token_type"`
 	ExpiresAt    time.Time `json:"expires_at"`
 }
 
+// ProfileResponse is the current user, plus the admin acting as them during an impersonation
+type ProfileResponse struct {
+	*models.User
+	Impersonator *services.ActorClaim `json:"impersonator,omitempty"`
+}
+
 // AuthHandler handles authentication routes
 type AuthHandler struct {
 	AuthSvc *services.AuthService
@@ -41,7 +51,7 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	}
 
 	// Validate the payload
-	if errors := validators.ValidateUserCreation(&payload); errors != nil {
+	if errors := validators.ValidateUserCreation(&payload, h.AuthSvc.Passwords.Rules); errors != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 			"error": validators.FormatValidationError(errors, payload),
 		})
@@ -64,8 +74,16 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 
+	// Accounts that must verify their email first get no tokens yet
+	if h.AuthSvc.LoginBlocked(user) {
+		return c.Status(http.StatusCreated).JSON(fiber.Map{
+			"message": "Registration successful, please verify your email address",
+			"user":    user,
+		})
+	}
+
 	// Generate tokens for the newly registered user
-	accessToken, refreshToken, err := h.AuthSvc.CreateTokens(user)
+	accessToken, refreshToken, err := h.AuthSvc.CreateTokens(user, clientInfo(c), services.AuthMethodPassword)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate authentication tokens",
@@ -77,12 +95,12 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 
 	// Return the tokens
 	return c.Status(http.StatusCreated).JSON(fiber.Map{
-		"token": TokenResponse{
+		"token": deliverTokens(c, h.AuthSvc.Cfg, TokenResponse{
 			AccessToken:  accessToken,
 			RefreshToken: refreshToken,
 			TokenType:    "bearer",
 			ExpiresAt:    expiresAt,
-		},
+		}),
 		"user": user,
 	})
 }
@@ -105,10 +123,29 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	}
 
 	// Authenticate the user
-	user, accessToken, refreshToken, err := h.AuthSvc.LoginUser(&payload)
+	user, accessToken, refreshToken, err := h.AuthSvc.LoginUser(&payload, clientInfo(c))
 	if err != nil {
+		var throttled *services.LoginThrottledError
+		if errors.As(err, &throttled) {
+			return throttledResponse(c, throttled)
+		}
+
+		var mfaRequired *services.MFARequiredError
+		if errors.As(err, &mfaRequired) {
+			return c.Status(http.StatusOK).JSON(fiber.Map{
+				"mfa_required": true,
+				"mfa_token":    mfaRequired.ChallengeToken,
+			})
+		}
+
 		log.Debug().Err(err).Str("email", payload.Email).Msg("Login failed")
 
+		if errors.Is(err, services.ErrEmailNotVerified) {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
 		// For security reasons, don't specify whether email or password is incorrect
 		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
 			"error": err.Error(),
@@ -120,12 +157,12 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 
 	// Return the tokens
 	return c.Status(http.StatusOK).JSON(fiber.Map{
-		"token": TokenResponse{
+		"token": deliverTokens(c, h.AuthSvc.Cfg, TokenResponse{
 			AccessToken:  accessToken,
 			RefreshToken: refreshToken,
 			TokenType:    "bearer",
 			ExpiresAt:    expiresAt,
-		},
+		}),
 		"user": user,
 	})
 }
@@ -137,14 +174,26 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 		RefreshToken string `json:"refresh_token"`
 	}
 
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
-			"error": "Cannot parse JSON",
-		})
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Cannot parse JSON",
+			})
+		}
+	}
+
+	// Browser clients in cookie auth mode send the refresh token as a cookie
+	if req.RefreshToken == "" {
+		req.RefreshToken = c.Cookies(middleware.RefreshTokenCookie)
+		if req.RefreshToken != "" && !middleware.ValidCSRF(c) {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{
+				"error": "Invalid or missing CSRF token",
+			})
+		}
 	}
 
 	// Validate the refresh token and generate new tokens
-	accessToken, refreshToken, err := h.AuthSvc.RefreshTokens(req.RefreshToken)
+	accessToken, refreshToken, err := h.AuthSvc.RefreshTokens(req.RefreshToken, clientInfo(c))
 	if err != nil {
 		log.Debug().Err(err).Msg("Token refresh failed")
 		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
@@ -156,18 +205,18 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 	expiresAt := time.Now().Add(15 * time.Minute)
 
 	// Return the new tokens
-	return c.Status(http.StatusOK).JSON(TokenResponse{
+	return c.Status(http.StatusOK).JSON(deliverTokens(c, h.AuthSvc.Cfg, TokenResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		TokenType:    "bearer",
 		ExpiresAt:    expiresAt,
-	})
+	}))
 }
 
 // Me returns the authenticated user's information
 func (h *AuthHandler) Me(c *fiber.Ctx) error {
 	// Get the user ID from the context (set by auth middleware)
-	userID := c.Locals("userID").(string)
+	userID := middleware.GetUserID(c)
 	if userID == "" {
 		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Not authenticated",
@@ -183,14 +232,96 @@ func (h *AuthHandler) Me(c *fiber.Ctx) error {
 		})
 	}
 
-	// Return user info (excluding sensitive data)
-	return c.Status(http.StatusOK).JSON(user)
+	// Return user info (excluding sensitive data). While an admin is
+	// impersonating the user, the response names the admin.
+	return c.Status(http.StatusOK).JSON(ProfileResponse{
+		User:         user,
+		Impersonator: middleware.GetClaims(c).Actor,
+	})
 }
 
-// Logout doesn't actually invalidate tokens (since they're stateless)
-// In a production app, you'd implement token blacklisting or use Redis for token management
+// ServiceIdentity returns the principal of a signed service request, so a
+// service can check that its signing setup works
+func (h *AuthHandler) ServiceIdentity(c *fiber.Ctx) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil || !claims.Service() {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated as a service",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"subject":     claims.Subject,
+		"service":     claims.ServiceName,
+		"permissions": claims.Permissions,
+	})
+}
+
+// StopImpersonation ends the impersonation the current token was issued for
+func (h *AuthHandler) StopImpersonation(c *fiber.Ctx) error {
+	if err := h.AuthSvc.StopImpersonation(middleware.GetClaims(c), c.IP()); err != nil {
+		if errors.Is(err, services.ErrNotImpersonating) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		log.Error().Err(err).Msg("Failed to stop impersonation")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to stop impersonation",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Impersonation ended",
+	})
+}
+
+// Logout revokes the current access token and its refresh token family
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	// Get the claims from the context (set by auth middleware)
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	// Logging out of an impersonation ends it
+	var err error
+	if claims.Impersonated() {
+		err = h.AuthSvc.StopImpersonation(claims, c.IP())
+	} else {
+		err = h.AuthSvc.Logout(claims)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Str("userID", claims.Subject).Msg("Failed to logout")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to logout",
+		})
+	}
+
+	clearAuthCookies(c, h.AuthSvc.Cfg)
 	return c.Status(http.StatusOK).JSON(fiber.Map{
 		"message": "Logged out successfully",
 	})
 }
+
+// clientInfo collects the caller details recorded for logins
+func clientInfo(c *fiber.Ctx) services.ClientInfo {
+	return services.ClientInfo{
+		IP:        c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+	}
+}
+
+// throttledResponse rejects a login with 429 and tells the client when to retry
+func throttledResponse(c *fiber.Ctx, err *services.LoginThrottledError) error {
+	retryAfter := int(math.Ceil(err.RetryAfter.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	return c.Status(http.StatusTooManyRequests).JSON(fiber.Map{
+		"error":       err.Error(),
+		"retry_after": retryAfter,
+	})
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"fiber-gorm/internal/keys"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// KeysHandler publishes the token verification keys
+type KeysHandler struct {
+	Keys *keys.Manager
+}
+
+func NewKeysHandler(keyManager *keys.Manager) *KeysHandler {
+	return &KeysHandler{
+		Keys: keyManager,
+	}
+}
+
+// JWKS returns the public keys as a JSON Web Key Set
+func (h *KeysHandler) JWKS(c *fiber.Ctx) error {
+	c.Set("Cache-Control", "public, max-age=300")
+	return c.Status(http.StatusOK).JSON(h.Keys.JWKS())
+}
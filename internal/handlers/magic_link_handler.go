@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/services"
+	"fiber-gorm/internal/validators"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// MagicLinkHandler handles passwordless login routes
+type MagicLinkHandler struct {
+	MagicLinkSvc *services.MagicLinkService
+}
+
+func NewMagicLinkHandler(magicLinkSvc *services.MagicLinkService) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		MagicLinkSvc: magicLinkSvc,
+	}
+}
+
+// RequestLink emails a login link. The response is the same whether or not the account exists.
+func (h *MagicLinkHandler) RequestLink(c *fiber.Ctx) error {
+	var payload models.MagicLinkPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	if err := h.MagicLinkSvc.RequestLink(payload.Email); err != nil {
+		if errors.Is(err, services.ErrMagicLinkDisabled) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		log.Error().Err(err).Msg("Failed to send login link")
+	}
+
+	return c.Status(http.StatusAccepted).JSON(fiber.Map{
+		"message": "If the account exists, a login link has been sent",
+	})
+}
+
+// ConsumeLink signs the user in with the token from the login link
+func (h *MagicLinkHandler) ConsumeLink(c *fiber.Ctx) error {
+	var payload models.ConsumeMagicLinkPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	user, accessToken, refreshToken, err := h.MagicLinkSvc.ConsumeLink(payload.Token, clientInfo(c))
+	if err != nil {
+		var mfaRequired *services.MFARequiredError
+		if errors.As(err, &mfaRequired) {
+			return c.Status(http.StatusOK).JSON(fiber.Map{
+				"mfa_required": true,
+				"mfa_token":    mfaRequired.ChallengeToken,
+			})
+		}
+
+		if errors.Is(err, services.ErrMagicLinkDisabled) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrUserNotFound) {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+				"error": services.ErrInvalidToken.Error(),
+			})
+		}
+
+		log.Error().Err(err).Msg("Failed to log in with login link")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate authentication tokens",
+		})
+	}
+
+	// Calculate token expiration (15 minutes from now)
+	expiresAt := time.Now().Add(15 * time.Minute)
+
+	// Return the tokens
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"token": deliverTokens(c, h.MagicLinkSvc.Cfg, TokenResponse{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			TokenType:    "bearer",
+			ExpiresAt:    expiresAt,
+		}),
+		"user": user,
+	})
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+	"fiber-gorm/internal/middleware"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/services"
+	"fiber-gorm/internal/validators"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// MFAHandler handles two-factor enrollment and the second login step
+type MFAHandler struct {
+	MFASvc *services.MFAService
+}
+
+func NewMFAHandler(mfaSvc *services.MFAService) *MFAHandler {
+	return &MFAHandler{
+		MFASvc: mfaSvc,
+	}
+}
+
+// VerifyLogin exchanges the MFA challenge from Login and a code for tokens
+func (h *MFAHandler) VerifyLogin(c *fiber.Ctx) error {
+	var payload models.MFALoginPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	user, accessToken, refreshToken, err := h.MFASvc.CompleteLogin(payload.MFAToken, payload.Code, payload.RecoveryCode, clientInfo(c))
+	if err != nil {
+		var throttled *services.LoginThrottledError
+		if errors.As(err, &throttled) {
+			return throttledResponse(c, throttled)
+		}
+
+		log.Debug().Err(err).Msg("MFA login failed")
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"error": mfaErrorMessage(err),
+		})
+	}
+
+	// Calculate token expiration (15 minutes from now)
+	expiresAt := time.Now().Add(15 * time.Minute)
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"token": deliverTokens(c, h.MFASvc.Cfg, TokenResponse{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			TokenType:    "bearer",
+			ExpiresAt:    expiresAt,
+		}),
+		"user": user,
+	})
+}
+
+// EnrollTOTP starts TOTP enrollment and returns the secret and otpauth URI
+func (h *MFAHandler) EnrollTOTP(c *fiber.Ctx) error {
+	userID := middleware.GetUserID(c)
+	enrollment, err := h.MFASvc.BeginTOTPEnrollment(userID)
+	if err != nil {
+		return h.handleError(c, userID, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(enrollment)
+}
+
+// ConfirmTOTP enables TOTP and returns the recovery codes
+func (h *MFAHandler) ConfirmTOTP(c *fiber.Ctx) error {
+	var payload models.TOTPCodePayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	userID := middleware.GetUserID(c)
+	codes, err := h.MFASvc.ConfirmTOTPEnrollment(userID, payload.Code)
+	if err != nil {
+		return h.handleError(c, userID, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTOTP turns off two-factor authentication
+func (h *MFAHandler) DisableTOTP(c *fiber.Ctx) error {
+	var payload models.TOTPCodePayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	userID := middleware.GetUserID(c)
+	if err := h.MFASvc.DisableTOTP(userID, payload.Code); err != nil {
+		return h.handleError(c, userID, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes
+func (h *MFAHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var payload models.TOTPCodePayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	userID := middleware.GetUserID(c)
+	codes, err := h.MFASvc.RegenerateRecoveryCodes(userID, payload.Code)
+	if err != nil {
+		return h.handleError(c, userID, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"recovery_codes": codes,
+	})
+}
+
+func (h *MFAHandler) handleError(c *fiber.Ctx, userID string, err error) error {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	case errors.Is(err, services.ErrMFAAlreadyEnabled),
+		errors.Is(err, services.ErrMFANotEnabled),
+		errors.Is(err, services.ErrMFAEnrollmentMissing):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidMFACode):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Error().Err(err).Str("userID", userID).Msg("Two-factor operation failed")
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to update two-factor authentication",
+	})
+}
+
+func mfaErrorMessage(err error) string {
+	if errors.Is(err, services.ErrInvalidMFACode) || errors.Is(err, services.ErrInvalidMFAChallenge) {
+		return err.Error()
+	}
+	return services.ErrInvalidMFAChallenge.Error()
+}
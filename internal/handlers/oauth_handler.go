@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fiber-gorm/internal/middleware"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/services"
+	"fiber-gorm/internal/validators"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// OAuthHandler handles the OAuth 2.0 authorization server routes used by
+// third-party apps and the client management routes for admins
+type OAuthHandler struct {
+	OAuthSvc *services.OAuthService
+}
+
+func NewOAuthHandler(oauthSvc *services.OAuthService) *OAuthHandler {
+	return &OAuthHandler{
+		OAuthSvc: oauthSvc,
+	}
+}
+
+// Authorize validates an authorization request and returns the details to
+// show the signed-in user on the consent screen
+func (h *OAuthHandler) Authorize(c *fiber.Ctx) error {
+	var req models.AuthorizeRequest
+	if err := c.QueryParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse query",
+		})
+	}
+
+	details, err := h.OAuthSvc.ValidateAuthorization(middleware.GetUserID(c), &req)
+	if err != nil {
+		return h.authorizeError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(details)
+}
+
+// Consent records the user's decision and returns where to send the browser next
+func (h *OAuthHandler) Consent(c *fiber.Ctx) error {
+	var payload models.ConsentPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	redirectURI, err := h.OAuthSvc.Authorize(middleware.GetUserID(c), &payload.AuthorizeRequest, payload.Approve)
+	if err != nil {
+		return h.authorizeError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"redirect_uri": redirectURI,
+	})
+}
+
+func (h *OAuthHandler) authorizeError(c *fiber.Ctx, err error) error {
+	var oauthErr *services.OAuthError
+	if errors.As(err, &oauthErr) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": oauthErr.Description,
+		})
+	}
+
+	log.Error().Err(err).Msg("Failed to process authorization request")
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to process authorization request",
+	})
+}
+
+// Token is the token endpoint (RFC 6749 section 3.2)
+func (h *OAuthHandler) Token(c *fiber.Ctx) error {
+	var req models.OAuthTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return tokenEndpointError(c, &services.OAuthError{Code: services.OAuthErrInvalidRequest, Description: "Cannot parse request"})
+	}
+
+	client, err := h.authenticateClient(c, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return tokenEndpointError(c, err)
+	}
+
+	resp, err := h.OAuthSvc.Token(client, &req)
+	if err != nil {
+		return tokenEndpointError(c, err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+	return c.Status(http.StatusOK).JSON(resp)
+}
+
+// Introspect is the token introspection endpoint (RFC 7662)
+func (h *OAuthHandler) Introspect(c *fiber.Ctx) error {
+	var params models.OAuthTokenParams
+	if err := c.BodyParser(&params); err != nil {
+		return tokenEndpointError(c, &services.OAuthError{Code: services.OAuthErrInvalidRequest, Description: "Cannot parse request"})
+	}
+
+	client, err := h.authenticateClient(c, params.ClientID, params.ClientSecret)
+	if err != nil {
+		return tokenEndpointError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(h.OAuthSvc.Introspect(client, params.Token, params.TokenTypeHint))
+}
+
+// Revoke is the token revocation endpoint (RFC 7009)
+func (h *OAuthHandler) Revoke(c *fiber.Ctx) error {
+	var params models.OAuthTokenParams
+	if err := c.BodyParser(&params); err != nil {
+		return tokenEndpointError(c, &services.OAuthError{Code: services.OAuthErrInvalidRequest, Description: "Cannot parse request"})
+	}
+
+	client, err := h.authenticateClient(c, params.ClientID, params.ClientSecret)
+	if err != nil {
+		return tokenEndpointError(c, err)
+	}
+
+	if err := h.OAuthSvc.Revoke(client, params.Token, params.TokenTypeHint); err != nil {
+		return tokenEndpointError(c, err)
+	}
+
+	return c.SendStatus(http.StatusOK)
+}
+
+// authenticateClient reads client credentials from HTTP Basic authentication
+// or, failing that, from the request body (RFC 6749 section 2.3.1)
+func (h *OAuthHandler) authenticateClient(c *fiber.Ctx, clientID, clientSecret string) (*models.OAuthClient, error) {
+	if auth := c.Get(fiber.HeaderAuthorization); strings.HasPrefix(auth, "Basic ") {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+		if err != nil {
+			return nil, &services.OAuthError{Code: services.OAuthErrInvalidClient, Description: "Client authentication failed"}
+		}
+
+		username, password, _ := strings.Cut(string(decoded), ":")
+		if clientID, err = url.QueryUnescape(username); err != nil {
+			return nil, &services.OAuthError{Code: services.OAuthErrInvalidClient, Description: "Client authentication failed"}
+		}
+		if clientSecret, err = url.QueryUnescape(password); err != nil {
+			return nil, &services.OAuthError{Code: services.OAuthErrInvalidClient, Description: "Client authentication failed"}
+		}
+	}
+
+	return h.OAuthSvc.AuthenticateClient(clientID, clientSecret)
+}
+
+// tokenEndpointError writes an error response in the format of RFC 6749 section 5.2
+func tokenEndpointError(c *fiber.Ctx, err error) error {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		log.Error().Err(err).Str("request_id", middleware.GetRequestID(c)).Msg("OAuth token endpoint failed")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "server_error",
+		})
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == services.OAuthErrInvalidClient {
+		status = http.StatusUnauthorized
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(status).JSON(fiber.Map{
+		"error":             oauthErr.Code,
+		"error_description": oauthErr.Description,
+	})
+}
+
+// CreateClient registers a third-party app. The client secret is only returned in this response.
+func (h *OAuthHandler) CreateClient(c *fiber.Ctx) error {
+	var payload models.CreateOAuthClientPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	client, secret, err := h.OAuthSvc.CreateClient(&payload)
+	if err != nil {
+		var oauthErr *services.OAuthError
+		if errors.As(err, &oauthErr) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": oauthErr.Description,
+			})
+		}
+
+		log.Error().Err(err).Msg("Failed to create OAuth client")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create OAuth client",
+		})
+	}
+
+	response := fiber.Map{"client": client}
+	if secret != "" {
+		response["client_secret"] = secret
+	}
+	return c.Status(http.StatusCreated).JSON(response)
+}
+
+// ListClients returns all registered third-party apps
+func (h *OAuthHandler) ListClients(c *fiber.Ctx) error {
+	clients, err := h.OAuthSvc.ListClients()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list OAuth clients")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list OAuth clients",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(clients)
+}
+
+// DeleteClient removes a third-party app and revokes its refresh tokens
+func (h *OAuthHandler) DeleteClient(c *fiber.Ctx) error {
+	if err := h.OAuthSvc.DeleteClient(c.Params("id")); err != nil {
+		if errors.Is(err, services.ErrOAuthClientNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		log.Error().Err(err).Msg("Failed to delete OAuth client")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete OAuth client",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "OAuth client deleted",
+	})
+}
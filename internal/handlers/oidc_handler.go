@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+	"fiber-gorm/internal/middleware"
+	"fiber-gorm/internal/services"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// OIDCStateCookie binds an OpenID Connect login to the browser that started it
+const OIDCStateCookie = "oidc_state"
+
+// oidcStateCookiePath limits the state cookie to the callback route
+const oidcStateCookiePath = "/api/auth/oidc"
+
+// OIDCHandler handles login with external OpenID Connect providers
+type OIDCHandler struct {
+	OIDCSvc *services.OIDCService
+}
+
+func NewOIDCHandler(oidcSvc *services.OIDCService) *OIDCHandler {
+	return &OIDCHandler{
+		OIDCSvc: oidcSvc,
+	}
+}
+
+// Providers lists the configured identity providers
+func (h *OIDCHandler) Providers(c *fiber.Ctx) error {
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"providers": h.OIDCSvc.ProviderNames(),
+	})
+}
+
+// Login redirects the browser to the identity provider
+func (h *OIDCHandler) Login(c *fiber.Ctx) error {
+	authURL, state, err := h.OIDCSvc.BeginLogin(c.UserContext(), c.Params("provider"), nil)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	h.setStateCookie(c, state, time.Now().Add(services.OIDCStateTTL))
+	return c.Redirect(authURL, http.StatusFound)
+}
+
+// Callback completes the login when the identity provider redirects back
+func (h *OIDCHandler) Callback(c *fiber.Ctx) error {
+	if providerError := c.Query("error"); providerError != "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Login was cancelled or denied at the identity provider",
+		})
+	}
+
+	// The state is single use either way
+	browserState := c.Cookies(OIDCStateCookie)
+	h.setStateCookie(c, "", time.Unix(0, 0))
+
+	result, err := h.OIDCSvc.HandleCallback(c.UserContext(), c.Params("provider"), c.Query("code"), c.Query("state"), browserState, clientInfo(c))
+	if err != nil {
+		var mfaRequired *services.MFARequiredError
+		if errors.As(err, &mfaRequired) {
+			return c.Status(http.StatusOK).JSON(fiber.Map{
+				"mfa_required": true,
+				"mfa_token":    mfaRequired.ChallengeToken,
+			})
+		}
+
+		return h.handleError(c, err)
+	}
+
+	if result.Linked {
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"message":  "Identity linked",
+			"identity": result.Identity,
+		})
+	}
+
+	// Calculate token expiration (15 minutes from now)
+	expiresAt := time.Now().Add(15 * time.Minute)
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"token": deliverTokens(c, h.OIDCSvc.Cfg, TokenResponse{
+			AccessToken:  result.AccessToken,
+			RefreshToken: result.RefreshToken,
+			TokenType:    "bearer",
+			ExpiresAt:    expiresAt,
+		}),
+		"user": result.User,
+	})
+}
+
+// ListIdentities returns the authenticated user's linked identities
+func (h *OIDCHandler) ListIdentities(c *fiber.Ctx) error {
+	identities, err := h.OIDCSvc.ListIdentities(middleware.GetUserID(c))
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(identities)
+}
+
+// LinkIdentity starts linking a provider to the authenticated user. The
+// client must send the browser to the returned URL, and the request must
+// come from that browser, which receives the state cookie.
+func (h *OIDCHandler) LinkIdentity(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(middleware.GetUserID(c))
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	authURL, state, err := h.OIDCSvc.BeginLogin(c.UserContext(), c.Params("provider"), &userID)
+	if err != nil {
+		return h.handleError(c, err)
+	}
+
+	h.setStateCookie(c, state, time.Now().Add(services.OIDCStateTTL))
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"authorization_url": authURL,
+	})
+}
+
+// UnlinkIdentity removes one of the authenticated user's linked identities
+func (h *OIDCHandler) UnlinkIdentity(c *fiber.Ctx) error {
+	if err := h.OIDCSvc.UnlinkIdentity(middleware.GetUserID(c), c.Params("id")); err != nil {
+		return h.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Identity unlinked",
+	})
+}
+
+// setStateCookie sets or, with an expiry in the past, clears the state
+// cookie. SameSite=Lax lets the provider's redirect back carry it.
+func (h *OIDCHandler) setStateCookie(c *fiber.Ctx, state string, expiresAt time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     OIDCStateCookie,
+		Value:    state,
+		Path:     oidcStateCookiePath,
+		Domain:   h.OIDCSvc.Cfg.AuthCookieDomain,
+		Expires:  expiresAt,
+		Secure:   h.OIDCSvc.Cfg.AuthCookieSecure,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+func (h *OIDCHandler) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrUnknownProvider),
+		errors.Is(err, services.ErrIdentityNotFound),
+		errors.Is(err, services.ErrUserNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidOIDCState),
+		errors.Is(err, services.ErrOIDCEmailRequired):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrOIDCLoginFailed):
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrOIDCAccountExists),
+		errors.Is(err, services.ErrIdentityInUse):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrEmailNotVerified):
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Error().Err(err).Str("provider", c.Params("provider")).Msg("OIDC request failed")
+	return c.Status(http.StatusBadGateway).JSON(fiber.Map{
+		"error": "Identity provider is unavailable",
+	})
+}
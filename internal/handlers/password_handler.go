@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"fiber-gorm/internal/middleware"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/services"
+	"fiber-gorm/internal/validators"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// PasswordHandler handles password reset and change routes
+type PasswordHandler struct {
+	PasswordSvc *services.PasswordService
+}
+
+func NewPasswordHandler(passwordSvc *services.PasswordService) *PasswordHandler {
+	return &PasswordHandler{
+		PasswordSvc: passwordSvc,
+	}
+}
+
+// ForgotPassword sends a password reset email. The response is the same
+// whether or not the account exists.
+func (h *PasswordHandler) ForgotPassword(c *fiber.Ctx) error {
+	var payload models.ForgotPasswordPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	if err := h.PasswordSvc.RequestReset(payload.Email); err != nil {
+		log.Error().Err(err).Msg("Failed to send password reset email")
+	}
+
+	return c.Status(http.StatusAccepted).JSON(fiber.Map{
+		"message": "If the account exists, a password reset email has been sent",
+	})
+}
+
+// ResetPassword sets a new password using the token from the reset email
+func (h *PasswordHandler) ResetPassword(c *fiber.Ctx) error {
+	var payload models.ResetPasswordPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	if err := h.PasswordSvc.ResetPassword(payload.Token, payload.Password); err != nil {
+		if errors.Is(err, services.ErrInvalidToken) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid or expired reset token",
+			})
+		}
+
+		var rejected *services.PasswordRejectedError
+		if errors.As(err, &rejected) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": rejected.Error(),
+			})
+		}
+
+		log.Error().Err(err).Msg("Failed to reset password")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reset password",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Password has been reset, please log in again",
+	})
+}
+
+// ChangePassword changes the password of the authenticated user
+func (h *PasswordHandler) ChangePassword(c *fiber.Ctx) error {
+	var payload models.ChangePasswordPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	userID := middleware.GetUserID(c)
+	if err := h.PasswordSvc.ChangePassword(userID, payload.CurrentPassword, payload.NewPassword); err != nil {
+		var rejected *services.PasswordRejectedError
+		if errors.Is(err, services.ErrIncorrectPassword) || errors.As(err, &rejected) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		log.Error().Err(err).Str("userID", userID).Msg("Failed to change password")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to change password",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Password changed successfully",
+	})
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"fiber-gorm/internal/middleware"
+	"fiber-gorm/internal/services"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// SessionHandler handles listing and revoking logins
+type SessionHandler struct {
+	SessionSvc *services.SessionService
+}
+
+func NewSessionHandler(sessionSvc *services.SessionService) *SessionHandler {
+	return &SessionHandler{
+		SessionSvc: sessionSvc,
+	}
+}
+
+// ListSessions returns the authenticated user's active sessions
+func (h *SessionHandler) ListSessions(c *fiber.Ctx) error {
+	claims := middleware.GetClaims(c)
+	return h.listSessions(c, claims.Subject, claims.SessionID)
+}
+
+// RevokeSession ends one of the authenticated user's sessions
+func (h *SessionHandler) RevokeSession(c *fiber.Ctx) error {
+	claims := middleware.GetClaims(c)
+	return h.revokeSession(c, claims.Subject, claims)
+}
+
+// RevokeOtherSessions ends every session except the one making the request
+func (h *SessionHandler) RevokeOtherSessions(c *fiber.Ctx) error {
+	claims := middleware.GetClaims(c)
+	if err := h.SessionSvc.RevokeOtherSessions(claims); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "The current token does not belong to a session",
+			})
+		}
+
+		log.Error().Err(err).Str("userID", claims.Subject).Msg("Failed to revoke sessions")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke sessions",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "All other sessions revoked",
+	})
+}
+
+// ListUserSessions returns the active sessions of any user, for support staff
+func (h *SessionHandler) ListUserSessions(c *fiber.Ctx) error {
+	return h.listSessions(c, c.Params("id"), "")
+}
+
+// RevokeUserSession ends a session of any user, for support staff
+func (h *SessionHandler) RevokeUserSession(c *fiber.Ctx) error {
+	return h.revokeSession(c, c.Params("id"), nil)
+}
+
+func (h *SessionHandler) listSessions(c *fiber.Ctx, userID, currentSessionID string) error {
+	sessions, err := h.SessionSvc.ListSessions(userID, currentSessionID)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		log.Error().Err(err).Str("userID", userID).Msg("Failed to list sessions")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list sessions",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(sessions)
+}
+
+func (h *SessionHandler) revokeSession(c *fiber.Ctx, userID string, current *services.TokenClaims) error {
+	if err := h.SessionSvc.RevokeSession(userID, c.Params("sessionId"), current); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) || errors.Is(err, services.ErrUserNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"error": services.ErrSessionNotFound.Error(),
+			})
+		}
+
+		log.Error().Err(err).Str("userID", userID).Msg("Failed to revoke session")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke session",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Session revoked",
+	})
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"errors"
+	"fiber-gorm/internal/middleware"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/services"
+	"fiber-gorm/internal/validators"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// TaskHandler handles the task routes
+type TaskHandler struct {
+	TaskSvc *services.TaskService
+}
+
+func NewTaskHandler(taskSvc *services.TaskService) *TaskHandler {
+	return &TaskHandler{
+		TaskSvc: taskSvc,
+	}
+}
+
+// CreateTask creates a task owned by the authenticated user
+func (h *TaskHandler) CreateTask(c *fiber.Ctx) error {
+	var payload models.CreateTaskPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.ValidateTaskCreation(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	task, err := h.TaskSvc.CreateTask(middleware.GetUserID(c), &payload)
+	if err != nil {
+		return taskError(c, err, "Failed to create task")
+	}
+
+	return c.Status(http.StatusCreated).JSON(task)
+}
+
+// ListTasks returns the authenticated user's tasks, filtered by the
+// status, priority and due (overdue, this_week) query parameters
+func (h *TaskHandler) ListTasks(c *fiber.Ctx) error {
+	var query models.TaskListQuery
+	if err := c.QueryParser(&query); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid query parameters",
+		})
+	}
+
+	if err := validators.Validate(&query); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, query),
+		})
+	}
+
+	userID := middleware.GetUserID(c)
+	tasks, err := h.TaskSvc.ListTasks(userID, &query)
+	if err != nil {
+		log.Error().Err(err).Str("userID", userID).Msg("Failed to list tasks")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list tasks",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(tasks)
+}
+
+// GetTask returns one task
+func (h *TaskHandler) GetTask(c *fiber.Ctx) error {
+	task, err := h.TaskSvc.GetTask(middleware.GetUserID(c), c.Params("id"))
+	if err != nil {
+		return taskError(c, err, "Failed to load task")
+	}
+
+	return c.Status(http.StatusOK).JSON(task)
+}
+
+// UpdateTask replaces the editable fields of a task. For an occurrence of a
+// recurring task, the scope query parameter selects this occurrence only
+// (the default) or all future occurrences.
+func (h *TaskHandler) UpdateTask(c *fiber.Ctx) error {
+	scope := c.Query("scope", models.TaskEditScopeThis)
+	if scope != models.TaskEditScopeThis && scope != models.TaskEditScopeFuture {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "scope must be one of: this, future",
+		})
+	}
+
+	var payload models.UpdateTaskPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.ValidateTaskUpdate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	task, err := h.TaskSvc.UpdateTask(middleware.GetUserID(c), c.Params("id"), scope, &payload)
+	if err != nil {
+		return taskError(c, err, "Failed to update task")
+	}
+
+	return c.Status(http.StatusOK).JSON(task)
+}
+
+// DeleteTask deletes a task
+func (h *TaskHandler) DeleteTask(c *fiber.Ctx) error {
+	if err := h.TaskSvc.DeleteTask(middleware.GetUserID(c), c.Params("id")); err != nil {
+		return taskError(c, err, "Failed to delete task")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Task deleted",
+	})
+}
+
+// TransitionTask moves a task to another status of the workflow
+func (h *TaskHandler) TransitionTask(c *fiber.Ctx) error {
+	var payload models.TransitionTaskPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	// While impersonating, the history names the admin
+	actorID := middleware.GetUserID(c)
+	if claims := middleware.GetClaims(c); claims.Impersonated() {
+		actorID = claims.Actor.Subject
+	}
+
+	task, err := h.TaskSvc.TransitionTask(middleware.GetUserID(c), actorID, c.Params("id"), &payload)
+	if err != nil {
+		return taskError(c, err, "Failed to transition task")
+	}
+
+	return c.Status(http.StatusOK).JSON(task)
+}
+
+// ListTransitions returns the status history of a task
+func (h *TaskHandler) ListTransitions(c *fiber.Ctx) error {
+	transitions, err := h.TaskSvc.ListTransitions(middleware.GetUserID(c), c.Params("id"))
+	if err != nil {
+		return taskError(c, err, "Failed to list task transitions")
+	}
+
+	return c.Status(http.StatusOK).JSON(transitions)
+}
+
+// ListSubtasks returns the subtasks of a task
+func (h *TaskHandler) ListSubtasks(c *fiber.Ctx) error {
+	subtasks, err := h.TaskSvc.ListSubtasks(middleware.GetUserID(c), c.Params("id"))
+	if err != nil {
+		return taskError(c, err, "Failed to list subtasks")
+	}
+
+	return c.Status(http.StatusOK).JSON(subtasks)
+}
+
+// ListDependencies returns the tasks a task is blocked by
+func (h *TaskHandler) ListDependencies(c *fiber.Ctx) error {
+	blockers, err := h.TaskSvc.ListBlockers(middleware.GetUserID(c), c.Params("id"))
+	if err != nil {
+		return taskError(c, err, "Failed to list task dependencies")
+	}
+
+	return c.Status(http.StatusOK).JSON(blockers)
+}
+
+// AddDependency makes a task blocked by another task
+func (h *TaskHandler) AddDependency(c *fiber.Ctx) error {
+	var payload models.AddTaskDependencyPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	dependency, err := h.TaskSvc.AddDependency(middleware.GetUserID(c), c.Params("id"), &payload)
+	if err != nil {
+		return taskError(c, err, "Failed to add task dependency")
+	}
+
+	return c.Status(http.StatusCreated).JSON(dependency)
+}
+
+// RemoveDependency removes a dependency of a task
+func (h *TaskHandler) RemoveDependency(c *fiber.Ctx) error {
+	if err := h.TaskSvc.RemoveDependency(middleware.GetUserID(c), c.Params("id"), c.Params("blockerId")); err != nil {
+		return taskError(c, err, "Failed to remove task dependency")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Dependency removed",
+	})
+}
+
+// PlanTasks returns the tasks named by the ids query parameter and their
+// open blockers in an order that respects the dependencies
+func (h *TaskHandler) PlanTasks(c *fiber.Ctx) error {
+	var query models.TaskPlanQuery
+	if err := c.QueryParser(&query); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid query parameters",
+		})
+	}
+
+	if err := validators.Validate(&query); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, query),
+		})
+	}
+
+	plan, err := h.TaskSvc.PlanTasks(middleware.GetUserID(c), strings.Split(query.IDs, ","))
+	if err != nil {
+		return taskError(c, err, "Failed to plan tasks")
+	}
+
+	return c.Status(http.StatusOK).JSON(plan)
+}
+
+// taskError maps task service errors to responses, logging unexpected ones
+func taskError(c *fiber.Ctx, err error, message string) error {
+	if errors.Is(err, services.ErrTaskNotFound) || errors.Is(err, services.ErrDependencyNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if errors.Is(err, services.ErrRecurrenceScope) || errors.Is(err, services.ErrRecurrenceDueDate) ||
+		errors.Is(err, services.ErrParentNotFound) || errors.Is(err, services.ErrPlanTooLarge) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var cycleErr *services.DependencyCycleError
+	if errors.As(err, &cycleErr) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+			"cycle": cycleErr.Cycle,
+		})
+	}
+
+	var blockedErr *services.BlockedTaskError
+	if errors.As(err, &blockedErr) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error":      err.Error(),
+			"blocked_by": blockedErr.BlockedBy,
+		})
+	}
+
+	var transitionErr *services.InvalidTransitionError
+	if errors.As(err, &transitionErr) || errors.Is(err, services.ErrTaskConflict) || errors.Is(err, services.ErrSubtaskCycle) ||
+		errors.Is(err, services.ErrDoneTaskBlocked) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	log.Error().Err(err).Str("taskID", c.Params("id")).Msg(message)
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error": message,
+	})
+}
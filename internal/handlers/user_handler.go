@@ -9,11 +9,12 @@ import (
 )
 
 type UserHandler struct {
-	Svc *services.UserService
+	Svc            *services.UserService
+	PasswordPolicy validators.PasswordPolicy
 }
 
-func NewUserHandler(svc *services.UserService) *UserHandler {
-	return &UserHandler{Svc: svc}
+func NewUserHandler(svc *services.UserService, passwordPolicy validators.PasswordPolicy) *UserHandler {
+	return &UserHandler{Svc: svc, PasswordPolicy: passwordPolicy}
 }
 
 func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
@@ -26,7 +27,7 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 	}
 
 	// Validate the payload using custom validator
-	if err := validators.ValidateUserCreation(&payload); err != nil {
+	if err := validators.ValidateUserCreation(&payload, h.PasswordPolicy); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"errors": validators.FormatValidationError(err, payload),
 		})
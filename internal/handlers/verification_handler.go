@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/services"
+	"fiber-gorm/internal/validators"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// VerificationHandler handles email verification routes
+type VerificationHandler struct {
+	VerificationSvc *services.EmailVerificationService
+}
+
+func NewVerificationHandler(verificationSvc *services.EmailVerificationService) *VerificationHandler {
+	return &VerificationHandler{
+		VerificationSvc: verificationSvc,
+	}
+}
+
+// VerifyEmail confirms an email address using the token from the verification link
+func (h *VerificationHandler) VerifyEmail(c *fiber.Ctx) error {
+	var payload models.VerifyEmailPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	user, err := h.VerificationSvc.VerifyEmail(payload.Token)
+	if err != nil {
+		log.Debug().Err(err).Msg("Email verification failed")
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid or expired verification token",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Email verified successfully",
+		"user":    user,
+	})
+}
+
+// ResendVerification sends a new verification email. The response is the same
+// whether or not the account exists.
+func (h *VerificationHandler) ResendVerification(c *fiber.Ctx) error {
+	var payload models.ResendVerificationPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if err := validators.Validate(&payload); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": validators.FormatValidationError(err, payload),
+		})
+	}
+
+	if err := h.VerificationSvc.ResendVerification(payload.Email); err != nil {
+		log.Error().Err(err).Msg("Failed to resend verification email")
+	}
+
+	return c.Status(http.StatusAccepted).JSON(fiber.Map{
+		"message": "If the account exists and is unverified, a verification email has been sent",
+	})
+}
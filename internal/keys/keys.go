@@ -0,0 +1,213 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"fiber-gorm/internal/config"
+)
+
+// Error types for key management
+var (
+	ErrUnknownKey = errors.New("unknown signing key")
+	ErrRetiredKey = errors.New("signing key has been retired")
+)
+
+// Key is a single signing or verification key identified by its kid
+type Key struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+	Retired    bool
+}
+
+// Manager signs tokens with the active key and verifies them with any
+// known, non-retired key. Without asymmetric keys it falls back to HS256
+// using the shared JWT secret.
+type Manager struct {
+	keys   map[string]*Key
+	active *Key
+	secret []byte
+}
+
+// NewManager builds a key manager from configuration. When JWTKeysDir is
+// empty the manager signs with HS256 and cfg.JWTSecret.
+func NewManager(cfg config.Config) (*Manager, error) {
+	if cfg.JWTKeysDir == "" {
+		return NewHMACManager(cfg.JWTSecret), nil
+	}
+
+	loaded, err := LoadDir(cfg.JWTKeysDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeyManager(loaded, cfg.JWTActiveKeyID, config.SplitList(cfg.JWTRetiredKeyIDs))
+}
+
+// NewHMACManager creates a manager that signs and verifies with a shared secret
+func NewHMACManager(secret string) *Manager {
+	return &Manager{secret: []byte(secret)}
+}
+
+// NewKeyManager creates a manager from loaded keys. activeID selects the
+// signing key; keys listed in retiredIDs are no longer accepted.
+func NewKeyManager(loaded []*Key, activeID string, retiredIDs []string) (*Manager, error) {
+	m := &Manager{keys: make(map[string]*Key, len(loaded))}
+	for _, key := range loaded {
+		m.keys[key.ID] = key
+	}
+
+	for _, id := range retiredIDs {
+		key, ok := m.keys[id]
+		if !ok {
+			return nil, fmt.Errorf("retired key %q not found", id)
+		}
+		key.Retired = true
+	}
+
+	active, ok := m.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found", activeID)
+	}
+	if active.Retired {
+		return nil, fmt.Errorf("active key %q is retired", activeID)
+	}
+	if active.PrivateKey == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeID)
+	}
+	m.active = active
+
+	return m, nil
+}
+
+// LoadDir reads every .pem file in dir. The file name without extension
+// becomes the key ID. Public-key-only files are loaded as verification keys.
+func LoadDir(dir string) ([]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	sort.Strings(paths)
+
+	loaded := make([]*Key, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key %s: %w", path, err)
+		}
+
+		id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		key, err := ParsePEM(id, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key %s: %w", path, err)
+		}
+		loaded = append(loaded, key)
+	}
+
+	return loaded, nil
+}
+
+// ParsePEM parses an RSA or Ed25519 private or public key
+func ParsePEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: id}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.PrivateKey, key.PublicKey = jwt.SigningMethodRS256, k, k.Public()
+	case ed25519.PrivateKey:
+		key.Method, key.PrivateKey, key.PublicKey = jwt.SigningMethodEdDSA, k, k.Public()
+	case *rsa.PublicKey:
+		key.Method, key.PublicKey = jwt.SigningMethodRS256, k
+	case ed25519.PublicKey:
+		key.Method, key.PublicKey = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return key, nil
+}
+
+// Sign signs the claims with the active key and sets the kid header
+func (m *Manager) Sign(claims jwt.Claims) (string, error) {
+	if m.active == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	}
+
+	token := jwt.NewWithClaims(m.active.Method, claims)
+	token.Header["kid"] = m.active.ID
+	return token.SignedString(m.active.PrivateKey)
+}
+
+// Keyfunc resolves the verification key for a token, for use with jwt.Parse
+func (m *Manager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if m.active == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return m.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if key.Retired {
+		return nil, ErrRetiredKey
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.PublicKey, nil
+}
+
+// ValidMethods lists the algorithms accepted by Keyfunc, for jwt.WithValidMethods
+func (m *Manager) ValidMethods() []string {
+	if m.active == nil {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+
+	seen := make(map[string]bool)
+	methods := make([]string, 0, 2)
+	for _, key := range m.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
@@ -1,63 +1,184 @@
 package middleware
 
 import (
-	"fiber-gorm/internal/config"
+	"errors"
+	"fiber-gorm/internal/services"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/rs/zerolog/log"
 )
 
-// JWTAuthMiddleware creates a middleware for protecting routes with JWT
-func JWTAuthMiddleware(cfg *config.Config) fiber.Handler {
+const claimsKey contextKey = "claims"
+
+// APIKeyHeader is an alternative to the Authorization header for API keys
+const APIKeyHeader = "X-API-Key"
+
+// ImpersonatedByHeader is set on responses to impersonated requests and names the acting admin
+const ImpersonatedByHeader = "X-Impersonated-By"
+
+// JWTAuthMiddleware creates a middleware for protecting routes with JWT.
+// When apiKeys is not nil, API keys are accepted as well, either as the
+// bearer token or in the X-API-Key header. Without an Authorization header
+// the access token is read from the access_token cookie of the cookie auth
+// mode, and state-changing requests must then carry a CSRF token.
+func JWTAuthMiddleware(verifier *services.TokenVerifier, apiKeys *services.APIKeyService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if apiKeys != nil {
+			if key := c.Get(APIKeyHeader); key != "" {
+				return authenticateAPIKey(c, apiKeys, key)
+			}
+		}
+
 		// Get the Authorization header
 		authHeader := c.Get("Authorization")
+		var token string
 		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"message": "Authorization header is required",
-			})
+			token = c.Cookies(AccessTokenCookie)
+			if token == "" {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"message": "Authorization header is required",
+				})
+			}
+
+			// Browsers attach cookies to cross-site requests too
+			if !ValidCSRF(c) {
+				return csrfRejected(c)
+			}
+		} else {
+			// Check if the header has the Bearer prefix
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"message": "Invalid authorization format, expected 'Bearer {token}'",
+				})
+			}
+			token = parts[1]
+
+			if apiKeys != nil && services.IsAPIKey(token) {
+				return authenticateAPIKey(c, apiKeys, token)
+			}
 		}
 
-		// Check if the header has the Bearer prefix
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		// Verify the access token
+		claims, err := verifier.VerifyAccessToken(token)
+		if err != nil {
+			if errors.Is(err, services.ErrTokenRevoked) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"message": "Token has been revoked",
+				})
+			}
+			if errors.Is(err, services.ErrDenylistUnavailable) {
+				log.Error().Err(err).Str("request_id", GetRequestID(c)).Msg("Failed to check access token")
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"message": "Failed to verify token",
+				})
+			}
+
+			log.Debug().Err(err).Str("request_id", GetRequestID(c)).Msg("Failed to verify access token")
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"message": "Invalid authorization format, expected 'Bearer {token}'",
+				"message": "Invalid or expired token",
 			})
 		}
 
-		// Extract the token
-		tokenString := parts[1]
+		// Tokens issued to third-party apps only grant the scopes the user consented to
+		claims.RestrictToScope()
 
-		// Parse and validate the token
-		token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid token signing method")
-			}
-			return []byte(cfg.JWTSecret), nil
+		// Make impersonation visible to clients and in the logs
+		if claims.Impersonated() {
+			c.Set(ImpersonatedByHeader, claims.Actor.Subject)
+			log.Info().
+				Str("request_id", GetRequestID(c)).
+				Str("actor_id", claims.Actor.Subject).
+				Str("user_id", claims.Subject).
+				Str("path", c.Path()).
+				Msg("Impersonated request")
+		}
+
+		// Make the claims available to handlers
+		c.Locals(claimsKey, claims)
+
+		return c.Next()
+	}
+}
+
+func authenticateAPIKey(c *fiber.Ctx, apiKeys *services.APIKeyService, key string) error {
+	claims, err := apiKeys.Authenticate(key, c.IP())
+	if err != nil {
+		log.Debug().Err(err).Str("request_id", GetRequestID(c)).Msg("Failed to verify API key")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid or expired API key",
 		})
+	}
 
-		if err != nil {
-			log.Error().Err(err).Str("token", tokenString).Msg("Failed to parse JWT token")
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"message": "Invalid or expired token",
+	c.Locals(claimsKey, claims)
+	return c.Next()
+}
+
+// DenyAPIKeys rejects requests authenticated with an API key, with a token
+// issued to a third-party app, with an impersonation token or by an internal
+// service, for account management routes that need an interactive login by
+// the user themselves. It must run after JWTAuthMiddleware.
+func DenyAPIKeys() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := GetClaims(c)
+		if claims != nil && claims.HasAuthMethod(services.AuthMethodAPIKey) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "This endpoint is not available with an API key",
 			})
 		}
-
-		// Check if token is valid
-		if !token.Valid {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"message": "Invalid token",
+		if claims != nil && claims.ThirdParty() {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "This endpoint is not available to third-party apps",
+			})
+		}
+		if claims != nil && claims.Service() {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "This endpoint is not available to services",
+			})
+		}
+		if claims != nil && claims.Impersonated() {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "This endpoint is not available while impersonating a user",
 			})
 		}
 
-		// Get claims and set user ID in context
-		if claims, ok := token.Claims.(*jwt.RegisteredClaims); ok {
-			c.Locals("userID", claims.Subject)
+		return c.Next()
+	}
+}
+
+// RequireUser rejects requests whose principal is not a user, i.e. OAuth
+// clients using the client credentials grant and internal services, for
+// routes that act on data owned by the user. It must run after the
+// authentication middleware.
+func RequireUser() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := GetClaims(c)
+		if claims == nil || claims.ClientCredentials() || claims.Service() {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "This endpoint is only available to users",
+			})
 		}
 
 		return c.Next()
 	}
 }
+
+// GetClaims returns the claims of the authenticated request, or nil when
+// the request did not pass through JWTAuthMiddleware
+func GetClaims(c *fiber.Ctx) *services.TokenClaims {
+	if claims, ok := c.Locals(claimsKey).(*services.TokenClaims); ok {
+		return claims
+	}
+
+	return nil
+}
+
+// GetUserID returns the ID of the authenticated user, or an empty string
+func GetUserID(c *fiber.Ctx) string {
+	if claims := GetClaims(c); claims != nil {
+		return claims.Subject
+	}
+
+	return ""
+}
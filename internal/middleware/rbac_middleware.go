@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"fiber-gorm/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireRole allows the request only if the authenticated user has one of the given roles.
+// It must run after JWTAuthMiddleware.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := GetClaims(c)
+		if claims == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "Not authenticated",
+			})
+		}
+
+		for _, role := range roles {
+			if claims.Role == role {
+				return c.Next()
+			}
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Insufficient role",
+		})
+	}
+}
+
+// RequirePermission allows the request only if the authenticated user has all of the given permissions.
+// It must run after JWTAuthMiddleware.
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := GetClaims(c)
+		if claims == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "Not authenticated",
+			})
+		}
+
+		for _, permission := range permissions {
+			if !claims.HasPermission(permission) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"message": "Insufficient permissions",
+				})
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireMFA allows the request only if the access token was issued after a
+// completed second factor. It must run after JWTAuthMiddleware.
+func RequireMFA() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := GetClaims(c)
+		if claims == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "Not authenticated",
+			})
+		}
+
+		if !claims.HasAuthMethod(services.AuthMethodOTP) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "Two-factor authentication is required",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// ForUsers applies check to user principals only. Signed services have no
+// role or second factor and are authorized by their permissions alone.
+func ForUsers(check fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if claims := GetClaims(c); claims != nil && claims.Service() {
+			return c.Next()
+		}
+		return check(c)
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"errors"
+	"fiber-gorm/internal/services"
+	"fiber-gorm/pkg/svcauth"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// ServiceAuthMiddleware authenticates HMAC-signed requests from internal
+// services. Requests without the X-Service-Key-Id header are passed to
+// fallback, usually JWTAuthMiddleware, so a route can serve users and
+// services alike; without a fallback they are rejected. Service principals
+// get the permissions configured for their key and are checked by
+// RequirePermission like users.
+func ServiceAuthMiddleware(auth *services.ServiceAuthenticator, fallback fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		keyID := c.Get(svcauth.HeaderKeyID)
+		if keyID == "" {
+			if fallback != nil {
+				return fallback(c)
+			}
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "Signed service request is required",
+			})
+		}
+
+		claims, err := auth.Authenticate(
+			keyID,
+			c.Get(svcauth.HeaderTimestamp),
+			c.Get(svcauth.HeaderNonce),
+			c.Get(svcauth.HeaderSignature),
+			c.Method(),
+			c.OriginalURL(),
+			c.Body(),
+		)
+		if err != nil {
+			log.Warn().Err(err).
+				Str("request_id", GetRequestID(c)).
+				Str("key_id", keyID).
+				Str("ip", c.IP()).
+				Msg("Rejected signed service request")
+
+			message := services.ErrInvalidSignature.Error()
+			if errors.Is(err, services.ErrStaleSignature) || errors.Is(err, services.ErrReplayedSignature) || errors.Is(err, services.ErrIncompleteSignature) {
+				message = err.Error()
+			}
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": message,
+			})
+		}
+
+		c.Locals(claimsKey, claims)
+		return c.Next()
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+	AuditActionAccountLocked   = "account.locked"
+	AuditActionAccountUnlocked = "account.unlocked"
+	AuditActionIPLocked        = "ip.locked"
+
+	AuditActionImpersonationStarted = "impersonation.started"
+	AuditActionImpersonationStopped = "impersonation.stopped"
+)
+
+// AuditLog records a security relevant event. ActorID is the user who caused
+// the event, if any, and TargetID the user it affected.
+type AuditLog struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Action    string     `gorm:"index;not null" json:"action"`
+	ActorID   *uuid.UUID `gorm:"type:uuid;index" json:"actor_id"`
+	TargetID  *uuid.UUID `gorm:"type:uuid;index" json:"target_id"`
+	IP        string     `json:"ip"`
+	Details   string     `json:"details"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+}
+
+func (a *AuditLog) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
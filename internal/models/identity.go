@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Identity links a user to an account at an external OpenID Connect provider
+type Identity struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;index;not null" json:"user_id"`
+	Provider  string    `gorm:"uniqueIndex:idx_identities_provider_subject;not null" json:"provider"`
+	Subject   string    `gorm:"uniqueIndex:idx_identities_provider_subject;not null" json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (i *Identity) BeforeCreate(tx *gorm.DB) (err error) {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// OIDCState is a pending authorization request. It is looked up by the hash
+// of the state parameter and deleted when the provider redirects back.
+type OIDCState struct {
+	StateHash    string     `gorm:"primaryKey"`
+	Provider     string     `gorm:"not null"`
+	Nonce        string     `gorm:"not null"`
+	CodeVerifier string     `gorm:"not null"`
+	LinkUserID   *uuid.UUID `gorm:"type:uuid"`
+	ExpiresAt    time.Time  `gorm:"index;not null"`
+	CreatedAt    time.Time
+}
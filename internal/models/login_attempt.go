@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// LoginAttempt counts recent failed logins for a throttling key,
+// such as an account email or a client IP
+type LoginAttempt struct {
+	Key           string     `gorm:"primaryKey" json:"key"`
+	Failures      int        `gorm:"not null" json:"failures"`
+	WindowStart   time.Time  `gorm:"not null" json:"window_start"`
+	LastFailureAt time.Time  `gorm:"not null" json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Locked reports whether the key is locked out at the given time
+func (a *LoginAttempt) Locked(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
+
+// Expired reports whether the failures are too old to count, either because the
+// counting window has passed or because a lockout has run its course
+func (a *LoginAttempt) Expired(now time.Time, window time.Duration) bool {
+	if a.LockedUntil != nil {
+		return !now.Before(*a.LockedUntil)
+	}
+	return now.After(a.WindowStart.Add(window))
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OAuth grant types supported by the token endpoint
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+// OAuthClient is a third-party application registered to use the API on
+// behalf of users. Its ID is the OAuth client_id. Public clients, such as
+// mobile and single-page apps, have no secret and must use PKCE.
+type OAuthClient struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey" json:"client_id"`
+	Name         string    `gorm:"not null" json:"name"`
+	SecretHash   string    `json:"-"`
+	Public       bool      `json:"public"`
+	RedirectURIs []string  `gorm:"serializer:json" json:"redirect_uris"`
+	GrantTypes   []string  `gorm:"serializer:json" json:"grant_types"`
+	Scopes       []string  `gorm:"serializer:json" json:"scopes"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (c *OAuthClient) BeforeCreate(tx *gorm.DB) (err error) {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// AllowsGrant reports whether the client may use the given grant type
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	for _, g := range c.GrantTypes {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsRedirect reports whether the redirect URI exactly matches a registered one
+func (c *OAuthClient) AllowsRedirect(redirectURI string) bool {
+	for _, uri := range c.RedirectURIs {
+		if uri == redirectURI {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthAuthorizationCode is an authorization code issued to a client. Only a
+// hash of the code is stored. A used code keeps the refresh token family it
+// was exchanged for, so the tokens can be revoked if the code is replayed.
+// RedirectURISent records whether the authorization request named the
+// redirect URI; only then does the token request have to repeat it.
+type OAuthAuthorizationCode struct {
+	CodeHash        string    `gorm:"primaryKey"`
+	ClientID        uuid.UUID `gorm:"type:uuid;not null"`
+	UserID          uuid.UUID `gorm:"type:uuid;not null"`
+	RedirectURI     string    `gorm:"not null"`
+	RedirectURISent bool
+	Scopes          []string   `gorm:"serializer:json"`
+	CodeChallenge   string     `gorm:"not null"`
+	FamilyID        *uuid.UUID `gorm:"type:uuid"`
+	UsedAt          *time.Time
+	ExpiresAt       time.Time `gorm:"index;not null"`
+	CreatedAt       time.Time
+}
+
+// OAuthConsent remembers the scopes a user has granted to a client so the
+// consent step is only shown again when a client asks for more
+type OAuthConsent struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
+	ClientID  uuid.UUID `gorm:"type:uuid;primaryKey" json:"client_id"`
+	Scopes    []string  `gorm:"serializer:json" json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreateOAuthClientPayload struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	Public       bool     `json:"public"`
+	RedirectURIs []string `json:"redirect_uris" validate:"dive,required,url"`
+	GrantTypes   []string `json:"grant_types" validate:"required,min=1,dive,oneof=authorization_code client_credentials refresh_token"`
+	Scopes       []string `json:"scopes" validate:"required,min=1,dive,required"`
+}
+
+// AuthorizeRequest holds the parameters of an authorization request (RFC 6749 section 4.1.1, RFC 7636)
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type" query:"response_type" form:"response_type"`
+	ClientID            string `json:"client_id" query:"client_id" form:"client_id"`
+	RedirectURI         string `json:"redirect_uri" query:"redirect_uri" form:"redirect_uri"`
+	Scope               string `json:"scope" query:"scope" form:"scope"`
+	State               string `json:"state" query:"state" form:"state"`
+	CodeChallenge       string `json:"code_challenge" query:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" query:"code_challenge_method" form:"code_challenge_method"`
+}
+
+// ConsentPayload is the user's answer to an authorization request
+type ConsentPayload struct {
+	AuthorizeRequest
+	Approve bool `json:"approve" form:"approve"`
+}
+
+// OAuthTokenRequest holds the form parameters of a token endpoint request (RFC 6749 section 4)
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// OAuthTokenParams holds the form parameters of the introspection and revocation endpoints (RFC 7662, RFC 7009)
+type OAuthTokenParams struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
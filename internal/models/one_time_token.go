@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Purposes of one-time tokens
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeMagicLink         = "magic_link"
+)
+
+// OneTimeToken records a single-use token sent to a user, such as an
+// email verification link. Only a hash of the token is stored.
+type OneTimeToken struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;index:idx_one_time_tokens_user_purpose;not null" json:"user_id"`
+	Purpose    string     `gorm:"index:idx_one_time_tokens_user_purpose;not null" json:"purpose"`
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (t *OneTimeToken) BeforeCreate(tx *gorm.DB) (err error) {
+	t.ID = uuid.New()
+	return nil
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PasswordHistory keeps the hash of a password a user has set, so recently
+// used passwords can be refused when choosing a new one
+type PasswordHistory struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID       uuid.UUID `gorm:"type:uuid;index;not null" json:"user_id"`
+	PasswordHash string    `gorm:"not null" json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (h *PasswordHistory) BeforeCreate(tx *gorm.DB) (err error) {
+	h.ID = uuid.New()
+	return nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RefreshToken is the server-side record of an issued refresh token.
+// Only a hash of the token is stored. Tokens issued from the same login
+// share a FamilyID so the whole chain can be revoked when reuse is detected.
+// Tokens issued to a third-party app carry its ClientID and granted Scopes.
+type RefreshToken struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	FamilyID    uuid.UUID  `gorm:"type:uuid;index;not null" json:"family_id"`
+	ParentID    *uuid.UUID `gorm:"type:uuid" json:"parent_id"`
+	TokenHash   string     `gorm:"uniqueIndex;not null" json:"-"`
+	AuthMethods string     `json:"auth_methods"`
+	ClientID    *uuid.UUID `gorm:"type:uuid;index" json:"client_id,omitempty"`
+	Scopes      string     `json:"scopes,omitempty"`
+	ExpiresAt   time.Time  `gorm:"index;not null" json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (t *RefreshToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
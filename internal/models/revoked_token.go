@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// RevokedToken is a denylisted access token, kept until it would have expired
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey" json:"jti"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
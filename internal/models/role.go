@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Built-in role names
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// Built-in permission names
+const (
+	PermissionTasksRead  = "tasks:read"
+	PermissionTasksWrite = "tasks:write"
+	PermissionUsersRead  = "users:read"
+	PermissionUsersWrite = "users:write"
+)
+
+// DefaultRoles lists the permissions granted to each built-in role when it is first seeded
+var DefaultRoles = map[string][]string{
+	RoleAdmin: {PermissionTasksRead, PermissionTasksWrite, PermissionUsersRead, PermissionUsersWrite},
+	RoleUser:  {PermissionTasksRead, PermissionTasksWrite},
+}
+
+type Role struct {
+	ID          uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	Name        string       `gorm:"uniqueIndex;not null" json:"name"`
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+type Permission struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	Name      string    `gorm:"uniqueIndex;not null" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AssignRolePayload struct {
+	Role string `json:"role" validate:"required"`
+}
+
+// PermissionNames returns the names of the permissions granted by the role
+func (r *Role) PermissionNames() []string {
+	names := make([]string, 0, len(r.Permissions))
+	for _, p := range r.Permissions {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func (r *Role) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+func (p *Permission) BeforeCreate(tx *gorm.DB) (err error) {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
@@ -7,24 +7,218 @@ import (
 	"gorm.io/gorm"
 )
 
+// Task statuses. Done and cancelled are terminal: a task in one of them is finished.
+const (
+	TaskStatusTodo       = "todo"
+	TaskStatusInProgress = "in_progress"
+	TaskStatusReview     = "review"
+	TaskStatusDone       = "done"
+	TaskStatusBlocked    = "blocked"
+	TaskStatusCancelled  = "cancelled"
+)
+
+// Task priorities
+const (
+	TaskPriorityLow    = "low"
+	TaskPriorityMedium = "medium"
+	TaskPriorityHigh   = "high"
+	TaskPriorityUrgent = "urgent"
+)
+
+// Scopes of an edit to an occurrence of a recurring task
+const (
+	TaskEditScopeThis   = "this"
+	TaskEditScopeFuture = "future"
+)
+
+// TaskStatuses lists every task status
+var TaskStatuses = []string{
+	TaskStatusTodo,
+	TaskStatusInProgress,
+	TaskStatusReview,
+	TaskStatusDone,
+	TaskStatusBlocked,
+	TaskStatusCancelled,
+}
+
+// Task is owned by the user who created it and only visible to them. Tasks
+// are deleted together with their owner. Status and FinishedAt only change
+// through workflow transitions.
 type Task struct {
 	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	User       *User      `json:"-"`
 	Name       string     `json:"name"`
+	Status     string     `gorm:"index;not null;default:todo" json:"status"`
 	FinishedAt *time.Time `json:"finished_at"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
+
+	Priority         string     `gorm:"index;not null;default:medium" json:"priority"`
+	StartAt          *time.Time `json:"start_at"`
+	DueAt            *time.Time `gorm:"index" json:"due_at"`
+	EstimatedMinutes *int       `json:"estimated_minutes"`
+	// ReminderSentAt is set once the due date reminder was sent and cleared when the due date changes
+	ReminderSentAt *time.Time `gorm:"index" json:"-"`
+	// ReminderAttempts counts failed deliveries; the next one is not tried before ReminderRetryAt
+	ReminderAttempts int        `gorm:"not null;default:0" json:"-"`
+	ReminderRetryAt  *time.Time `json:"-"`
+
+	// Every occurrence of a recurring task is a task of its own, and finishing
+	// one creates the next from the series. OccurrenceAt is the due date the
+	// occurrence was scheduled for, which stays put when only its DueAt is moved.
+	SeriesID     *uuid.UUID  `gorm:"type:uuid;uniqueIndex:idx_tasks_series_occurrence" json:"series_id,omitempty"`
+	Series       *TaskSeries `json:"series,omitempty"`
+	OccurrenceAt *time.Time  `gorm:"uniqueIndex:idx_tasks_series_occurrence" json:"occurrence_at,omitempty"`
+	// NextOccurrence is the occurrence created when this one was finished
+	NextOccurrence *Task `gorm:"-" json:"next_occurrence,omitempty"`
+
+	// ParentID makes the task a subtask of another task of the same user
+	ParentID *uuid.UUID `gorm:"type:uuid;index" json:"parent_id"`
+	// Progress rolls up the subtasks of a parent task
+	Progress *TaskProgress `gorm:"-" json:"progress,omitempty"`
+	// BlockedBy lists the open tasks this task waits for, in a plan
+	BlockedBy []uuid.UUID `gorm:"-" json:"blocked_by,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TaskTransition records a status change of a task and who made it
+type TaskTransition struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	TaskID     uuid.UUID `gorm:"type:uuid;index;not null" json:"task_id"`
+	FromStatus string    `gorm:"not null" json:"from_status"`
+	ToStatus   string    `gorm:"not null" json:"to_status"`
+	ActorID    uuid.UUID `gorm:"type:uuid;not null" json:"actor_id"`
+	Comment    string    `json:"comment,omitempty"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// TaskProgress counts the subtasks of a task. Cancelled subtasks do not count.
+type TaskProgress struct {
+	Total   int `json:"total"`
+	Done    int `json:"done"`
+	Percent int `json:"percent"`
+}
+
+// TaskDependency records that a task cannot be done before BlockedByID is.
+// Both tasks belong to the same user, and the dependencies never form a cycle.
+type TaskDependency struct {
+	TaskID      uuid.UUID `gorm:"type:uuid;primaryKey" json:"task_id"`
+	BlockedByID uuid.UUID `gorm:"type:uuid;primaryKey;index" json:"blocked_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TaskSeries holds the recurrence of a recurring task and the fields its
+// next occurrences are created with. RecurrenceRule is an RFC 5545 RRULE
+// evaluated on the wall clock of TimeZone from StartsAt, the first due date.
+type TaskSeries struct {
+	ID               uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID           uuid.UUID `gorm:"type:uuid;index;not null" json:"-"`
+	RecurrenceRule   string    `gorm:"not null" json:"recurrence_rule"`
+	TimeZone         string    `gorm:"not null" json:"recurrence_tz"`
+	StartsAt         time.Time `gorm:"not null" json:"starts_at"`
+	Name             string    `json:"-"`
+	Priority         string    `json:"-"`
+	EstimatedMinutes *int      `json:"-"`
+	// StartLead is how long before the due date an occurrence starts
+	StartLead *time.Duration `json:"-"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
 }
 
 type CreateTaskPayload struct {
-	Name string `json:"name" validate:"required"`
+	Name             string     `json:"name" validate:"required,max=255"`
+	Priority         string     `json:"priority" validate:"omitempty,oneof=low medium high urgent"`
+	StartAt          *time.Time `json:"start_at"`
+	DueAt            *time.Time `json:"due_at"`
+	EstimatedMinutes *int       `json:"estimated_minutes" validate:"omitempty,min=1"`
+	// RecurrenceRule is an RFC 5545 RRULE such as "FREQ=WEEKLY;BYDAY=MO", which needs a due date
+	RecurrenceRule     string `json:"recurrence_rule" validate:"max=500"`
+	RecurrenceTimeZone string `json:"recurrence_tz" validate:"omitempty,timezone"`
+	// ParentID makes the task a subtask of another task
+	ParentID *uuid.UUID `json:"parent_id"`
 }
 
+// UpdateTaskPayload replaces the task's editable fields. The status is
+// changed with a transition. The recurrence of a recurring task can only be
+// changed for all future occurrences.
 type UpdateTaskPayload struct {
-	Name       string     `json:"name" validate:"required"`
-	FinishedAt *time.Time `json:"finished_at"`
+	Name             string     `json:"name" validate:"required,max=255"`
+	Priority         string     `json:"priority" validate:"omitempty,oneof=low medium high urgent"`
+	StartAt          *time.Time `json:"start_at"`
+	DueAt            *time.Time `json:"due_at"`
+	EstimatedMinutes *int       `json:"estimated_minutes" validate:"omitempty,min=1"`
+	// RecurrenceRule is an RFC 5545 RRULE such as "FREQ=WEEKLY;BYDAY=MO",
+	// which needs a due date. Without it the recurrence is left as it is; an
+	// empty rule stops it.
+	RecurrenceRule *string `json:"recurrence_rule" validate:"omitempty,max=500"`
+	// RecurrenceTimeZone is left as it is when empty
+	RecurrenceTimeZone string `json:"recurrence_tz" validate:"omitempty,timezone"`
+	// ParentID makes the task a subtask of another task
+	ParentID *uuid.UUID `json:"parent_id"`
+}
+
+type AddTaskDependencyPayload struct {
+	BlockedByID uuid.UUID `json:"blocked_by_id" validate:"required"`
+}
+
+// TaskPlanQuery selects the tasks to plan by a comma-separated list of IDs
+type TaskPlanQuery struct {
+	IDs string `query:"ids" validate:"required"`
+}
+
+// TaskListQuery holds the query parameters of the task list. Due is
+// "overdue" or "this_week"; weeks start on Monday in the TimeZone, UTC by default.
+type TaskListQuery struct {
+	Status   string `query:"status" validate:"omitempty,oneof=todo in_progress review done blocked cancelled"`
+	Priority string `query:"priority" validate:"omitempty,oneof=low medium high urgent"`
+	Due      string `query:"due" validate:"omitempty,oneof=overdue this_week"`
+	TimeZone string `query:"tz" validate:"omitempty,timezone"`
+}
+
+// TaskFilter narrows a task list. Zero values do not filter.
+type TaskFilter struct {
+	Status   string
+	Priority string
+	// DueAfter and DueBefore select tasks due in [DueAfter, DueBefore)
+	DueAfter  *time.Time
+	DueBefore *time.Time
+	// Unfinished leaves out done and cancelled tasks
+	Unfinished bool
+}
+
+type TransitionTaskPayload struct {
+	Status  string `json:"status" validate:"required,oneof=todo in_progress review done blocked cancelled"`
+	Comment string `json:"comment" validate:"max=500"`
+}
+
+// TerminalTaskStatus reports whether a status ends the task's workflow
+func TerminalTaskStatus(status string) bool {
+	return status == TaskStatusDone || status == TaskStatusCancelled
+}
+
+// Finished reports whether the task has reached a terminal status
+func (t *Task) Finished() bool {
+	return TerminalTaskStatus(t.Status)
 }
 
 func (t *Task) BeforeCreate(tx *gorm.DB) (err error) {
-	t.ID = uuid.New()
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+func (s *TaskSeries) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+func (t *TaskTransition) BeforeCreate(tx *gorm.DB) (err error) {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
 	return nil
 }
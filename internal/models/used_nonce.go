@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// UsedNonce is the nonce of a signed service request, kept until the request
+// timestamp falls outside the accepted clock skew so it cannot be replayed
+type UsedNonce struct {
+	Nonce     string    `gorm:"primaryKey" json:"nonce"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
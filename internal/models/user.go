@@ -8,25 +8,76 @@ import (
 )
 
 type User struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `gorm:"uniqueIndex;not null" json:"email"`
-	Password  string    `json:"-"`
-	Hobby     *string   `json:"hobby"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `gorm:"uniqueIndex;not null" json:"email"`
+	Password        string     `json:"-"`
+	Hobby           *string    `json:"hobby"`
+	RoleID          *uuid.UUID `gorm:"type:uuid;index" json:"role_id"`
+	Role            *Role      `json:"role,omitempty"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TOTPSecret      string     `json:"-"`
+	TOTPEnabledAt   *time.Time `json:"totp_enabled_at"`
+	TOTPLastStep    int64      `json:"-"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 }
 
 type CreateUserPayload struct {
 	Name     string  `json:"name" validate:"required"`
 	Email    string  `json:"email" validate:"required,email"`
-	Password string  `json:"password" validate:"required,min=6"`
+	Password string  `json:"password" validate:"required"`
 	Hobby    *string `json:"hobby"`
 }
 
 type LoginUserPayload struct {
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Password string `json:"password" validate:"required"`
+}
+
+type VerifyEmailPayload struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationPayload struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type MagicLinkPayload struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ConsumeMagicLinkPayload struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ChangePasswordPayload struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+type TOTPCodePayload struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type MFALoginPayload struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// ImpersonatePayload records why an admin is acting as a user
+type ImpersonatePayload struct {
+	Reason string `json:"reason" validate:"required,max=500"`
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
@@ -0,0 +1,137 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"fiber-gorm/internal/config"
+	"fiber-gorm/internal/mailer"
+	"fiber-gorm/pkg/svcauth"
+)
+
+// WebhookKeyID identifies the API in the signature of webhook requests
+const WebhookKeyID = "task-reminders"
+
+// Reminder tells a user that one of their tasks is due soon
+type Reminder struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Email    string    `json:"email"`
+	Name     string    `json:"name"`
+	TaskID   uuid.UUID `json:"task_id"`
+	TaskName string    `json:"task_name"`
+	DueAt    time.Time `json:"due_at"`
+}
+
+// Notifier delivers task reminders
+type Notifier interface {
+	Notify(reminder Reminder) error
+}
+
+// New returns the notifier selected by cfg.TaskReminderNotifier
+func New(cfg config.Config, mail mailer.Mailer) (Notifier, error) {
+	switch cfg.TaskReminderNotifier {
+	case "log":
+		return NewLogNotifier(), nil
+	case "email":
+		return NewEmailNotifier(mail, cfg.AppBaseURL), nil
+	case "webhook":
+		if cfg.TaskReminderWebhookURL == "" {
+			return nil, fmt.Errorf("TASK_REMINDER_WEBHOOK_URL is required for the webhook notifier")
+		}
+		return NewWebhookNotifier(cfg.TaskReminderWebhookURL, []byte(cfg.TaskReminderWebhookSecret)), nil
+	default:
+		return nil, fmt.Errorf("unsupported reminder notifier: %s", cfg.TaskReminderNotifier)
+	}
+}
+
+// LogNotifier writes reminders to the application log and keeps them in
+// memory so tests can inspect what was sent
+type LogNotifier struct {
+	mu   sync.Mutex
+	sent []Reminder
+}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Notify(reminder Reminder) error {
+	n.mu.Lock()
+	n.sent = append(n.sent, reminder)
+	n.mu.Unlock()
+
+	log.Info().
+		Str("user_id", reminder.UserID.String()).
+		Str("task_id", reminder.TaskID.String()).
+		Time("due_at", reminder.DueAt).
+		Msg("Task reminder")
+	return nil
+}
+
+// Sent returns every reminder sent so far
+func (n *LogNotifier) Sent() []Reminder {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Reminder(nil), n.sent...)
+}
+
+// EmailNotifier emails reminders to the task owner
+type EmailNotifier struct {
+	Mailer  mailer.Mailer
+	BaseURL string
+}
+
+func NewEmailNotifier(mail mailer.Mailer, baseURL string) *EmailNotifier {
+	return &EmailNotifier{Mailer: mail, BaseURL: baseURL}
+}
+
+func (n *EmailNotifier) Notify(reminder Reminder) error {
+	return n.Mailer.Send(mailer.Message{
+		To:      reminder.Email,
+		Subject: fmt.Sprintf("Reminder: %s is due soon", reminder.TaskName),
+		Body: fmt.Sprintf("Hi %s,\n\nYour task \"%s\" is due at %s.\n\n%s/tasks/%s\n",
+			reminder.Name, reminder.TaskName, reminder.DueAt.UTC().Format(time.RFC1123), n.BaseURL, reminder.TaskID),
+	})
+}
+
+// WebhookNotifier posts reminders as JSON to a URL. With a secret the
+// requests are signed like service requests (see package svcauth), so the
+// receiver can check they come from this API.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(url string, secret []byte) *WebhookNotifier {
+	client := &http.Client{Timeout: 10 * time.Second}
+	if len(secret) > 0 {
+		client = svcauth.NewClient(WebhookKeyID, secret)
+		client.Timeout = 10 * time.Second
+	}
+	return &WebhookNotifier{URL: url, Client: client}
+}
+
+func (n *WebhookNotifier) Notify(reminder Reminder) error {
+	body, err := json.Marshal(reminder)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.Client.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post reminder: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("reminder webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
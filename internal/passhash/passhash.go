@@ -0,0 +1,245 @@
+// Package passhash hashes and verifies passwords. New hashes use the
+// configured algorithm; hashes made with any supported algorithm or older
+// parameters still verify and are reported as needing a rehash.
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"fiber-gorm/internal/config"
+)
+
+// Algorithm names accepted in configuration
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// ErrUnknownFormat is returned for stored hashes no supported algorithm produced
+var ErrUnknownFormat = errors.New("unknown password hash format")
+
+// Algorithm is one password hashing scheme
+type Algorithm interface {
+	// Hash returns the encoded hash of a password with the current parameters
+	Hash(password string) (string, error)
+	// Recognizes reports whether an encoded hash was produced by this algorithm
+	Recognizes(encoded string) bool
+	// Verify reports whether the password matches an encoded hash of this algorithm
+	Verify(encoded, password string) (bool, error)
+	// Outdated reports whether an encoded hash uses parameters other than the current ones
+	Outdated(encoded string) bool
+}
+
+// Hasher hashes passwords with a preferred algorithm and verifies hashes of
+// every supported algorithm
+type Hasher struct {
+	preferred  Algorithm
+	algorithms []Algorithm
+	dummy      string
+}
+
+// New builds a hasher from configuration. Both argon2id and bcrypt hashes
+// are accepted; PasswordHashAlgorithm selects which one new hashes use.
+func New(cfg config.Config) (*Hasher, error) {
+	argon := &Argon2id{
+		Memory:      uint32(cfg.Argon2Memory),
+		Iterations:  uint32(cfg.Argon2Iterations),
+		Parallelism: uint8(cfg.Argon2Parallelism),
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+	bcryptAlgorithm := &Bcrypt{Cost: cfg.BcryptCost}
+
+	switch cfg.PasswordHashAlgorithm {
+	case AlgorithmArgon2id, "":
+		return NewHasher(argon, bcryptAlgorithm)
+	case AlgorithmBcrypt:
+		return NewHasher(bcryptAlgorithm, argon)
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", cfg.PasswordHashAlgorithm)
+	}
+}
+
+// NewHasher creates a hasher that hashes with preferred and also verifies
+// hashes of the other algorithms
+func NewHasher(preferred Algorithm, others ...Algorithm) (*Hasher, error) {
+	h := &Hasher{
+		preferred:  preferred,
+		algorithms: append([]Algorithm{preferred}, others...),
+	}
+
+	// A hash of a random password, compared against when there is no real
+	// hash so that unknown accounts take as long to reject as wrong passwords
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	dummy, err := preferred.Hash(base64.RawStdEncoding.EncodeToString(buf))
+	if err != nil {
+		return nil, fmt.Errorf("invalid password hash parameters: %w", err)
+	}
+	h.dummy = dummy
+
+	return h, nil
+}
+
+// Hash hashes a password with the preferred algorithm
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+// Verify reports whether the password matches the encoded hash, and whether
+// the hash should be replaced because it uses another algorithm or outdated parameters
+func (h *Hasher) Verify(encoded, password string) (match bool, rehash bool, err error) {
+	for _, algorithm := range h.algorithms {
+		if !algorithm.Recognizes(encoded) {
+			continue
+		}
+
+		match, err = algorithm.Verify(encoded, password)
+		if err != nil || !match {
+			return false, false, err
+		}
+		return true, algorithm != h.preferred || algorithm.Outdated(encoded), nil
+	}
+
+	return false, false, ErrUnknownFormat
+}
+
+// VerifyDummy does the work of verifying a password without a stored hash,
+// so callers can make a missing account indistinguishable by response time
+func (h *Hasher) VerifyDummy(password string) {
+	h.Verify(h.dummy, password)
+}
+
+// Argon2id hashes passwords with argon2id (RFC 9106) into PHC strings such
+// as $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+type Argon2id struct {
+	// Memory is the memory cost in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type argon2Params struct {
+	version     int
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	if a.Memory < 8*uint32(a.Parallelism) || a.Iterations < 1 || a.Parallelism < 1 {
+		return "", errors.New("argon2id requires at least one iteration and thread and 8 KiB of memory per thread")
+	}
+
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a *Argon2id) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (a *Argon2id) Verify(encoded, password string) (bool, error) {
+	params, err := parseArgon2(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+	return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+}
+
+func (a *Argon2id) Outdated(encoded string) bool {
+	params, err := parseArgon2(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params.version != argon2.Version ||
+		params.memory != a.Memory ||
+		params.iterations != a.Iterations ||
+		params.parallelism != a.Parallelism ||
+		uint32(len(params.salt)) != a.SaltLength ||
+		uint32(len(params.key)) != a.KeyLength
+}
+
+func parseArgon2(encoded string) (*argon2Params, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, ErrUnknownFormat
+	}
+
+	var params argon2Params
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &params.version); err != nil {
+		return nil, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id hash: %w", err)
+	}
+	if params.memory == 0 || params.iterations == 0 || params.parallelism == 0 || len(params.key) == 0 {
+		return nil, errors.New("invalid argon2id parameters")
+	}
+
+	return &params, nil
+}
+
+// Bcrypt hashes passwords with bcrypt in the usual $2a$<cost>$ format
+type Bcrypt struct {
+	Cost int
+}
+
+func (b *Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (b *Bcrypt) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (b *Bcrypt) Verify(encoded, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *Bcrypt) Outdated(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != b.Cost
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"fiber-gorm/internal/models"
+)
+
+type APIKeyRepository struct {
+	DB *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{DB: db}
+}
+
+func (r *APIKeyRepository) CreateAPIKey(key *models.APIKey) error {
+	return r.DB.Create(key).Error
+}
+
+func (r *APIKeyRepository) FindAPIKeyByPrefix(prefix string) (*models.APIKey, error) {
+	var key models.APIKey
+	return &key, r.DB.First(&key, "prefix = ?", prefix).Error
+}
+
+// FindAPIKeysByUser returns the user's keys that have not been revoked, newest first
+func (r *APIKeyRepository) FindAPIKeysByUser(userID uuid.UUID) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	return keys, r.DB.Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("created_at DESC").
+		Find(&keys).Error
+}
+
+// RevokeAPIKey revokes a key owned by the user. It reports false when no such active key exists.
+func (r *APIKeyRepository) RevokeAPIKey(id, userID uuid.UUID) (bool, error) {
+	result := r.DB.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *APIKeyRepository) TouchAPIKey(id uuid.UUID, ip string, at time.Time) error {
+	return r.DB.Model(&models.APIKey{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"last_used_at": at, "last_used_ip": ip}).Error
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"fiber-gorm/internal/models"
+)
+
+type AuditLogRepository struct {
+	DB *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) *AuditLogRepository {
+	return &AuditLogRepository{DB: db}
+}
+
+func (r *AuditLogRepository) CreateAuditLog(entry *models.AuditLog) error {
+	return r.DB.Create(entry).Error
+}
+
+// FindRecentAuditLogs returns the newest entries first, optionally filtered by action
+func (r *AuditLogRepository) FindRecentAuditLogs(action string, limit int) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+	query := r.DB.Order("created_at DESC").Limit(limit)
+	if action != "" {
+		query = query.Where("action = ?", action)
+	}
+	return entries, query.Find(&entries).Error
+}
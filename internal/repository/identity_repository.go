@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"fiber-gorm/internal/models"
+)
+
+type IdentityRepository struct {
+	DB *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) *IdentityRepository {
+	return &IdentityRepository{DB: db}
+}
+
+func (r *IdentityRepository) CreateIdentity(identity *models.Identity) error {
+	return r.DB.Create(identity).Error
+}
+
+func (r *IdentityRepository) FindIdentity(provider, subject string) (*models.Identity, error) {
+	var identity models.Identity
+	return &identity, r.DB.First(&identity, "provider = ? AND subject = ?", provider, subject).Error
+}
+
+func (r *IdentityRepository) FindIdentitiesByUser(userID uuid.UUID) ([]models.Identity, error) {
+	var identities []models.Identity
+	return identities, r.DB.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+}
+
+// DeleteIdentity removes an identity owned by the user. It reports false when there is no such identity.
+func (r *IdentityRepository) DeleteIdentity(id, userID uuid.UUID) (bool, error) {
+	result := r.DB.Delete(&models.Identity{}, "id = ? AND user_id = ?", id, userID)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *IdentityRepository) CreateOIDCState(state *models.OIDCState) error {
+	if err := r.DB.Where("expires_at < ?", time.Now()).Delete(&models.OIDCState{}).Error; err != nil {
+		return err
+	}
+	return r.DB.Create(state).Error
+}
+
+// ConsumeOIDCState deletes and returns an unexpired state. It returns
+// gorm.ErrRecordNotFound when the state is unknown, expired or already used.
+func (r *IdentityRepository) ConsumeOIDCState(stateHash string) (*models.OIDCState, error) {
+	var state models.OIDCState
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&state, "state_hash = ? AND expires_at > ?", stateHash, time.Now()).Error; err != nil {
+			return err
+		}
+
+		result := tx.Delete(&models.OIDCState{}, "state_hash = ?", stateHash)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
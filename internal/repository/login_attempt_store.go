@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"fiber-gorm/internal/models"
+)
+
+// LoginAttemptStore keeps failed login counters per throttling key
+type LoginAttemptStore interface {
+	// Get returns the current counters for key, or nil if there are none
+	Get(key string) (*models.LoginAttempt, error)
+	// RecordFailure adds a failure to key, starting a new window when the
+	// previous one is older than window, and returns the updated counters
+	RecordFailure(key string, window time.Duration) (*models.LoginAttempt, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+// NewLoginAttemptStore returns the login attempt store implementation named by store
+func NewLoginAttemptStore(store string, db *gorm.DB) (LoginAttemptStore, error) {
+	switch store {
+	case "memory":
+		return NewMemoryLoginAttemptStore(), nil
+	case "database":
+		return NewGormLoginAttemptStore(db), nil
+	default:
+		return nil, fmt.Errorf("unsupported login attempt store: %s", store)
+	}
+}
+
+// MemoryLoginAttemptStore keeps counters in process memory.
+// It is only suitable for single-instance deployments and tests.
+type MemoryLoginAttemptStore struct {
+	mu      sync.Mutex
+	entries map[string]models.LoginAttempt
+}
+
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{entries: make(map[string]models.LoginAttempt)}
+}
+
+func (s *MemoryLoginAttemptStore) Get(key string) (*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	return &attempt, nil
+}
+
+func (s *MemoryLoginAttemptStore) RecordFailure(key string, window time.Duration) (*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	attempt, ok := s.entries[key]
+	if !ok || attempt.Expired(now, window) {
+		attempt = models.LoginAttempt{Key: key, WindowStart: now}
+	}
+
+	attempt.Failures++
+	attempt.LastFailureAt = now
+	attempt.UpdatedAt = now
+	s.entries[key] = attempt
+	return &attempt, nil
+}
+
+func (s *MemoryLoginAttemptStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt := s.entries[key]
+	attempt.Key = key
+	attempt.LockedUntil = &until
+	attempt.UpdatedAt = time.Now()
+	s.entries[key] = attempt
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// GormLoginAttemptStore stores counters in the database so that every
+// instance sharing it enforces the same limits
+type GormLoginAttemptStore struct {
+	DB *gorm.DB
+}
+
+func NewGormLoginAttemptStore(db *gorm.DB) *GormLoginAttemptStore {
+	return &GormLoginAttemptStore{DB: db}
+}
+
+func (s *GormLoginAttemptStore) Get(key string) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := s.DB.First(&attempt, "key = ?", key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (s *GormLoginAttemptStore) RecordFailure(key string, window time.Duration) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.First(&attempt, "key = ?", key).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			attempt = models.LoginAttempt{Key: key, Failures: 1, WindowStart: now, LastFailureAt: now}
+			return tx.Create(&attempt).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		if attempt.Expired(now, window) {
+			attempt = models.LoginAttempt{Key: key, Failures: 1, WindowStart: now, LastFailureAt: now}
+			return tx.Select("*").Save(&attempt).Error
+		}
+
+		// Increment in the database so concurrent failures are all counted
+		if err := tx.Model(&models.LoginAttempt{}).Where("key = ?", key).Updates(map[string]interface{}{
+			"failures":        gorm.Expr("failures + 1"),
+			"last_failure_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.First(&attempt, "key = ?", key).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (s *GormLoginAttemptStore) Lock(key string, until time.Time) error {
+	return s.DB.Model(&models.LoginAttempt{}).
+		Where("key = ?", key).
+		Update("locked_until", until).Error
+}
+
+func (s *GormLoginAttemptStore) Reset(key string) error {
+	return s.DB.Delete(&models.LoginAttempt{}, "key = ?", key).Error
+}
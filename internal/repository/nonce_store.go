@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"fiber-gorm/internal/models"
+)
+
+// NonceStore remembers the nonces of signed requests to reject replays
+type NonceStore interface {
+	// Use records nonce until expiresAt and reports whether it was unused
+	Use(nonce string, expiresAt time.Time) (bool, error)
+}
+
+// NewNonceStore returns the nonce store implementation named by store
+func NewNonceStore(store string, db *gorm.DB) (NonceStore, error) {
+	switch store {
+	case "memory":
+		return NewMemoryNonceStore(), nil
+	case "database":
+		return NewGormNonceStore(db), nil
+	default:
+		return nil, fmt.Errorf("unsupported nonce store: %s", store)
+	}
+}
+
+// MemoryNonceStore keeps nonces in process memory.
+// It is only suitable for single-instance deployments and tests.
+type MemoryNonceStore struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{entries: make(map[string]time.Time)}
+}
+
+func (s *MemoryNonceStore) Use(nonce string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for n, exp := range s.entries {
+		if now.After(exp) {
+			delete(s.entries, n)
+		}
+	}
+
+	if _, ok := s.entries[nonce]; ok {
+		return false, nil
+	}
+	s.entries[nonce] = expiresAt
+	return true, nil
+}
+
+// GormNonceStore stores nonces in the database so that a request cannot be
+// replayed against another instance
+type GormNonceStore struct {
+	DB *gorm.DB
+}
+
+func NewGormNonceStore(db *gorm.DB) *GormNonceStore {
+	return &GormNonceStore{DB: db}
+}
+
+func (s *GormNonceStore) Use(nonce string, expiresAt time.Time) (bool, error) {
+	if err := s.DB.Where("expires_at < ?", time.Now()).Delete(&models.UsedNonce{}).Error; err != nil {
+		return false, err
+	}
+
+	// The primary key makes concurrent uses of the same nonce insert at most one row
+	result := s.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.UsedNonce{Nonce: nonce, ExpiresAt: expiresAt})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"fiber-gorm/internal/models"
+)
+
+type OAuthRepository struct {
+	DB *gorm.DB
+}
+
+func NewOAuthRepository(db *gorm.DB) *OAuthRepository {
+	return &OAuthRepository{DB: db}
+}
+
+func (r *OAuthRepository) CreateClient(client *models.OAuthClient) error {
+	return r.DB.Create(client).Error
+}
+
+func (r *OAuthRepository) FindClient(id uuid.UUID) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	return &client, r.DB.First(&client, "id = ?", id).Error
+}
+
+func (r *OAuthRepository) FindClients() ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	return clients, r.DB.Order("created_at").Find(&clients).Error
+}
+
+// DeleteClient removes a client with its pending codes and consents. It reports false when there is no such client.
+func (r *OAuthRepository) DeleteClient(id uuid.UUID) (bool, error) {
+	deleted := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.OAuthClient{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected == 1
+
+		if err := tx.Delete(&models.OAuthAuthorizationCode{}, "client_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.OAuthConsent{}, "client_id = ?", id).Error
+	})
+	return deleted, err
+}
+
+func (r *OAuthRepository) CreateAuthorizationCode(code *models.OAuthAuthorizationCode) error {
+	if err := r.DB.Where("expires_at < ?", time.Now()).Delete(&models.OAuthAuthorizationCode{}).Error; err != nil {
+		return err
+	}
+	return r.DB.Create(code).Error
+}
+
+// FindAuthorizationCode returns an unexpired code, used or not
+func (r *OAuthRepository) FindAuthorizationCode(codeHash string) (*models.OAuthAuthorizationCode, error) {
+	var code models.OAuthAuthorizationCode
+	return &code, r.DB.First(&code, "code_hash = ? AND expires_at > ?", codeHash, time.Now()).Error
+}
+
+// MarkAuthorizationCodeUsed records the token family a code was exchanged for.
+// It reports false when the code had already been used, so concurrent exchanges cannot both succeed.
+func (r *OAuthRepository) MarkAuthorizationCodeUsed(codeHash string, familyID uuid.UUID) (bool, error) {
+	result := r.DB.Model(&models.OAuthAuthorizationCode{}).
+		Where("code_hash = ? AND used_at IS NULL", codeHash).
+		Updates(map[string]interface{}{"used_at": time.Now(), "family_id": familyID})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *OAuthRepository) FindConsent(userID, clientID uuid.UUID) (*models.OAuthConsent, error) {
+	var consent models.OAuthConsent
+	return &consent, r.DB.First(&consent, "user_id = ? AND client_id = ?", userID, clientID).Error
+}
+
+// SaveConsent creates or replaces the scopes a user has granted to a client
+func (r *OAuthRepository) SaveConsent(consent *models.OAuthConsent) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scopes", "updated_at"}),
+	}).Create(consent).Error
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"fiber-gorm/internal/models"
+)
+
+type OneTimeTokenRepository struct {
+	DB *gorm.DB
+}
+
+func NewOneTimeTokenRepository(db *gorm.DB) *OneTimeTokenRepository {
+	return &OneTimeTokenRepository{DB: db}
+}
+
+func (r *OneTimeTokenRepository) CreateOneTimeToken(token *models.OneTimeToken) error {
+	return r.DB.Create(token).Error
+}
+
+// FindOneTimeToken returns an unexpired, unused token without consuming it.
+// It returns gorm.ErrRecordNotFound if the token is unknown, expired or already used.
+func (r *OneTimeTokenRepository) FindOneTimeToken(hash string, purpose string) (*models.OneTimeToken, error) {
+	var token models.OneTimeToken
+	err := r.DB.First(&token, "token_hash = ? AND purpose = ? AND consumed_at IS NULL AND expires_at > ?", hash, purpose, time.Now()).Error
+	return &token, err
+}
+
+// ConsumeOneTimeToken marks an unexpired, unused token as consumed and returns it.
+// It returns gorm.ErrRecordNotFound if the token is unknown, expired or already used.
+func (r *OneTimeTokenRepository) ConsumeOneTimeToken(hash string, purpose string) (*models.OneTimeToken, error) {
+	var token models.OneTimeToken
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&token, "token_hash = ? AND purpose = ? AND consumed_at IS NULL AND expires_at > ?", hash, purpose, time.Now()).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.OneTimeToken{}).
+			Where("id = ? AND consumed_at IS NULL", token.ID).
+			Update("consumed_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	return &token, err
+}
+
+// InvalidateOneTimeTokens consumes every outstanding token of a user for the given purpose
+func (r *OneTimeTokenRepository) InvalidateOneTimeTokens(userID uuid.UUID, purpose string) error {
+	return r.DB.Model(&models.OneTimeToken{}).
+		Where("user_id = ? AND purpose = ? AND consumed_at IS NULL", userID, purpose).
+		Update("consumed_at", time.Now()).Error
+}
+
+// CountOneTimeTokensSince counts the tokens issued to a user for a purpose since the given time
+func (r *OneTimeTokenRepository) CountOneTimeTokensSince(userID uuid.UUID, purpose string, since time.Time) (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.OneTimeToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", userID, purpose, since).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"fiber-gorm/internal/models"
+)
+
+type PasswordHistoryRepository struct {
+	DB *gorm.DB
+}
+
+func NewPasswordHistoryRepository(db *gorm.DB) *PasswordHistoryRepository {
+	return &PasswordHistoryRepository{DB: db}
+}
+
+// FindRecentPasswords returns the user's most recent password hashes, newest first
+func (r *PasswordHistoryRepository) FindRecentPasswords(userID uuid.UUID, limit int) ([]models.PasswordHistory, error) {
+	var entries []models.PasswordHistory
+	err := r.DB.Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&entries).Error
+	return entries, err
+}
+
+// AddPassword records a password hash and deletes all but the newest keep entries of the user
+func (r *PasswordHistoryRepository) AddPassword(userID uuid.UUID, hash string, keep int) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.PasswordHistory{UserID: userID, PasswordHash: hash}).Error; err != nil {
+			return err
+		}
+
+		newest := tx.Model(&models.PasswordHistory{}).
+			Select("id").
+			Where("user_id = ?", userID).
+			Order("created_at DESC").
+			Limit(keep)
+		return tx.Where("user_id = ? AND id NOT IN (?)", userID, newest).
+			Delete(&models.PasswordHistory{}).Error
+	})
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"fiber-gorm/internal/models"
+)
+
+type RecoveryCodeRepository struct {
+	DB *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{DB: db}
+}
+
+// ReplaceRecoveryCodes deletes the user's existing codes and stores the given hashes
+func (r *RecoveryCodeRepository) ReplaceRecoveryCodes(userID uuid.UUID, hashes []string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]models.RecoveryCode, 0, len(hashes))
+		for _, hash := range hashes {
+			codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// ConsumeRecoveryCode marks an unused code as used. It reports false if no such code exists.
+func (r *RecoveryCodeRepository) ConsumeRecoveryCode(userID uuid.UUID, hash string) (bool, error) {
+	result := r.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *RecoveryCodeRepository) DeleteRecoveryCodes(userID uuid.UUID) error {
+	return r.DB.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"fiber-gorm/internal/models"
+)
+
+type RoleRepository struct {
+	DB *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) *RoleRepository {
+	return &RoleRepository{DB: db}
+}
+
+func (r *RoleRepository) FindAllRoles() ([]models.Role, error) {
+	var roles []models.Role
+	return roles, r.DB.Preload("Permissions").Find(&roles).Error
+}
+
+func (r *RoleRepository) FindRoleByName(name string) (*models.Role, error) {
+	var role models.Role
+	return &role, r.DB.Preload("Permissions").First(&role, "name = ?", name).Error
+}
+
+// SeedDefaultRoles creates the built-in roles and permissions if they are missing.
+// Permissions of roles that already exist are left untouched so they can be edited.
+// Users without a role are assigned the default user role.
+func (r *RoleRepository) SeedDefaultRoles() error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		for name, permissionNames := range models.DefaultRoles {
+			var role models.Role
+			err := tx.First(&role, "name = ?", name).Error
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			role.Name = name
+			for _, permissionName := range permissionNames {
+				var permission models.Permission
+				if err := tx.FirstOrCreate(&permission, models.Permission{Name: permissionName}).Error; err != nil {
+					return err
+				}
+				role.Permissions = append(role.Permissions, permission)
+			}
+
+			if err := tx.Create(&role).Error; err != nil {
+				return err
+			}
+		}
+
+		var userRole models.Role
+		if err := tx.First(&userRole, "name = ?", models.RoleUser).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.User{}).
+			Where("role_id IS NULL").
+			Update("role_id", userRole.ID).Error
+	})
+}
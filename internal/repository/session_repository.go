@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"fiber-gorm/internal/models"
+)
+
+type SessionRepository struct {
+	DB *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{DB: db}
+}
+
+func (r *SessionRepository) CreateSession(session *models.Session) error {
+	return r.DB.Create(session).Error
+}
+
+// TouchSession records that the session was used again from the given IP
+func (r *SessionRepository) TouchSession(id uuid.UUID, ip string, at time.Time) error {
+	return r.DB.Model(&models.Session{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"last_seen_at": at, "ip": ip}).Error
+}
+
+// activeSessions limits a query to sessions that still hold a usable refresh token
+func activeSessions(db *gorm.DB) *gorm.DB {
+	return db.Where(`EXISTS (
+		SELECT 1 FROM refresh_tokens
+		WHERE refresh_tokens.family_id = sessions.id
+			AND refresh_tokens.used_at IS NULL
+			AND refresh_tokens.revoked_at IS NULL
+			AND refresh_tokens.expires_at > ?
+	)`, time.Now())
+}
+
+// FindActiveSessionsByUser returns the user's sessions that can still be refreshed, most recently used first
+func (r *SessionRepository) FindActiveSessionsByUser(userID uuid.UUID) ([]models.Session, error) {
+	var sessions []models.Session
+	return sessions, r.DB.Scopes(activeSessions).
+		Where("user_id = ?", userID).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+}
+
+func (r *SessionRepository) FindActiveSessionForUser(id, userID uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	return &session, r.DB.Scopes(activeSessions).
+		First(&session, "id = ? AND user_id = ?", id, userID).Error
+}
@@ -1,11 +1,18 @@
 package repository
 
 import (
+	"time"
+
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"fiber-gorm/internal/models"
 )
 
+// TaskRepository stores tasks. Every query is scoped to the owning user, so
+// a task of another user is indistinguishable from one that does not exist;
+// only the reminder scheduler looks at the tasks of all users.
 type TaskRepository struct {
 	DB *gorm.DB
 }
@@ -14,11 +21,308 @@ func NewTaskRepository(db *gorm.DB) *TaskRepository {
 	return &TaskRepository{DB: db}
 }
 
+// CreateTask creates the task, and its series for the first occurrence of a recurring task
 func (r *TaskRepository) CreateTask(task *models.Task) error {
 	return r.DB.Create(task).Error
 }
 
-func (r *TaskRepository) FindAllTasks() ([]models.Task, error) {
+// FindTasksByUser returns the user's tasks matching the filter, oldest first
+func (r *TaskRepository) FindTasksByUser(userID uuid.UUID, filter models.TaskFilter) ([]models.Task, error) {
+	query := r.DB.Where("user_id = ?", userID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Priority != "" {
+		query = query.Where("priority = ?", filter.Priority)
+	}
+	if filter.DueAfter != nil {
+		query = query.Where("due_at >= ?", *filter.DueAfter)
+	}
+	if filter.DueBefore != nil {
+		query = query.Where("due_at < ?", *filter.DueBefore)
+	}
+	if filter.Unfinished {
+		query = query.Where("status NOT IN ?", []string{models.TaskStatusDone, models.TaskStatusCancelled})
+	}
+
+	var tasks []models.Task
+	return tasks, query.Preload("Series").Order("created_at").Find(&tasks).Error
+}
+
+// FindTaskForUser returns a task owned by the user, or gorm.ErrRecordNotFound
+func (r *TaskRepository) FindTaskForUser(id, userID uuid.UUID) (*models.Task, error) {
+	var task models.Task
+	return &task, r.DB.Preload("Series").First(&task, "id = ? AND user_id = ?", id, userID).Error
+}
+
+// UpdateTask saves the task's fields except the workflow ones, which only
+// TransitionTask changes, and the reminder state, which belongs to the scheduler
+func (r *TaskRepository) UpdateTask(task *models.Task) error {
+	return saveTask(r.DB, task)
+}
+
+// DeleteTask deletes the task with its history and dependencies; its subtasks are kept
+func (r *TaskRepository) DeleteTask(task *models.Task) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteTask(tx, task)
+	})
+}
+
+// UpdateTaskSeries saves a series together with some of its occurrences
+// and deletes others in one transaction. A series without an ID is created.
+func (r *TaskRepository) UpdateTaskSeries(series *models.TaskSeries, tasks []*models.Task, removed []models.Task) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if series != nil {
+			if err := tx.Save(series).Error; err != nil {
+				return err
+			}
+		}
+		for i := range removed {
+			if err := deleteTask(tx, &removed[i]); err != nil {
+				return err
+			}
+		}
+		for _, task := range tasks {
+			if err := saveTask(tx, task); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func saveTask(db *gorm.DB, task *models.Task) error {
+	return db.Where("user_id = ?", task.UserID).Omit(clause.Associations, "Status", "FinishedAt", "ReminderSentAt", "ReminderAttempts", "ReminderRetryAt").Save(task).Error
+}
+
+// deleteTask deletes the task with its history and dependencies. Its
+// subtasks become top-level tasks.
+func deleteTask(tx *gorm.DB, task *models.Task) error {
+	if err := tx.Where("task_id = ?", task.ID).Delete(&models.TaskTransition{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("task_id = ? OR blocked_by_id = ?", task.ID, task.ID).Delete(&models.TaskDependency{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Task{}).Where("parent_id = ?", task.ID).UpdateColumn("parent_id", nil).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", task.UserID).Delete(task).Error
+}
+
+// LockTasks runs fn in a transaction, with a repository bound to it, after
+// locking the user's row. Changes to the user's subtasks and dependencies
+// made this way are checked and saved one at a time, so two concurrent
+// changes cannot each pass a cycle check the other invalidates. fn must
+// use only the given repository.
+func (r *TaskRepository) LockTasks(userID uuid.UUID, fn func(repo *TaskRepository) error) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		// A write takes the lock on every database, including SQLite, which
+		// has no SELECT ... FOR UPDATE
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).UpdateColumn("id", gorm.Expr("id")).Error; err != nil {
+			return err
+		}
+		return fn(&TaskRepository{DB: tx})
+	})
+}
+
+// createOccurrence creates the next occurrence of a recurring task. It
+// reports false when the series already has an occurrence at that time.
+func createOccurrence(tx *gorm.DB, task *models.Task) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(task)
+	return result.RowsAffected == 1, result.Error
+}
+
+// FindLaterOccurrences returns the user's unfinished occurrences of a
+// series that are scheduled after the given time
+func (r *TaskRepository) FindLaterOccurrences(userID, seriesID uuid.UUID, after time.Time) ([]models.Task, error) {
 	var tasks []models.Task
-	return tasks, r.DB.Find(&tasks).Error
+	return tasks, r.DB.
+		Where("user_id = ? AND series_id = ? AND occurrence_at > ?", userID, seriesID, after).
+		Where("status NOT IN ?", []string{models.TaskStatusDone, models.TaskStatusCancelled}).
+		Order("occurrence_at").
+		Find(&tasks).Error
+}
+
+// TransitionTask moves the task from the status it was loaded with to
+// transition.ToStatus and records the transition. It reports false, without
+// changing anything, when the task's status was changed in the meantime.
+// A next occurrence, if given, is created in the same transaction unless
+// the series already has it, and set as the task's NextOccurrence.
+func (r *TaskRepository) TransitionTask(task *models.Task, transition *models.TaskTransition, next *models.Task) (bool, error) {
+	moved, created := false, false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Task{}).
+			Where("id = ? AND user_id = ? AND status = ?", task.ID, task.UserID, transition.FromStatus).
+			Updates(map[string]interface{}{
+				"status":      transition.ToStatus,
+				"finished_at": task.FinishedAt,
+				"updated_at":  transition.CreatedAt,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		moved = true
+		if err := tx.Create(transition).Error; err != nil {
+			return err
+		}
+
+		if next == nil {
+			return nil
+		}
+		var err error
+		created, err = createOccurrence(tx, next)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if moved {
+		task.Status = transition.ToStatus
+		task.UpdatedAt = transition.CreatedAt
+	}
+	if created {
+		task.NextOccurrence = next
+	}
+	return moved, nil
+}
+
+// FindTaskTransitions returns the status history of a task, oldest first
+func (r *TaskRepository) FindTaskTransitions(taskID uuid.UUID) ([]models.TaskTransition, error) {
+	var transitions []models.TaskTransition
+	return transitions, r.DB.Where("task_id = ?", taskID).Order("created_at").Find(&transitions).Error
+}
+
+// FindTasksToRemind returns unfinished tasks of all users that are due
+// after now but before the given time and have not been reminded of,
+// soonest first. Tasks that are already overdue are not reminded of.
+// Reminders that failed are left out until their retry time, and those that
+// failed maxAttempts times for good.
+func (r *TaskRepository) FindTasksToRemind(dueBefore, now time.Time, maxAttempts, limit int) ([]models.Task, error) {
+	var tasks []models.Task
+	return tasks, r.DB.
+		Where("due_at > ? AND due_at < ? AND reminder_sent_at IS NULL", now, dueBefore).
+		Where("reminder_attempts < ? AND (reminder_retry_at IS NULL OR reminder_retry_at <= ?)", maxAttempts, now).
+		Where("status NOT IN ?", []string{models.TaskStatusDone, models.TaskStatusCancelled}).
+		Order("due_at").
+		Limit(limit).
+		Find(&tasks).Error
+}
+
+// ClaimReminder marks the task's reminder as sent. It reports false when
+// the reminder was already claimed, e.g. by another instance.
+func (r *TaskRepository) ClaimReminder(id uuid.UUID, at time.Time) (bool, error) {
+	result := r.DB.Model(&models.Task{}).
+		Where("id = ? AND reminder_sent_at IS NULL", id).
+		UpdateColumn("reminder_sent_at", at)
+	return result.RowsAffected == 1, result.Error
+}
+
+// ResetReminder clears the task's reminder state, including failed
+// attempts, so it is reminded of again
+func (r *TaskRepository) ResetReminder(id uuid.UUID) error {
+	return r.DB.Model(&models.Task{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"reminder_sent_at":  nil,
+			"reminder_attempts": 0,
+			"reminder_retry_at": nil,
+		}).Error
+}
+
+// DeferReminder releases a claimed reminder whose delivery failed, recording
+// the number of attempts and when to try again
+func (r *TaskRepository) DeferReminder(id uuid.UUID, attempts int, retryAt time.Time) error {
+	return r.DB.Model(&models.Task{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"reminder_sent_at":  nil,
+			"reminder_attempts": attempts,
+			"reminder_retry_at": retryAt,
+		}).Error
+}
+
+// FindTasksByIDs returns the user's tasks with the given IDs, leaving out unknown ones
+func (r *TaskRepository) FindTasksByIDs(userID uuid.UUID, ids []uuid.UUID) ([]models.Task, error) {
+	var tasks []models.Task
+	return tasks, r.DB.Where("user_id = ? AND id IN ?", userID, ids).Order("created_at").Find(&tasks).Error
+}
+
+// FindSubtasks returns the subtasks of one of the user's tasks, oldest first
+func (r *TaskRepository) FindSubtasks(userID, parentID uuid.UUID) ([]models.Task, error) {
+	var tasks []models.Task
+	return tasks, r.DB.Preload("Series").
+		Where("user_id = ? AND parent_id = ?", userID, parentID).
+		Order("created_at").
+		Find(&tasks).Error
+}
+
+// CountSubtasks returns the progress of the subtasks of the given tasks,
+// leaving out tasks without subtasks
+func (r *TaskRepository) CountSubtasks(userID uuid.UUID, parentIDs []uuid.UUID) (map[uuid.UUID]*models.TaskProgress, error) {
+	var rows []struct {
+		ParentID uuid.UUID
+		Status   string
+		Count    int
+	}
+	err := r.DB.Model(&models.Task{}).
+		Select("parent_id, status, COUNT(*) AS count").
+		Where("user_id = ? AND parent_id IN ?", userID, parentIDs).
+		Group("parent_id, status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	progress := make(map[uuid.UUID]*models.TaskProgress)
+	for _, row := range rows {
+		if row.Status == models.TaskStatusCancelled {
+			continue
+		}
+		p, ok := progress[row.ParentID]
+		if !ok {
+			p = &models.TaskProgress{}
+			progress[row.ParentID] = p
+		}
+		p.Total += row.Count
+		if row.Status == models.TaskStatusDone {
+			p.Done += row.Count
+		}
+	}
+	for _, p := range progress {
+		p.Percent = p.Done * 100 / p.Total
+	}
+	return progress, nil
+}
+
+// FindDependencies returns the dependencies between the user's tasks
+func (r *TaskRepository) FindDependencies(userID uuid.UUID) ([]models.TaskDependency, error) {
+	var dependencies []models.TaskDependency
+	return dependencies, r.DB.
+		Joins("JOIN tasks ON tasks.id = task_dependencies.task_id").
+		Where("tasks.user_id = ?", userID).
+		Find(&dependencies).Error
+}
+
+// FindBlockers returns the tasks the task is blocked by
+func (r *TaskRepository) FindBlockers(task *models.Task) ([]models.Task, error) {
+	var tasks []models.Task
+	return tasks, r.DB.
+		Where("user_id = ? AND id IN (?)", task.UserID,
+			r.DB.Model(&models.TaskDependency{}).Select("blocked_by_id").Where("task_id = ?", task.ID)).
+		Order("created_at").
+		Find(&tasks).Error
+}
+
+// CreateDependency records a dependency; recording an existing one again does nothing
+func (r *TaskRepository) CreateDependency(dependency *models.TaskDependency) error {
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(dependency).Error
+}
+
+// DeleteDependency removes a dependency. It reports false when there was none.
+func (r *TaskRepository) DeleteDependency(taskID, blockedByID uuid.UUID) (bool, error) {
+	result := r.DB.Where("task_id = ? AND blocked_by_id = ?", taskID, blockedByID).Delete(&models.TaskDependency{})
+	return result.RowsAffected == 1, result.Error
 }
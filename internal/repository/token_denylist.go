@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"fiber-gorm/internal/models"
+)
+
+// TokenDenylist records revoked access token IDs until they expire
+type TokenDenylist interface {
+	Add(jti string, expiresAt time.Time) error
+	Contains(jti string) (bool, error)
+}
+
+// NewTokenDenylist returns the denylist implementation named by store
+func NewTokenDenylist(store string, db *gorm.DB) (TokenDenylist, error) {
+	switch store {
+	case "memory":
+		return NewMemoryTokenDenylist(), nil
+	case "database":
+		return NewGormTokenDenylist(db), nil
+	default:
+		return nil, fmt.Errorf("unsupported token denylist store: %s", store)
+	}
+}
+
+// MemoryTokenDenylist keeps revoked tokens in process memory.
+// It is only suitable for single-instance deployments and tests.
+type MemoryTokenDenylist struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+func NewMemoryTokenDenylist() *MemoryTokenDenylist {
+	return &MemoryTokenDenylist{entries: make(map[string]time.Time)}
+}
+
+func (d *MemoryTokenDenylist) Add(jti string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Drop entries whose tokens have expired on their own
+	now := time.Now()
+	for id, exp := range d.entries {
+		if now.After(exp) {
+			delete(d.entries, id)
+		}
+	}
+
+	d.entries[jti] = expiresAt
+	return nil
+}
+
+func (d *MemoryTokenDenylist) Contains(jti string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	exp, ok := d.entries[jti]
+	return ok && time.Now().Before(exp), nil
+}
+
+// GormTokenDenylist stores revoked tokens in the database so that every
+// instance sharing it sees the same revocations
+type GormTokenDenylist struct {
+	DB *gorm.DB
+}
+
+func NewGormTokenDenylist(db *gorm.DB) *GormTokenDenylist {
+	return &GormTokenDenylist{DB: db}
+}
+
+func (d *GormTokenDenylist) Add(jti string, expiresAt time.Time) error {
+	if err := d.DB.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+
+	return d.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+func (d *GormTokenDenylist) Contains(jti string) (bool, error) {
+	var count int64
+	err := d.DB.Model(&models.RevokedToken{}).
+		Where("jti = ? AND expires_at > ?", jti, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -4,6 +4,7 @@ import (
 	"fiber-gorm/internal/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepository struct {
@@ -15,28 +16,83 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 }
 
 func (r *UserRepository) CreateUser(user *models.User) error {
-	return r.DB.Create(user).Error
+	return r.DB.Omit(clause.Associations).Create(user).Error
 }
 
 func (r *UserRepository) FindAllUsers() ([]models.User, error) {
 	var users []models.User
-	return users, r.DB.Find(&users).Error
+	return users, r.DB.Preload("Role").Find(&users).Error
 }
 
 func (r *UserRepository) FindUserById(id string) (*models.User, error) {
 	var user models.User
-	return &user, r.DB.Where("id = ?", id).First(&user).Error
+	return &user, r.DB.Preload("Role.Permissions").Where("id = ?", id).First(&user).Error
 }
 
 func (r *UserRepository) FindUserByEmail(email string) (*models.User, error) {
 	var user models.User
-	return &user, r.DB.First(&user, "email = ?", email).Error
+	return &user, r.DB.Preload("Role.Permissions").First(&user, "email = ?", email).Error
 }
 
 func (r *UserRepository) UpdateUser(user *models.User) error {
-	return r.DB.Save(user).Error
+	return r.DB.Omit(clause.Associations).Save(user).Error
 }
 
+// DeleteUser deletes the user together with the tasks they own and their
+// history, and everything that signs in as or acts for the user: refresh
+// tokens, sessions, API keys, linked identities, recovery codes, password
+// history, one-time tokens, and OAuth consents and authorization codes.
 func (r *UserRepository) DeleteUser(user *models.User) error {
-	return r.DB.Delete(user).Error
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		tasks := tx.Model(&models.Task{}).Select("id").Where("user_id = ?", user.ID)
+		if err := tx.Where("task_id IN (?)", tasks).Delete(&models.TaskTransition{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id IN (?)", tasks).Delete(&models.TaskDependency{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Task{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.TaskSeries{}).Error; err != nil {
+			return err
+		}
+
+		owned := []interface{}{
+			&models.RefreshToken{},
+			&models.Session{},
+			&models.APIKey{},
+			&models.Identity{},
+			&models.RecoveryCode{},
+			&models.PasswordHistory{},
+			&models.OneTimeToken{},
+			&models.OAuthConsent{},
+			&models.OAuthAuthorizationCode{},
+		}
+		for _, model := range owned {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("link_user_id = ?", user.ID).Delete(&models.OIDCState{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+}
+
+// AdvanceTOTPStep records the time step of an accepted TOTP code. It reports
+// false if a code from the same or a later step was already accepted.
+func (r *UserRepository) AdvanceTOTPStep(user *models.User, step int64) (bool, error) {
+	result := r.DB.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	if result.RowsAffected == 1 {
+		user.TOTPLastStep = step
+	}
+	return result.RowsAffected == 1, nil
 }
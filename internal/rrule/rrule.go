@@ -0,0 +1,440 @@
+// Package rrule implements the subset of RFC 5545 recurrence rules used by
+// recurring tasks: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT,
+// UNTIL, BYDAY (with ordinals for MONTHLY and YEARLY), BYMONTHDAY and
+// BYMONTH. Weeks start on Monday. With YEARLY, BYDAY and BYMONTHDAY select
+// days within the BYMONTH months, which they require, so BYDAY ordinals
+// count within a month and range from -5 to 5. Combinations whose RFC 5545
+// meaning differs from this, such as WEEKLY with BYMONTHDAY, are rejected.
+//
+// Occurrences are computed on the wall clock of a time zone, so a task due
+// at 09:00 stays at 09:00 local time across daylight saving changes.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequencies
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+	Yearly  = "YEARLY"
+)
+
+// maxPeriods bounds the search for occurrences of rules that never match
+const maxPeriods = 100000
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// WeekdayNum is a BYDAY entry. N selects the Nth (or, negative, Nth last)
+// such weekday of the month or year; zero means every such weekday.
+type WeekdayNum struct {
+	Day time.Weekday
+	N   int
+}
+
+// Rule is a parsed recurrence rule
+type Rule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+
+	until *until
+}
+
+// until is the UNTIL limit. Floating and date-only values are resolved in
+// the time zone the occurrences are computed in.
+type until struct {
+	utc      time.Time
+	floating bool
+	dateOnly bool
+}
+
+// Parse parses a recurrence rule such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10".
+// An "RRULE:" prefix is accepted.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, errors.New("empty recurrence rule")
+	}
+
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(value)
+			switch rule.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				return nil, fmt.Errorf("unsupported recurrence frequency %q", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = positiveInt(value, "INTERVAL")
+		case "COUNT":
+			rule.Count, err = positiveInt(value, "COUNT")
+		case "UNTIL":
+			rule.until, err = parseUntil(value)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseInts(value, "BYMONTHDAY", -31, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseInts(value, "BYMONTH", 1, 12)
+			for _, m := range months {
+				rule.ByMonth = append(rule.ByMonth, time.Month(m))
+			}
+		case "WKST":
+			if strings.ToUpper(value) != "MO" {
+				return nil, errors.New("only WKST=MO is supported")
+			}
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("recurrence rule needs a FREQ")
+	}
+	if rule.Count > 0 && rule.until != nil {
+		return nil, errors.New("COUNT and UNTIL cannot be combined")
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != Monthly && rule.Freq != Yearly {
+			return nil, errors.New("BYDAY ordinals are only supported with MONTHLY and YEARLY")
+		}
+	}
+	if rule.Freq == Weekly && len(rule.ByMonthDay) > 0 {
+		return nil, errors.New("BYMONTHDAY is not supported with WEEKLY")
+	}
+	// RFC 5545 spreads these over the whole year, which is not supported
+	if rule.Freq == Yearly && len(rule.ByMonth) == 0 && (len(rule.ByDay) > 0 || len(rule.ByMonthDay) > 0) {
+		return nil, errors.New("BYDAY and BYMONTHDAY are only supported with YEARLY together with BYMONTH")
+	}
+
+	return rule, nil
+}
+
+func positiveInt(value, name string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return n, nil
+}
+
+// parseInts parses a list of non-zero integers in [min, max]
+func parseInts(value, name string, min, max int) ([]int, error) {
+	var values []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("invalid %s %q", name, item)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, item := range strings.Split(strings.ToUpper(value), ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY %q", item)
+		}
+		day, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid BYDAY %q", item)
+		}
+
+		n := 0
+		if prefix := item[:len(item)-2]; prefix != "" {
+			var err error
+			n, err = strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("invalid BYDAY %q", item)
+			}
+		}
+		days = append(days, WeekdayNum{Day: day, N: n})
+	}
+	return days, nil
+}
+
+func parseUntil(value string) (*until, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return &until{utc: t}, nil
+	}
+	if t, err := time.Parse("20060102T150405", value); err == nil {
+		return &until{utc: t, floating: true}, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		return &until{utc: t, floating: true, dateOnly: true}, nil
+	}
+	return nil, fmt.Errorf("invalid UNTIL %q", value)
+}
+
+// limit returns the last instant an occurrence may fall on
+func (u *until) limit(loc *time.Location) time.Time {
+	if !u.floating {
+		return u.utc
+	}
+
+	t := time.Date(u.utc.Year(), u.utc.Month(), u.utc.Day(), u.utc.Hour(), u.utc.Minute(), u.utc.Second(), 0, loc)
+	if u.dateOnly {
+		// A date includes the whole day
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t
+}
+
+// Next returns the first occurrence after the given time of the series
+// that starts at dtstart, computed in loc. It reports false when the series
+// has ended. As in RFC 5545, the start is always the first occurrence and
+// counts toward COUNT, even when the rule does not match it.
+func (r *Rule) Next(dtstart, after time.Time, loc *time.Location) (time.Time, bool) {
+	var next time.Time
+	found := false
+	r.each(dtstart, loc, func(occurrence time.Time) bool {
+		if occurrence.After(after) {
+			next, found = occurrence, true
+			return false
+		}
+		return true
+	})
+	return next, found
+}
+
+// CountBefore returns how many occurrences of the series that starts at
+// dtstart, computed in loc, fall before the given time
+func (r *Rule) CountBefore(dtstart, before time.Time, loc *time.Location) int {
+	count := 0
+	r.each(dtstart, loc, func(occurrence time.Time) bool {
+		if !occurrence.Before(before) {
+			return false
+		}
+		count++
+		return true
+	})
+	return count
+}
+
+// each calls fn with the occurrences of the series in order until fn
+// returns false or the series ends
+func (r *Rule) each(dtstart time.Time, loc *time.Location, fn func(time.Time) bool) {
+	// Recurrence rules have a precision of seconds
+	start := dtstart.In(loc).Truncate(time.Second)
+	var limit time.Time
+	if r.until != nil {
+		limit = r.until.limit(loc)
+	}
+
+	if !fn(start) {
+		return
+	}
+
+	count := 1
+	for period := 0; period < maxPeriods; period++ {
+		for _, candidate := range r.candidates(start, period) {
+			if !candidate.After(start) {
+				continue
+			}
+			if r.until != nil && candidate.After(limit) {
+				return
+			}
+
+			count++
+			if r.Count > 0 && count > r.Count {
+				return
+			}
+			if !fn(candidate) {
+				return
+			}
+		}
+	}
+}
+
+// WithCount returns the rule text with its COUNT replaced by count
+func WithCount(rule string, count int) string {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:"), ";")
+	for i, part := range parts {
+		if name, _, _ := strings.Cut(part, "="); strings.EqualFold(name, "COUNT") {
+			parts[i] = "COUNT=" + strconv.Itoa(count)
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// candidates returns the sorted occurrences in the nth period of the series,
+// at the wall clock time of start
+func (r *Rule) candidates(start time.Time, n int) []time.Time {
+	loc := start.Location()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, loc)
+	}
+
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		day := at(start.Year(), start.Month(), start.Day()+n*r.Interval)
+		if r.matchesMonth(day.Month()) && r.matchesWeekday(day.Weekday()) && r.matchesMonthDay(day) {
+			days = append(days, day)
+		}
+
+	case Weekly:
+		monday := start.Day() - (int(start.Weekday())+6)%7 + 7*n*r.Interval
+		weekdays := []time.Weekday{start.Weekday()}
+		if len(r.ByDay) > 0 {
+			weekdays = weekdays[:0]
+			for _, d := range r.ByDay {
+				weekdays = append(weekdays, d.Day)
+			}
+		}
+		for _, weekday := range weekdays {
+			day := at(start.Year(), start.Month(), monday+(int(weekday)+6)%7)
+			if r.matchesMonth(day.Month()) {
+				days = append(days, day)
+			}
+		}
+
+	case Monthly:
+		first := at(start.Year(), start.Month()+time.Month(n*r.Interval), 1)
+		if r.matchesMonth(first.Month()) {
+			days = r.daysInMonth(first, start.Day(), at)
+		}
+
+	case Yearly:
+		year := start.Year() + n*r.Interval
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{start.Month()}
+		}
+		for _, month := range months {
+			days = append(days, r.daysInMonth(at(year, month, 1), start.Day(), at)...)
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return dedupe(days)
+}
+
+// daysInMonth returns the days of the month starting at first selected by
+// BYMONTHDAY or BYDAY, or the given day of the month without either. Days
+// that do not exist in the month, such as the 31st of April, are skipped.
+func (r *Rule) daysInMonth(first time.Time, defaultDay int, at func(int, time.Month, int) time.Time) []time.Time {
+	year, month := first.Year(), first.Month()
+	length := at(year, month+1, 0).Day()
+
+	var days []time.Time
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if defaultDay <= length {
+			days = append(days, at(year, month, defaultDay))
+		}
+		return days
+	}
+
+	for _, d := range r.ByMonthDay {
+		if d < 0 {
+			d = length + d + 1
+		}
+		if d >= 1 && d <= length && (len(r.ByDay) == 0 || r.matchesByDay(at(year, month, d), length)) {
+			days = append(days, at(year, month, d))
+		}
+	}
+
+	if len(r.ByMonthDay) == 0 {
+		for d := 1; d <= length; d++ {
+			if day := at(year, month, d); r.matchesByDay(day, length) {
+				days = append(days, day)
+			}
+		}
+	}
+	return days
+}
+
+// matchesByDay reports whether a day of a month with the given length is selected by BYDAY
+func (r *Rule) matchesByDay(day time.Time, length int) bool {
+	for _, d := range r.ByDay {
+		if d.Day != day.Weekday() {
+			continue
+		}
+		nth := (day.Day()-1)/7 + 1
+		nthLast := -((length-day.Day())/7 + 1)
+		if d.N == 0 || d.N == nth || d.N == nthLast {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonth(month time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesWeekday(weekday time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByDay {
+		if d.Day == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	length := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+	for _, d := range r.ByMonthDay {
+		if d == day.Day() || length+d+1 == day.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func dedupe(days []time.Time) []time.Time {
+	out := days[:0]
+	for i, day := range days {
+		if i == 0 || !day.Equal(days[i-1]) {
+			out = append(out, day)
+		}
+	}
+	return out
+}
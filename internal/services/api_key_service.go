@@ -0,0 +1,206 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"fiber-gorm/internal/config"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/repository"
+)
+
+// APIKeyPrefix starts every API key so they are easy to recognise, e.g. by secret scanners
+const APIKeyPrefix = "fgk_"
+
+const (
+	apiKeyLookupLength = 8
+	// apiKeyTouchInterval limits how often last-used details are written
+	apiKeyTouchInterval = time.Minute
+)
+
+// Error types for API keys
+var (
+	ErrInvalidAPIKey    = errors.New("Invalid or expired API key")
+	ErrAPIKeyNotFound   = errors.New("API key not found")
+	ErrInvalidScope     = errors.New("Scopes must be permissions you hold")
+	ErrInvalidKeyExpiry = errors.New("Expiry must be in the future")
+)
+
+var apiKeyLookupEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// APIKeyService manages personal access tokens and authenticates requests made with them
+type APIKeyService struct {
+	Cfg      config.Config
+	Repo     *repository.APIKeyRepository
+	UserRepo *repository.UserRepository
+}
+
+func NewAPIKeyService(cfg config.Config, repo *repository.APIKeyRepository, userRepo *repository.UserRepository) *APIKeyService {
+	return &APIKeyService{
+		Cfg:      cfg,
+		Repo:     repo,
+		UserRepo: userRepo,
+	}
+}
+
+// IsAPIKey reports whether a credential looks like an API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// CreateAPIKey creates a key for the user and returns the full key, which is not stored
+func (s *APIKeyService) CreateAPIKey(userID string, payload *models.CreateAPIKeyPayload) (string, *models.APIKey, error) {
+	user, err := s.UserRepo.FindUserById(userID)
+	if err != nil {
+		return "", nil, ErrUserNotFound
+	}
+
+	var granted []string
+	if user.Role != nil {
+		granted = user.Role.PermissionNames()
+	}
+	for _, scope := range payload.Scopes {
+		if !containsString(granted, scope) {
+			return "", nil, ErrInvalidScope
+		}
+	}
+
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+		return "", nil, ErrInvalidKeyExpiry
+	}
+
+	lookup := make([]byte, 5)
+	if _, err := rand.Read(lookup); err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	secret, err := generateToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	prefix := APIKeyPrefix + apiKeyLookupEncoding.EncodeToString(lookup)
+	raw := prefix + "_" + secret
+
+	key := &models.APIKey{
+		UserID:    user.ID,
+		Name:      payload.Name,
+		Prefix:    prefix,
+		KeyHash:   hashToken(raw),
+		Scopes:    payload.Scopes,
+		ExpiresAt: payload.ExpiresAt,
+	}
+	if err := s.Repo.CreateAPIKey(key); err != nil {
+		return "", nil, fmt.Errorf("failed to store API key: %w", err)
+	}
+
+	log.Info().Str("user_id", user.ID.String()).Str("prefix", prefix).Msg("API key created")
+	return raw, key, nil
+}
+
+// ListAPIKeys returns the user's keys that have not been revoked
+func (s *APIKeyService) ListAPIKeys(userID string) ([]models.APIKey, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return s.Repo.FindAPIKeysByUser(id)
+}
+
+// RevokeAPIKey revokes one of the user's keys
+func (s *APIKeyService) RevokeAPIKey(userID, keyID string) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	kid, err := uuid.Parse(keyID)
+	if err != nil {
+		return ErrAPIKeyNotFound
+	}
+
+	revoked, err := s.Repo.RevokeAPIKey(kid, uid)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// Authenticate verifies an API key and returns claims equivalent to an access
+// token. Permissions are the key's scopes limited to what the owner's role
+// currently grants, so demoting a user also narrows their keys.
+func (s *APIKeyService) Authenticate(raw, ip string) (*TokenClaims, error) {
+	if !IsAPIKey(raw) || len(raw) < len(APIKeyPrefix)+apiKeyLookupLength+1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	prefix := raw[:len(APIKeyPrefix)+apiKeyLookupLength]
+	key, err := s.Repo.FindAPIKeyByPrefix(prefix)
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(raw)), []byte(key.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if !key.Active(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.UserRepo.FindUserById(key.UserID.String())
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval || key.LastUsedIP != ip {
+		if err := s.Repo.TouchAPIKey(key.ID, ip, now); err != nil {
+			log.Error().Err(err).Str("prefix", key.Prefix).Msg("Failed to record API key use")
+		}
+	}
+
+	claims := &TokenClaims{
+		Type:          TokenTypeAccess,
+		Email:         user.Email,
+		Username:      user.Name,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		AuthMethods:   []string{AuthMethodAPIKey},
+		APIKeyID:      key.ID.String(),
+	}
+	claims.Subject = user.ID.String()
+	if user.Role != nil {
+		claims.Role = user.Role.Name
+		for _, scope := range key.Scopes {
+			if containsString(user.Role.PermissionNames(), scope) {
+				claims.Permissions = append(claims.Permissions, scope)
+			}
+		}
+	}
+
+	// Unverified accounts get no permissions until they confirm their email
+	if !claims.EmailVerified && s.Cfg.EmailVerificationPolicy == VerificationPolicyRestrict {
+		claims.Permissions = nil
+	}
+
+	return claims, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/repository"
+)
+
+// maxAuditLogs caps how many entries a single listing returns
+const maxAuditLogs = 200
+
+// AuditService records security relevant events in the log and the audit table
+type AuditService struct {
+	Repo *repository.AuditLogRepository
+}
+
+func NewAuditService(repo *repository.AuditLogRepository) *AuditService {
+	return &AuditService{Repo: repo}
+}
+
+// Record writes an audit entry. Failures to persist are logged rather than
+// returned so that auditing never breaks the operation being audited.
+func (s *AuditService) Record(action string, actorID, targetID *uuid.UUID, ip, details string) {
+	entry := &models.AuditLog{
+		Action:   action,
+		ActorID:  actorID,
+		TargetID: targetID,
+		IP:       ip,
+		Details:  details,
+	}
+
+	event := log.Warn().Str("audit", action).Str("ip", ip).Str("details", details)
+	if actorID != nil {
+		event = event.Str("actor_id", actorID.String())
+	}
+	if targetID != nil {
+		event = event.Str("target_id", targetID.String())
+	}
+	event.Msg("Audit event")
+
+	if err := s.Repo.CreateAuditLog(entry); err != nil {
+		log.Error().Err(err).Str("audit", action).Msg("Failed to persist audit event")
+	}
+}
+
+// List returns recent audit entries, newest first
+func (s *AuditService) List(action string, limit int) ([]models.AuditLog, error) {
+	if limit <= 0 || limit > maxAuditLogs {
+		limit = maxAuditLogs
+	}
+	return s.Repo.FindRecentAuditLogs(action, limit)
+}
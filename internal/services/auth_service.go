@@ -1,191 +1,355 @@
 package services
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
-	"golang.org/x/crypto/bcrypt"
 
 	"fiber-gorm/internal/config"
 	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/passhash"
 	"fiber-gorm/internal/repository"
 	"fiber-gorm/internal/validators"
 )
 
-// Custom token claims to include additional data
-type TokenClaims struct {
-	jwt.RegisteredClaims
-	Email    string `json:"email,omitempty"`
-	Username string `json:"username,omitempty"`
-	Role     string `json:"role,omitempty"`
-}
-
 // Error types for authentication
 var (
 	ErrInvalidCredentials = errors.New("Invalid email or password")
 	ErrUserNotFound       = errors.New("User not found")
 	ErrInvalidToken       = errors.New("Invalid or expired token")
 	ErrPasswordMismatch   = errors.New("Passwords do not match")
+	ErrRefreshTokenReused = errors.New("Refresh token has already been used")
+)
+
+// Token lifetimes
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
 )
 
 // AuthService handles authentication logic
 type AuthService struct {
-	Cfg      config.Config
-	UserRepo *repository.UserRepository
+	Cfg              config.Config
+	Verifier         *TokenVerifier
+	UserRepo         *repository.UserRepository
+	RefreshTokenRepo *repository.RefreshTokenRepository
+	SessionRepo      *repository.SessionRepository
+	RoleRepo         *repository.RoleRepository
+	Hasher           *passhash.Hasher
+	Passwords        *PasswordPolicy
+
+	EmailVerification *EmailVerificationService
+	Throttle          *LoginThrottle
 }
 
-func NewAuthService(cfg config.Config, userRepo *repository.UserRepository) *AuthService {
+func NewAuthService(cfg config.Config, verifier *TokenVerifier, userRepo *repository.UserRepository, refreshTokenRepo *repository.RefreshTokenRepository, sessionRepo *repository.SessionRepository, roleRepo *repository.RoleRepository, hasher *passhash.Hasher, passwords *PasswordPolicy, emailVerification *EmailVerificationService, throttle *LoginThrottle) *AuthService {
 	return &AuthService{
-		Cfg:      cfg,
-		UserRepo: userRepo,
+		Cfg:               cfg,
+		Verifier:          verifier,
+		UserRepo:          userRepo,
+		RefreshTokenRepo:  refreshTokenRepo,
+		SessionRepo:       sessionRepo,
+		RoleRepo:          roleRepo,
+		Hasher:            hasher,
+		Passwords:         passwords,
+		EmailVerification: emailVerification,
+		Throttle:          throttle,
+	}
+}
+
+// CreateTokens generates both access and refresh tokens for a user,
+// starting a new session and refresh token family. authMethods records how
+// the user authenticated and is carried over when the refresh token is rotated.
+func (s *AuthService) CreateTokens(user *models.User, client ClientInfo, authMethods ...string) (accessToken string, refreshToken string, err error) {
+	now := time.Now()
+	session := models.Session{
+		ID:          uuid.New(),
+		UserID:      user.ID,
+		UserAgent:   client.UserAgent,
+		IP:          client.IP,
+		DeviceLabel: DeviceLabel(client.UserAgent),
+		LastSeenAt:  now,
+	}
+	if err := s.SessionRepo.CreateSession(&session); err != nil {
+		return "", "", fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return s.issueTokens(user, session.ID, nil, tokenGrant{AuthMethods: authMethods})
+}
+
+// tokenGrant describes what a token pair is issued for. ClientID is set
+// for tokens issued to a third-party app, limited to Scopes. Actor is set
+// for impersonation tokens. AccessOnly skips the refresh token for clients
+// not allowed to refresh.
+type tokenGrant struct {
+	AuthMethods []string
+	ClientID    *uuid.UUID
+	Scopes      []string
+	Actor       *ActorClaim
+	AccessOnly  bool
+}
+
+// grantOf returns the grant a stored refresh token was issued for
+func grantOf(token *models.RefreshToken) tokenGrant {
+	return tokenGrant{
+		AuthMethods: strings.Fields(token.AuthMethods),
+		ClientID:    token.ClientID,
+		Scopes:      strings.Fields(token.Scopes),
 	}
 }
 
-// CreateTokens generates both access and refresh tokens for a user
-func (s *AuthService) CreateTokens(user *models.User) (accessToken string, refreshToken string, err error) {
+// issueTokens signs a token pair and records the refresh token as a member
+// of the given family. parentID links a rotated token to its predecessor.
+// Access-only grants may pass uuid.Nil as the family to issue a token
+// outside of any session.
+func (s *AuthService) issueTokens(user *models.User, familyID uuid.UUID, parentID *uuid.UUID, grant tokenGrant) (accessToken string, refreshToken string, err error) {
+	ttl := accessTokenTTL
+	if grant.Actor != nil {
+		ttl = impersonationTTL
+	}
+
 	// Create access token with custom claims
-	accessExp := time.Now().Add(15 * time.Minute)
-	accessClaims := &TokenClaims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(accessExp),
-			Subject:   user.ID.String(),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ID:        fmt.Sprintf("%d", time.Now().UnixNano()),
-		},
-		Email:    user.Email,
-		Username: user.Name,
-		Role:     "user", // You can add role-based auth later
-	}
-
-	accessJWT := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessToken, err = accessJWT.SignedString([]byte(s.Cfg.JWTSecret))
+	accessClaims := s.Verifier.NewClaims(TokenTypeAccess, user.ID.String(), ttl)
+	accessClaims.Email = user.Email
+	accessClaims.Username = user.Name
+	if familyID != uuid.Nil {
+		accessClaims.SessionID = familyID.String()
+	}
+	accessClaims.Actor = grant.Actor
+	accessClaims.EmailVerified = user.EmailVerifiedAt != nil
+	accessClaims.AuthMethods = grant.AuthMethods
+	if user.Role != nil {
+		accessClaims.Role = user.Role.Name
+		accessClaims.Permissions = user.Role.PermissionNames()
+	}
+	if grant.ClientID != nil {
+		accessClaims.ClientID = grant.ClientID.String()
+		accessClaims.Scope = strings.Join(grant.Scopes, " ")
+		accessClaims.RestrictToScope()
+	}
+
+	// Unverified accounts get no permissions until they confirm their email
+	if !accessClaims.EmailVerified && s.Cfg.EmailVerificationPolicy == VerificationPolicyRestrict {
+		accessClaims.Permissions = nil
+	}
+
+	accessToken, err = s.Verifier.Keys.Sign(accessClaims)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to sign access token")
 		return "", "", fmt.Errorf("failed to create access token: %w", err)
 	}
+	if grant.AccessOnly {
+		return accessToken, "", nil
+	}
 
 	// Create refresh token with minimal claims (long-lived)
-	refreshExp := time.Now().Add(7 * 24 * time.Hour)
-	refreshClaims := &jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(refreshExp),
-		Subject:   user.ID.String(),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ID:        fmt.Sprintf("%d", time.Now().UnixNano()),
-	}
+	refreshClaims := s.Verifier.NewClaims(TokenTypeRefresh, user.ID.String(), refreshTokenTTL)
 
-	refreshJWT := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshToken, err = refreshJWT.SignedString([]byte(s.Cfg.JWTSecret))
+	refreshToken, err = s.Verifier.Keys.Sign(refreshClaims)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to sign refresh token")
 		return "", "", fmt.Errorf("failed to create refresh token: %w", err)
 	}
 
+	// Persist the refresh token so it can be rotated and revoked
+	record := models.RefreshToken{
+		UserID:      user.ID,
+		FamilyID:    familyID,
+		ParentID:    parentID,
+		TokenHash:   hashToken(refreshToken),
+		AuthMethods: strings.Join(grant.AuthMethods, " "),
+		ClientID:    grant.ClientID,
+		Scopes:      strings.Join(grant.Scopes, " "),
+		ExpiresAt:   refreshClaims.ExpiresAt.Time,
+	}
+	if err := s.RefreshTokenRepo.CreateRefreshToken(&record); err != nil {
+		return "", "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
 	return accessToken, refreshToken, nil
 }
 
+// hashToken returns the hex-encoded SHA-256 digest used to store tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // ValidateAccessToken validates an access token and returns the claims
 func (s *AuthService) ValidateAccessToken(tokenString string) (*TokenClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.Cfg.JWTSecret), nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if !token.Valid {
-		return nil, ErrInvalidToken
-	}
-
-	claims, ok := token.Claims.(*TokenClaims)
-	if !ok {
-		return nil, errors.New("failed to parse token claims")
-	}
-
-	return claims, nil
+	return s.Verifier.VerifyAccessToken(tokenString)
 }
 
-// ValidateRefreshToken validates a refresh token
+// ValidateRefreshToken validates a refresh token and returns the user ID it was issued to
 func (s *AuthService) ValidateRefreshToken(tokenString string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.Cfg.JWTSecret), nil
-	})
-
+	claims, err := s.Verifier.Verify(tokenString, TokenTypeRefresh)
 	if err != nil {
 		return "", err
 	}
 
-	if !token.Valid {
-		return "", ErrInvalidToken
-	}
-
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
-	if !ok {
-		return "", errors.New("failed to parse token claims")
-	}
-
 	return claims.Subject, nil
 }
 
-// HashPassword creates a bcrypt hash of a password
+// HashPassword hashes a password with the configured algorithm
 func (s *AuthService) HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hash), nil
+	return s.Hasher.Hash(password)
 }
 
 // ComparePassword checks if the provided password matches the hashed password
 func (s *AuthService) ComparePassword(hashedPassword, password string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-	if err != nil {
-		return ErrInvalidCredentials
+	if _, err := s.verifyPassword(hashedPassword, password); err != nil {
+		return err
 	}
 	return nil
 }
 
+// verifyPassword checks a password against a stored hash and reports whether
+// the hash should be upgraded to the current algorithm and parameters
+func (s *AuthService) verifyPassword(hashedPassword, password string) (rehash bool, err error) {
+	match, rehash, err := s.Hasher.Verify(hashedPassword, password)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to verify password hash")
+		return false, ErrInvalidCredentials
+	}
+	if !match {
+		return false, ErrInvalidCredentials
+	}
+	return rehash, nil
+}
+
+// LoginBlocked reports whether the verification policy prevents the user from signing in
+func (s *AuthService) LoginBlocked(user *models.User) bool {
+	return user.EmailVerifiedAt == nil && s.Cfg.EmailVerificationPolicy == VerificationPolicyBlock
+}
+
 // LoginUser authenticates a user and returns access and refresh tokens
-func (s *AuthService) LoginUser(payload *models.LoginUserPayload) (user *models.User, accessToken string, refreshToken string, err error) {
+func (s *AuthService) LoginUser(payload *models.LoginUserPayload, client ClientInfo) (user *models.User, accessToken string, refreshToken string, err error) {
+	// Refuse attempts from accounts or IPs with too many recent failures
+	if err = s.Throttle.Check(payload.Email, client.IP); err != nil {
+		return nil, "", "", err
+	}
+
 	// Find the user by email
 	user, err = s.UserRepo.FindUserByEmail(payload.Email)
 	if err != nil {
 		log.Error().Err(err).Str("email", payload.Email).Msg("User not found during login")
+		// Spend as long as a password check would, so response times do not reveal which emails are registered
+		s.Hasher.VerifyDummy(payload.Password)
+		s.loginFailed(payload.Email, client, nil)
 		return nil, "", "", ErrInvalidCredentials
 	}
 
 	// Compare the password with the stored hash
-	if err = s.ComparePassword(user.Password, payload.Password); err != nil {
+	rehash, err := s.verifyPassword(user.Password, payload.Password)
+	if err != nil {
 		log.Debug().Err(err).Str("email", payload.Email).Msg("Password mismatch during login")
+		s.loginFailed(payload.Email, client, user)
 		return nil, "", "", ErrInvalidCredentials
 	}
 
-	// Generate tokens
-	accessToken, refreshToken, err = s.CreateTokens(user)
+	// Upgrade hashes made with an older algorithm or weaker parameters while
+	// the plaintext password is at hand. Login still succeeds if this fails.
+	if rehash {
+		s.rehashPassword(user, payload.Password)
+	}
+
+	accessToken, refreshToken, err = s.finishLogin(user, client, AuthMethodPassword)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to generate tokens: %w", err)
+		return user, "", "", err
 	}
 
 	return user, accessToken, refreshToken, nil
 }
 
+// rehashPassword replaces the stored hash of a user's password with one made
+// with the current algorithm and parameters
+func (s *AuthService) rehashPassword(user *models.User, password string) {
+	hashedPassword, err := s.HashPassword(password)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID.String()).Msg("Failed to rehash password")
+		return
+	}
+
+	user.Password = hashedPassword
+	if err := s.UserRepo.UpdateUser(user); err != nil {
+		log.Error().Err(err).Str("user_id", user.ID.String()).Msg("Failed to store rehashed password")
+		return
+	}
+	log.Info().Str("user_id", user.ID.String()).Msg("Password hash upgraded")
+}
+
+// finishLogin issues tokens to a user who passed the first factor, or returns
+// an MFARequiredError with a challenge when two-factor authentication is enabled
+func (s *AuthService) finishLogin(user *models.User, client ClientInfo, authMethod string) (string, string, error) {
+	if s.LoginBlocked(user) {
+		return "", "", ErrEmailNotVerified
+	}
+
+	// Users with two-factor authentication must complete a second step
+	if user.TOTPEnabledAt != nil {
+		challenge, err := s.CreateMFAChallenge(user, authMethod)
+		if err != nil {
+			return "", "", err
+		}
+		return "", "", &MFARequiredError{ChallengeToken: challenge}
+	}
+
+	s.loginSucceeded(user.Email)
+
+	// Generate tokens
+	accessToken, refreshToken, err := s.CreateTokens(user, client, authMethod)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// loginFailed counts a failed login attempt. Counting errors are logged so
+// the caller still reports invalid credentials.
+func (s *AuthService) loginFailed(email string, client ClientInfo, user *models.User) {
+	if err := s.Throttle.RecordFailure(email, client.IP, user); err != nil {
+		log.Error().Err(err).Str("email", email).Msg("Failed to record login failure")
+	}
+}
+
+// loginSucceeded clears the account's failed login counter once every factor has been verified
+func (s *AuthService) loginSucceeded(email string) {
+	if err := s.Throttle.RecordSuccess(email); err != nil {
+		log.Error().Err(err).Str("email", email).Msg("Failed to reset login failures")
+	}
+}
+
+// UnlockUser lifts a failed-login lockout on a user's account
+func (s *AuthService) UnlockUser(userID string, actorID *uuid.UUID, ip string) (*models.User, error) {
+	user, err := s.UserRepo.FindUserById(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if err := s.Throttle.Unlock(user.Email); err != nil {
+		return nil, fmt.Errorf("failed to unlock account: %w", err)
+	}
+
+	s.Throttle.Audit.Record(models.AuditActionAccountUnlocked, actorID, &user.ID, ip, "")
+	return user, nil
+}
+
 // RegisterUser creates a new user account
 func (s *AuthService) RegisterUser(payload *models.CreateUserPayload) (*models.User, error) {
 	// Validate the payload
-	if err := validators.ValidateUserCreation(payload); err != nil {
+	if err := validators.ValidateUserCreation(payload, s.Passwords.Rules); err != nil {
 		return nil, fmt.Errorf("validation error: %w", err)
 	}
+	if err := s.Passwords.Check(&models.User{Name: payload.Name, Email: payload.Email}, payload.Password); err != nil {
+		return nil, err
+	}
 
 	// Check if user already exists
 	_, err := s.UserRepo.FindUserByEmail(payload.Email)
@@ -200,41 +364,169 @@ func (s *AuthService) RegisterUser(payload *models.CreateUserPayload) (*models.U
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
+	// New accounts get the default role
+	role, err := s.RoleRepo.FindRoleByName(models.RoleUser)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load default role: %w", err)
+	}
+
 	// Create the user
 	user := models.User{
 		Name:     payload.Name,
 		Email:    payload.Email,
 		Password: hashedPassword,
 		Hobby:    payload.Hobby,
+		RoleID:   &role.ID,
+		Role:     role,
 	}
 
 	// Save the user to the database
 	if err := s.UserRepo.CreateUser(&user); err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
+	s.Passwords.Remember(&user)
+
+	// A failed email must not fail the registration; the user can request a new one
+	if err := s.EmailVerification.SendVerification(&user); err != nil {
+		log.Error().Err(err).Str("user_id", user.ID.String()).Msg("Failed to send verification email")
+	}
 
 	return &user, nil
 }
 
-// RefreshTokens generates new access and refresh tokens using a valid refresh token
-func (s *AuthService) RefreshTokens(refreshToken string) (string, string, error) {
+// EnsureAdmin makes sure an administrator account exists for the given email.
+// An existing user is promoted; otherwise a new admin account is created.
+func (s *AuthService) EnsureAdmin(name, email, password string) (*models.User, error) {
+	role, err := s.RoleRepo.FindRoleByName(models.RoleAdmin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load admin role: %w", err)
+	}
+
+	user, err := s.UserRepo.FindUserByEmail(email)
+	if err == nil {
+		user.RoleID = &role.ID
+		user.Role = role
+		if err := s.UserRepo.UpdateUser(user); err != nil {
+			return nil, fmt.Errorf("failed to promote user: %w", err)
+		}
+		return user, nil
+	}
+
+	if err := s.Passwords.Check(&models.User{Name: name, Email: email}, password); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	hashedPassword, err := s.HashPassword(password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	// The operator configuring the admin vouches for the address
+	now := time.Now()
+	user = &models.User{
+		Name:            name,
+		Email:           email,
+		Password:        hashedPassword,
+		RoleID:          &role.ID,
+		Role:            role,
+		EmailVerifiedAt: &now,
+	}
+	if err := s.UserRepo.CreateUser(user); err != nil {
+		return nil, fmt.Errorf("failed to create admin: %w", err)
+	}
+	s.Passwords.Remember(user)
+
+	return user, nil
+}
+
+// RefreshTokens rotates a valid refresh token into a new token pair.
+// Presenting a refresh token that was already rotated revokes its whole family.
+func (s *AuthService) RefreshTokens(refreshToken string, client ClientInfo) (string, string, error) {
+	stored, user, err := s.rotateRefreshToken(refreshToken, nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Generate new tokens in the same family
+	accessToken, newRefreshToken, err := s.issueTokens(user, stored.FamilyID, &stored.ID, grantOf(stored))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create tokens: %w", err)
+	}
+
+	if err := s.SessionRepo.TouchSession(stored.FamilyID, client.IP, time.Now()); err != nil {
+		log.Error().Err(err).Str("session_id", stored.FamilyID.String()).Msg("Failed to update session")
+	}
+
+	return accessToken, newRefreshToken, nil
+}
+
+// rotateRefreshToken validates a refresh token issued to the given OAuth
+// client (nil for first-party logins), marks it used and returns it with its
+// user. Presenting a token that was already used revokes its whole family.
+func (s *AuthService) rotateRefreshToken(refreshToken string, clientID *uuid.UUID) (*models.RefreshToken, *models.User, error) {
 	// Validate the refresh token
 	userID, err := s.ValidateRefreshToken(refreshToken)
 	if err != nil {
-		return "", "", fmt.Errorf("invalid refresh token: %w", err)
+		return nil, nil, fmt.Errorf("invalid refresh token: %w", err)
+	}
+
+	// Look up the stored token
+	stored, err := s.RefreshTokenRepo.FindRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+
+	if stored.UserID.String() != userID || stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	// Tokens can only be refreshed by the client they were issued to
+	if (stored.ClientID == nil) != (clientID == nil) || (clientID != nil && *stored.ClientID != *clientID) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	// A token can only be exchanged once; a second use means it was stolen
+	used := false
+	if stored.UsedAt == nil {
+		if used, err = s.RefreshTokenRepo.MarkRefreshTokenUsed(stored.ID); err != nil {
+			return nil, nil, fmt.Errorf("failed to mark refresh token as used: %w", err)
+		}
+	}
+	if !used {
+		log.Warn().
+			Str("user_id", userID).
+			Str("family_id", stored.FamilyID.String()).
+			Msg("Refresh token reuse detected, revoking token family")
+		if err := s.RefreshTokenRepo.RevokeFamily(stored.FamilyID); err != nil {
+			return nil, nil, fmt.Errorf("failed to revoke token family: %w", err)
+		}
+		return nil, nil, ErrRefreshTokenReused
 	}
 
 	// Get the user
 	user, err := s.UserRepo.FindUserById(userID)
 	if err != nil {
-		return "", "", ErrUserNotFound
+		return nil, nil, ErrUserNotFound
 	}
 
-	// Generate new tokens
-	accessToken, newRefreshToken, err := s.CreateTokens(user)
+	return stored, user, nil
+}
+
+// Logout revokes the presented access token and the refresh token family it was issued with
+func (s *AuthService) Logout(claims *TokenClaims) error {
+	if err := s.Verifier.Revoke(claims); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	familyID, err := uuid.Parse(claims.SessionID)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create tokens: %w", err)
+		// Tokens issued without a session ID have no refresh token family to revoke
+		return nil
 	}
 
-	return accessToken, newRefreshToken, nil
+	if err := s.RefreshTokenRepo.RevokeFamily(familyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return nil
 }
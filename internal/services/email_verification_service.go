@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"fiber-gorm/internal/config"
+	"fiber-gorm/internal/mailer"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/repository"
+)
+
+// Email verification policies
+const (
+	VerificationPolicyNone     = "none"
+	VerificationPolicyRestrict = "restrict"
+	VerificationPolicyBlock    = "block"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+	// verificationCooldown limits how often a verification email can be resent
+	verificationCooldown = time.Minute
+)
+
+// Error types for email verification
+var (
+	ErrEmailNotVerified = errors.New("Email address has not been verified")
+)
+
+// EmailVerificationService issues and checks email verification tokens
+type EmailVerificationService struct {
+	Cfg       config.Config
+	Verifier  *TokenVerifier
+	UserRepo  *repository.UserRepository
+	TokenRepo *repository.OneTimeTokenRepository
+	Mailer    mailer.Mailer
+}
+
+func NewEmailVerificationService(cfg config.Config, verifier *TokenVerifier, userRepo *repository.UserRepository, tokenRepo *repository.OneTimeTokenRepository, m mailer.Mailer) *EmailVerificationService {
+	return &EmailVerificationService{
+		Cfg:       cfg,
+		Verifier:  verifier,
+		UserRepo:  userRepo,
+		TokenRepo: tokenRepo,
+		Mailer:    m,
+	}
+}
+
+// SendVerification emails a signed, single-use verification link to the user.
+// Links sent earlier stop working.
+func (s *EmailVerificationService) SendVerification(user *models.User) error {
+	claims := s.Verifier.NewClaims(TokenTypeEmailVerification, user.ID.String(), emailVerificationTTL)
+	claims.Email = user.Email
+
+	token, err := s.Verifier.Keys.Sign(claims)
+	if err != nil {
+		return fmt.Errorf("failed to sign verification token: %w", err)
+	}
+
+	if err := s.TokenRepo.InvalidateOneTimeTokens(user.ID, models.TokenPurposeEmailVerification); err != nil {
+		return fmt.Errorf("failed to invalidate previous tokens: %w", err)
+	}
+
+	record := models.OneTimeToken{
+		UserID:    user.ID,
+		Purpose:   models.TokenPurposeEmailVerification,
+		TokenHash: hashToken(token),
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	if err := s.TokenRepo.CreateOneTimeToken(&record); err != nil {
+		return fmt.Errorf("failed to store verification token: %w", err)
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", s.Cfg.AppBaseURL, url.QueryEscape(token))
+	return s.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in 24 hours.\n",
+			user.Name, link),
+	})
+}
+
+// VerifyEmail consumes a verification token and marks the user's email as verified
+func (s *EmailVerificationService) VerifyEmail(token string) (*models.User, error) {
+	claims, err := s.Verifier.Verify(token, TokenTypeEmailVerification)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	record, err := s.TokenRepo.ConsumeOneTimeToken(hashToken(token), models.TokenPurposeEmailVerification)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.UserRepo.FindUserById(record.UserID.String())
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	// The link only proves ownership of the address it was sent to
+	if user.ID.String() != claims.Subject || user.Email != claims.Email {
+		return nil, ErrInvalidToken
+	}
+
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := s.UserRepo.UpdateUser(user); err != nil {
+			return nil, fmt.Errorf("failed to mark email as verified: %w", err)
+		}
+	}
+
+	return user, nil
+}
+
+// ResendVerification sends a new verification email if the account exists and
+// is still unverified. It never reveals whether the account exists.
+func (s *EmailVerificationService) ResendVerification(email string) error {
+	user, err := s.UserRepo.FindUserByEmail(email)
+	if err != nil || user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	recent, err := s.TokenRepo.CountOneTimeTokensSince(user.ID, models.TokenPurposeEmailVerification, time.Now().Add(-verificationCooldown))
+	if err != nil {
+		return fmt.Errorf("failed to check recent verification emails: %w", err)
+	}
+	if recent > 0 {
+		log.Debug().Str("user_id", user.ID.String()).Msg("Verification email recently sent, skipping resend")
+		return nil
+	}
+
+	return s.SendVerification(user)
+}
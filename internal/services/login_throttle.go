@@ -0,0 +1,165 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fiber-gorm/internal/config"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/repository"
+)
+
+// maxLoginDelay caps the progressive delay between failed logins
+const maxLoginDelay = 30 * time.Second
+
+// LoginThrottledError is returned when a login is refused because of earlier
+// failures. Locked distinguishes a lockout from a progressive delay.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return "Too many failed login attempts, try again later"
+	}
+	return "Too many login attempts, slow down"
+}
+
+// ClientInfo describes the client making a request
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// LoginThrottle tracks failed logins per account and per client IP. After a
+// few failures each further attempt must wait progressively longer, and
+// after MaxFailures the account (or IP) is locked for Lockout.
+type LoginThrottle struct {
+	Store         repository.LoginAttemptStore
+	Audit         *AuditService
+	MaxFailures   int
+	MaxIPFailures int
+	Window        time.Duration
+	Lockout       time.Duration
+	// DelayBase is the wait after the third failure, doubling with each further failure. Zero disables delays.
+	DelayBase time.Duration
+}
+
+func NewLoginThrottle(cfg config.Config, store repository.LoginAttemptStore, audit *AuditService) *LoginThrottle {
+	return &LoginThrottle{
+		Store:         store,
+		Audit:         audit,
+		MaxFailures:   cfg.LoginMaxFailures,
+		MaxIPFailures: cfg.LoginIPMaxFailures,
+		Window:        cfg.LoginFailureWindow,
+		Lockout:       cfg.LoginLockoutDuration,
+		DelayBase:     cfg.LoginDelayBase,
+	}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns a LoginThrottledError if the account or IP may not attempt a login right now
+func (t *LoginThrottle) Check(email, ip string) error {
+	keys := []string{accountKey(email)}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		attempt, err := t.Store.Get(key)
+		if err != nil {
+			return fmt.Errorf("failed to read login attempts: %w", err)
+		}
+		if attempt == nil {
+			continue
+		}
+
+		if attempt.Locked(now) {
+			return &LoginThrottledError{RetryAfter: attempt.LockedUntil.Sub(now), Locked: true}
+		}
+		if attempt.Expired(now, t.Window) {
+			continue
+		}
+
+		if wait := attempt.LastFailureAt.Add(t.delay(attempt.Failures)).Sub(now); wait > 0 {
+			return &LoginThrottledError{RetryAfter: wait}
+		}
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed login and locks the account or IP once its
+// limit is reached. user is the account the email belongs to, if any.
+func (t *LoginThrottle) RecordFailure(email, ip string, user *models.User) error {
+	var targetID *uuid.UUID
+	if user != nil {
+		targetID = &user.ID
+	}
+
+	if err := t.recordFailure(accountKey(email), t.MaxFailures, models.AuditActionAccountLocked, targetID, ip, "email="+email); err != nil {
+		return err
+	}
+
+	if ip == "" {
+		return nil
+	}
+	return t.recordFailure(ipKey(ip), t.MaxIPFailures, models.AuditActionIPLocked, nil, ip, "")
+}
+
+func (t *LoginThrottle) recordFailure(key string, limit int, action string, targetID *uuid.UUID, ip, details string) error {
+	attempt, err := t.Store.RecordFailure(key, t.Window)
+	if err != nil {
+		return fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	if limit <= 0 || attempt.Failures < limit {
+		return nil
+	}
+
+	until := time.Now().Add(t.Lockout)
+	if err := t.Store.Lock(key, until); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", key, err)
+	}
+
+	t.Audit.Record(action, nil, targetID, ip, fmt.Sprintf("%s failures=%d locked_until=%s", details, attempt.Failures, until.Format(time.RFC3339)))
+	return nil
+}
+
+// RecordSuccess clears the account's failure counter after a successful login
+func (t *LoginThrottle) RecordSuccess(email string) error {
+	return t.Store.Reset(accountKey(email))
+}
+
+// Unlock lifts a lockout on the account
+func (t *LoginThrottle) Unlock(email string) error {
+	return t.Store.Reset(accountKey(email))
+}
+
+// delay returns how long to wait after the given number of failures
+func (t *LoginThrottle) delay(failures int) time.Duration {
+	if t.DelayBase <= 0 || failures < 3 {
+		return 0
+	}
+
+	delay := t.DelayBase
+	for i := 3; i < failures && delay < maxLoginDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxLoginDelay {
+		delay = maxLoginDelay
+	}
+	return delay
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"fiber-gorm/internal/config"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/repository"
+	"fiber-gorm/internal/totp"
+)
+
+const (
+	mfaChallengeTTL = 5 * time.Minute
+	// totpSkew is the number of 30 second steps of clock drift tolerated either way
+	totpSkew          = 1
+	recoveryCodeCount = 10
+)
+
+// Error types for two-factor authentication
+var (
+	ErrMFAAlreadyEnabled     = errors.New("Two-factor authentication is already enabled")
+	ErrMFANotEnabled         = errors.New("Two-factor authentication is not enabled")
+	ErrMFAEnrollmentMissing  = errors.New("Two-factor enrollment has not been started")
+	ErrInvalidMFACode        = errors.New("Invalid authentication code")
+	ErrInvalidMFAChallenge   = errors.New("Invalid or expired MFA challenge")
+	recoveryCodeEncoding     = base32.NewEncoding("abcdefghijkmnpqrstuvwxyz23456789").WithPadding(base32.NoPadding)
+	recoveryCodeReplacements = strings.NewReplacer("-", "", " ", "")
+)
+
+// MFARequiredError is returned by LoginUser when the password was correct but
+// a second factor is required. ChallengeToken must be exchanged together with
+// a TOTP or recovery code to obtain tokens.
+type MFARequiredError struct {
+	ChallengeToken string
+}
+
+func (e *MFARequiredError) Error() string {
+	return "Two-factor authentication required"
+}
+
+// CreateMFAChallenge issues a short-lived token proving the user passed the
+// first login step with the given authentication method
+func (s *AuthService) CreateMFAChallenge(user *models.User, authMethod string) (string, error) {
+	claims := s.Verifier.NewClaims(TokenTypeMFAChallenge, user.ID.String(), mfaChallengeTTL)
+	claims.AuthMethods = []string{authMethod}
+
+	token, err := s.Verifier.Keys.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to create MFA challenge: %w", err)
+	}
+	return token, nil
+}
+
+// TOTPEnrollment holds what a user needs to add the account to an authenticator app
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// MFAService manages TOTP enrollment, recovery codes and the second login step
+type MFAService struct {
+	Cfg              config.Config
+	Auth             *AuthService
+	UserRepo         *repository.UserRepository
+	RecoveryCodeRepo *repository.RecoveryCodeRepository
+}
+
+func NewMFAService(cfg config.Config, auth *AuthService, userRepo *repository.UserRepository, recoveryCodeRepo *repository.RecoveryCodeRepository) *MFAService {
+	return &MFAService{
+		Cfg:              cfg,
+		Auth:             auth,
+		UserRepo:         userRepo,
+		RecoveryCodeRepo: recoveryCodeRepo,
+	}
+}
+
+// BeginTOTPEnrollment generates a new secret for the user. Two-factor
+// authentication is not active until ConfirmTOTPEnrollment succeeds.
+func (s *MFAService) BeginTOTPEnrollment(userID string) (*TOTPEnrollment, error) {
+	user, err := s.UserRepo.FindUserById(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+	if err := s.UserRepo.UpdateUser(user); err != nil {
+		return nil, fmt.Errorf("failed to store TOTP secret: %w", err)
+	}
+
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.Cfg.JWTIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTPEnrollment activates two-factor authentication once the user
+// proves their authenticator works, and returns fresh recovery codes
+func (s *MFAService) ConfirmTOTPEnrollment(userID, code string) ([]string, error) {
+	user, err := s.UserRepo.FindUserById(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrMFAEnrollmentMissing
+	}
+
+	if err := s.verifyTOTP(user, code); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user.TOTPEnabledAt = &now
+	if err := s.UserRepo.UpdateUser(user); err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+
+	log.Info().Str("user_id", user.ID.String()).Msg("Two-factor authentication enabled")
+	return s.replaceRecoveryCodes(user)
+}
+
+// DisableTOTP turns off two-factor authentication. A current TOTP or recovery code is required.
+func (s *MFAService) DisableTOTP(userID, code string) error {
+	user, err := s.UserRepo.FindUserById(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if user.TOTPEnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	if err := s.verifySecondFactor(user, code); err != nil {
+		return err
+	}
+
+	user.TOTPSecret = ""
+	user.TOTPEnabledAt = nil
+	user.TOTPLastStep = 0
+	if err := s.UserRepo.UpdateUser(user); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+
+	if err := s.RecoveryCodeRepo.DeleteRecoveryCodes(user.ID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	log.Info().Str("user_id", user.ID.String()).Msg("Two-factor authentication disabled")
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes. A current TOTP code is required.
+func (s *MFAService) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	user, err := s.UserRepo.FindUserById(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.TOTPEnabledAt == nil {
+		return nil, ErrMFANotEnabled
+	}
+
+	if err := s.verifyTOTP(user, code); err != nil {
+		return nil, err
+	}
+
+	return s.replaceRecoveryCodes(user)
+}
+
+// CompleteLogin exchanges an MFA challenge and a TOTP or recovery code for tokens.
+// Wrong codes count as failed logins for the account.
+func (s *MFAService) CompleteLogin(challenge, code, recoveryCode string, client ClientInfo) (*models.User, string, string, error) {
+	claims, err := s.Auth.Verifier.VerifyActive(challenge, TokenTypeMFAChallenge)
+	if err != nil {
+		return nil, "", "", ErrInvalidMFAChallenge
+	}
+
+	user, err := s.UserRepo.FindUserById(claims.Subject)
+	if err != nil {
+		return nil, "", "", ErrUserNotFound
+	}
+	if user.TOTPEnabledAt == nil {
+		return nil, "", "", ErrMFANotEnabled
+	}
+
+	if err := s.Auth.Throttle.Check(user.Email, client.IP); err != nil {
+		return nil, "", "", err
+	}
+
+	if recoveryCode != "" {
+		err = s.useRecoveryCode(user, recoveryCode)
+	} else {
+		err = s.verifyTOTP(user, code)
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.Auth.loginFailed(user.Email, client, user)
+		}
+		return nil, "", "", err
+	}
+	s.Auth.loginSucceeded(user.Email)
+
+	// A challenge can only be completed once
+	if err := s.Auth.Verifier.Revoke(claims); err != nil {
+		return nil, "", "", fmt.Errorf("failed to revoke MFA challenge: %w", err)
+	}
+
+	accessToken, refreshToken, err := s.Auth.CreateTokens(user, client, append(claims.AuthMethods, AuthMethodOTP)...)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	return user, accessToken, refreshToken, nil
+}
+
+// verifySecondFactor accepts either a TOTP code or a recovery code
+func (s *MFAService) verifySecondFactor(user *models.User, code string) error {
+	if err := s.verifyTOTP(user, code); err == nil {
+		return nil
+	}
+	return s.useRecoveryCode(user, code)
+}
+
+// verifyTOTP checks a TOTP code and rejects codes that were already used
+func (s *MFAService) verifyTOTP(user *models.User, code string) error {
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), totpSkew)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	advanced, err := s.UserRepo.AdvanceTOTPStep(user, step)
+	if err != nil {
+		return fmt.Errorf("failed to record TOTP use: %w", err)
+	}
+	if !advanced {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (s *MFAService) useRecoveryCode(user *models.User, code string) error {
+	used, err := s.RecoveryCodeRepo.ConsumeRecoveryCode(user.ID, hashRecoveryCode(code))
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+
+	log.Info().Str("user_id", user.ID.String()).Msg("Recovery code used")
+	return nil
+}
+
+func (s *MFAService) replaceRecoveryCodes(user *models.User) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+
+		raw := recoveryCodeEncoding.EncodeToString(buf)[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashRecoveryCode(raw))
+	}
+
+	if err := s.RecoveryCodeRepo.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+// hashRecoveryCode normalizes a recovery code as typed by the user and hashes it
+func hashRecoveryCode(code string) string {
+	return hashToken(strings.ToLower(recoveryCodeReplacements.Replace(code)))
+}
@@ -0,0 +1,583 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/oidc"
+	"fiber-gorm/internal/repository"
+)
+
+// authorizationCodeTTL is how long a client has to exchange an authorization code
+const authorizationCodeTTL = 5 * time.Minute
+
+// OAuth error codes (RFC 6749 section 5.2)
+const (
+	OAuthErrInvalidRequest          = "invalid_request"
+	OAuthErrInvalidClient           = "invalid_client"
+	OAuthErrInvalidGrant            = "invalid_grant"
+	OAuthErrUnauthorizedClient      = "unauthorized_client"
+	OAuthErrUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthErrUnsupportedResponseType = "unsupported_response_type"
+	OAuthErrInvalidScope            = "invalid_scope"
+	OAuthErrAccessDenied            = "access_denied"
+)
+
+// ErrOAuthClientNotFound is returned when managing a client that does not exist
+var ErrOAuthClientNotFound = errors.New("OAuth client not found")
+
+// OAuthError is an error reported to an OAuth client with its RFC 6749 error code
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Description
+}
+
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// OAuthTokenResponse is the token endpoint response (RFC 6749 section 5.1)
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// IntrospectionResponse describes a token to the client it was issued to (RFC 7662 section 2.2)
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	JTI       string `json:"jti,omitempty"`
+}
+
+// AuthorizationDetails is shown to the user on the consent step
+type AuthorizationDetails struct {
+	Client          *models.OAuthClient `json:"client"`
+	RedirectURI     string              `json:"redirect_uri"`
+	Scopes          []string            `json:"scopes"`
+	ConsentRequired bool                `json:"consent_required"`
+}
+
+// OAuthService lets registered third-party apps obtain tokens on behalf of
+// users (authorization code with PKCE) or themselves (client credentials)
+type OAuthService struct {
+	Auth *AuthService
+	Repo *repository.OAuthRepository
+}
+
+func NewOAuthService(auth *AuthService, repo *repository.OAuthRepository) *OAuthService {
+	return &OAuthService{
+		Auth: auth,
+		Repo: repo,
+	}
+}
+
+// CreateClient registers a client and returns its secret, which is not stored.
+// Public clients get no secret.
+func (s *OAuthService) CreateClient(payload *models.CreateOAuthClientPayload) (*models.OAuthClient, string, error) {
+	permissions, err := s.knownPermissions()
+	if err != nil {
+		return nil, "", err
+	}
+	for _, scope := range payload.Scopes {
+		if !containsString(permissions, scope) {
+			return nil, "", oauthError(OAuthErrInvalidScope, fmt.Sprintf("Unknown scope: %s", scope))
+		}
+	}
+
+	for _, grantType := range payload.GrantTypes {
+		if grantType == models.GrantTypeClientCredentials && payload.Public {
+			return nil, "", oauthError(OAuthErrInvalidRequest, "Public clients cannot use the client_credentials grant")
+		}
+	}
+	if containsString(payload.GrantTypes, models.GrantTypeAuthorizationCode) && len(payload.RedirectURIs) == 0 {
+		return nil, "", oauthError(OAuthErrInvalidRequest, "The authorization_code grant requires at least one redirect URI")
+	}
+
+	client := &models.OAuthClient{
+		Name:         payload.Name,
+		Public:       payload.Public,
+		RedirectURIs: payload.RedirectURIs,
+		GrantTypes:   payload.GrantTypes,
+		Scopes:       payload.Scopes,
+	}
+
+	var secret string
+	if !payload.Public {
+		if secret, err = generateToken(); err != nil {
+			return nil, "", fmt.Errorf("failed to generate client secret: %w", err)
+		}
+		client.SecretHash = hashToken(secret)
+	}
+
+	if err := s.Repo.CreateClient(client); err != nil {
+		return nil, "", fmt.Errorf("failed to store OAuth client: %w", err)
+	}
+
+	log.Info().Str("client_id", client.ID.String()).Str("name", client.Name).Msg("OAuth client registered")
+	return client, secret, nil
+}
+
+// knownPermissions returns every permission granted by some role
+func (s *OAuthService) knownPermissions() ([]string, error) {
+	roles, err := s.Auth.RoleRepo.FindAllRoles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load roles: %w", err)
+	}
+
+	var permissions []string
+	for _, role := range roles {
+		for _, name := range role.PermissionNames() {
+			if !containsString(permissions, name) {
+				permissions = append(permissions, name)
+			}
+		}
+	}
+	return permissions, nil
+}
+
+// ListClients returns all registered clients
+func (s *OAuthService) ListClients() ([]models.OAuthClient, error) {
+	return s.Repo.FindClients()
+}
+
+// DeleteClient removes a client and revokes every refresh token issued to it.
+// Access tokens already issued stay valid until they expire.
+func (s *OAuthService) DeleteClient(clientID string) error {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return ErrOAuthClientNotFound
+	}
+
+	deleted, err := s.Repo.DeleteClient(id)
+	if err != nil {
+		return fmt.Errorf("failed to delete OAuth client: %w", err)
+	}
+	if !deleted {
+		return ErrOAuthClientNotFound
+	}
+
+	if err := s.Auth.RefreshTokenRepo.RevokeAllForClient(id); err != nil {
+		return fmt.Errorf("failed to revoke client tokens: %w", err)
+	}
+	return nil
+}
+
+// AuthenticateClient checks the credentials a client presented at the token,
+// introspection or revocation endpoint. Public clients present no secret.
+func (s *OAuthService) AuthenticateClient(clientID, secret string) (*models.OAuthClient, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return nil, oauthError(OAuthErrInvalidClient, "Client authentication failed")
+	}
+
+	client, err := s.Repo.FindClient(id)
+	if err != nil {
+		return nil, oauthError(OAuthErrInvalidClient, "Client authentication failed")
+	}
+
+	if client.Public {
+		if secret != "" {
+			return nil, oauthError(OAuthErrInvalidClient, "Client authentication failed")
+		}
+		return client, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, oauthError(OAuthErrInvalidClient, "Client authentication failed")
+	}
+	return client, nil
+}
+
+// resolveScopes checks requested scopes against those allowed, defaulting to all of them
+func resolveScopes(requested string, allowed []string) ([]string, error) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return allowed, nil
+	}
+
+	for _, scope := range scopes {
+		if !containsString(allowed, scope) {
+			return nil, oauthError(OAuthErrInvalidScope, fmt.Sprintf("Scope is not allowed: %s", scope))
+		}
+	}
+	return scopes, nil
+}
+
+// ValidateAuthorization checks an authorization request from a client on
+// behalf of the signed-in user and returns what the user is asked to approve
+func (s *OAuthService) ValidateAuthorization(userID string, req *models.AuthorizeRequest) (*AuthorizationDetails, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	id, err := uuid.Parse(req.ClientID)
+	if err != nil {
+		return nil, oauthError(OAuthErrInvalidClient, "Unknown client")
+	}
+	client, err := s.Repo.FindClient(id)
+	if err != nil {
+		return nil, oauthError(OAuthErrInvalidClient, "Unknown client")
+	}
+
+	// The redirect URI may only be omitted when exactly one is registered
+	redirectURI := req.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !client.AllowsRedirect(redirectURI) {
+		return nil, oauthError(OAuthErrInvalidRequest, "Redirect URI is not registered for this client")
+	}
+
+	if req.ResponseType != "code" {
+		return nil, oauthError(OAuthErrUnsupportedResponseType, "Only the code response type is supported")
+	}
+	if !client.AllowsGrant(models.GrantTypeAuthorizationCode) {
+		return nil, oauthError(OAuthErrUnauthorizedClient, "Client may not use the authorization code grant")
+	}
+
+	// PKCE is required for every client, and only with SHA-256
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) != 43 {
+		return nil, oauthError(OAuthErrInvalidRequest, "A S256 code challenge is required")
+	}
+
+	scopes, err := resolveScopes(req.Scope, client.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	consentRequired := true
+	if consent, err := s.Repo.FindConsent(uid, client.ID); err == nil {
+		consentRequired = false
+		for _, scope := range scopes {
+			if !containsString(consent.Scopes, scope) {
+				consentRequired = true
+			}
+		}
+	}
+
+	return &AuthorizationDetails{
+		Client:          client,
+		RedirectURI:     redirectURI,
+		Scopes:          scopes,
+		ConsentRequired: consentRequired,
+	}, nil
+}
+
+// Authorize records the user's answer to an authorization request and returns
+// the URL to send the user back to the client with, carrying either an
+// authorization code or an access_denied error
+func (s *OAuthService) Authorize(userID string, req *models.AuthorizeRequest, approve bool) (string, error) {
+	details, err := s.ValidateAuthorization(userID, req)
+	if err != nil {
+		return "", err
+	}
+	uid, _ := uuid.Parse(userID)
+
+	params := url.Values{}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	if !approve {
+		params.Set("error", OAuthErrAccessDenied)
+		return withQuery(details.RedirectURI, params), nil
+	}
+
+	consent := &models.OAuthConsent{UserID: uid, ClientID: details.Client.ID, Scopes: details.Scopes}
+	if previous, err := s.Repo.FindConsent(uid, details.Client.ID); err == nil {
+		for _, scope := range previous.Scopes {
+			if !containsString(consent.Scopes, scope) {
+				consent.Scopes = append(consent.Scopes, scope)
+			}
+		}
+	}
+	if err := s.Repo.SaveConsent(consent); err != nil {
+		return "", fmt.Errorf("failed to save consent: %w", err)
+	}
+
+	code, err := generateToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate authorization code: %w", err)
+	}
+
+	record := &models.OAuthAuthorizationCode{
+		CodeHash:        hashToken(code),
+		ClientID:        details.Client.ID,
+		UserID:          uid,
+		RedirectURI:     details.RedirectURI,
+		RedirectURISent: req.RedirectURI != "",
+		Scopes:          details.Scopes,
+		CodeChallenge:   req.CodeChallenge,
+		ExpiresAt:       time.Now().Add(authorizationCodeTTL),
+	}
+	if err := s.Repo.CreateAuthorizationCode(record); err != nil {
+		return "", fmt.Errorf("failed to store authorization code: %w", err)
+	}
+
+	params.Set("code", code)
+	return withQuery(details.RedirectURI, params), nil
+}
+
+// withQuery appends query parameters to a redirect URI that may already have some
+func withQuery(redirectURI string, params url.Values) string {
+	separator := "?"
+	if strings.Contains(redirectURI, "?") {
+		separator = "&"
+	}
+	return redirectURI + separator + params.Encode()
+}
+
+// Token handles a token endpoint request from an authenticated client
+func (s *OAuthService) Token(client *models.OAuthClient, req *models.OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	switch req.GrantType {
+	case models.GrantTypeAuthorizationCode, models.GrantTypeClientCredentials, models.GrantTypeRefreshToken:
+	default:
+		return nil, oauthError(OAuthErrUnsupportedGrantType, "Unsupported grant type")
+	}
+
+	if !client.AllowsGrant(req.GrantType) {
+		return nil, oauthError(OAuthErrUnauthorizedClient, "Client may not use this grant type")
+	}
+
+	switch req.GrantType {
+	case models.GrantTypeAuthorizationCode:
+		return s.exchangeCode(client, req)
+	case models.GrantTypeClientCredentials:
+		return s.clientCredentials(client, req)
+	default:
+		return s.refresh(client, req)
+	}
+}
+
+// exchangeCode redeems an authorization code (RFC 6749 section 4.1.3, RFC 7636 section 4.6)
+func (s *OAuthService) exchangeCode(client *models.OAuthClient, req *models.OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	invalidGrant := oauthError(OAuthErrInvalidGrant, "Invalid authorization code")
+
+	codeHash := hashToken(req.Code)
+	code, err := s.Repo.FindAuthorizationCode(codeHash)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, invalidGrant
+		}
+		return nil, fmt.Errorf("failed to load authorization code: %w", err)
+	}
+
+	if code.ClientID != client.ID {
+		return nil, invalidGrant
+	}
+	// The redirect URI must be repeated only if the authorization request
+	// included it, but when given it always has to match
+	if (code.RedirectURISent || req.RedirectURI != "") && code.RedirectURI != req.RedirectURI {
+		return nil, invalidGrant
+	}
+
+	if len(req.CodeVerifier) < 43 || len(req.CodeVerifier) > 128 ||
+		subtle.ConstantTimeCompare([]byte(oidc.CodeChallenge(req.CodeVerifier)), []byte(code.CodeChallenge)) != 1 {
+		return nil, invalidGrant
+	}
+
+	// A code can only be exchanged once; a second use means it was intercepted
+	familyID := uuid.New()
+	marked := false
+	if code.UsedAt == nil {
+		if marked, err = s.Repo.MarkAuthorizationCodeUsed(codeHash, familyID); err != nil {
+			return nil, fmt.Errorf("failed to mark authorization code as used: %w", err)
+		}
+	}
+	if !marked {
+		s.revokeCodeTokens(codeHash)
+		return nil, invalidGrant
+	}
+
+	user, err := s.Auth.UserRepo.FindUserById(code.UserID.String())
+	if err != nil || s.Auth.LoginBlocked(user) {
+		return nil, invalidGrant
+	}
+
+	accessToken, refreshToken, err := s.Auth.issueTokens(user, familyID, nil, tokenGrant{
+		ClientID:   &client.ID,
+		Scopes:     code.Scopes,
+		AccessOnly: !client.AllowsGrant(models.GrantTypeRefreshToken),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newOAuthTokenResponse(accessToken, refreshToken, code.Scopes), nil
+}
+
+// revokeCodeTokens revokes the tokens issued for a replayed authorization code
+func (s *OAuthService) revokeCodeTokens(codeHash string) {
+	code, err := s.Repo.FindAuthorizationCode(codeHash)
+	if err != nil || code.FamilyID == nil {
+		return
+	}
+
+	log.Warn().
+		Str("client_id", code.ClientID.String()).
+		Str("user_id", code.UserID.String()).
+		Msg("Authorization code reuse detected, revoking issued tokens")
+	if err := s.Auth.RefreshTokenRepo.RevokeFamily(*code.FamilyID); err != nil {
+		log.Error().Err(err).Str("family_id", code.FamilyID.String()).Msg("Failed to revoke token family")
+	}
+}
+
+// clientCredentials issues an access token to a confidential client acting on its own behalf (RFC 6749 section 4.4)
+func (s *OAuthService) clientCredentials(client *models.OAuthClient, req *models.OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	if client.Public {
+		return nil, oauthError(OAuthErrUnauthorizedClient, "Public clients cannot use the client_credentials grant")
+	}
+
+	scopes, err := resolveScopes(req.Scope, client.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := s.Auth.Verifier.NewClaims(TokenTypeAccess, client.ID.String(), accessTokenTTL)
+	claims.ClientID = client.ID.String()
+	claims.Scope = strings.Join(scopes, " ")
+	claims.Permissions = scopes
+
+	accessToken, err := s.Auth.Verifier.Keys.Sign(claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create access token: %w", err)
+	}
+
+	return newOAuthTokenResponse(accessToken, "", scopes), nil
+}
+
+// refresh rotates a refresh token issued to the client, optionally narrowing its scope (RFC 6749 section 6)
+func (s *OAuthService) refresh(client *models.OAuthClient, req *models.OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	// Check a narrowed scope before rotating, so a bad request does not use up the token
+	if req.Scope != "" {
+		if stored, err := s.Auth.RefreshTokenRepo.FindRefreshTokenByHash(hashToken(req.RefreshToken)); err == nil {
+			if _, err := resolveScopes(req.Scope, strings.Fields(stored.Scopes)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	stored, user, err := s.Auth.rotateRefreshToken(req.RefreshToken, &client.ID)
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenReused) {
+			return nil, oauthError(OAuthErrInvalidGrant, err.Error())
+		}
+		return nil, oauthError(OAuthErrInvalidGrant, "Invalid refresh token")
+	}
+
+	grant := grantOf(stored)
+	scopes, err := resolveScopes(req.Scope, grant.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	grant.Scopes = scopes
+
+	if s.Auth.LoginBlocked(user) {
+		return nil, oauthError(OAuthErrInvalidGrant, "Invalid refresh token")
+	}
+
+	accessToken, refreshToken, err := s.Auth.issueTokens(user, stored.FamilyID, &stored.ID, grant)
+	if err != nil {
+		return nil, err
+	}
+
+	return newOAuthTokenResponse(accessToken, refreshToken, scopes), nil
+}
+
+func newOAuthTokenResponse(accessToken, refreshToken string, scopes []string) *OAuthTokenResponse {
+	return &OAuthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	}
+}
+
+// Introspect describes a token issued to the client. Tokens issued to other
+// clients or to users directly are reported as inactive.
+func (s *OAuthService) Introspect(client *models.OAuthClient, token, hint string) *IntrospectionResponse {
+	if hint != TokenTypeRefresh+"_token" {
+		if claims, err := s.Auth.Verifier.VerifyAccessToken(token); err == nil {
+			if claims.ClientID != client.ID.String() {
+				return &IntrospectionResponse{Active: false}
+			}
+			return &IntrospectionResponse{
+				Active:    true,
+				Scope:     claims.Scope,
+				ClientID:  claims.ClientID,
+				Username:  claims.Email,
+				TokenType: "Bearer",
+				ExpiresAt: claims.ExpiresAt.Unix(),
+				IssuedAt:  claims.IssuedAt.Unix(),
+				Subject:   claims.Subject,
+				Issuer:    claims.Issuer,
+				JTI:       claims.ID,
+			}
+		}
+	}
+
+	stored, err := s.Auth.RefreshTokenRepo.FindRefreshTokenByHash(hashToken(token))
+	if err != nil || stored.ClientID == nil || *stored.ClientID != client.ID {
+		return &IntrospectionResponse{Active: false}
+	}
+	if stored.UsedAt != nil || stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return &IntrospectionResponse{Active: false}
+	}
+
+	return &IntrospectionResponse{
+		Active:    true,
+		Scope:     stored.Scopes,
+		ClientID:  stored.ClientID.String(),
+		TokenType: TokenTypeRefresh + "_token",
+		ExpiresAt: stored.ExpiresAt.Unix(),
+		IssuedAt:  stored.CreatedAt.Unix(),
+		Subject:   stored.UserID.String(),
+	}
+}
+
+// Revoke revokes a token issued to the client. Revoking a refresh token
+// revokes its whole family. Unknown tokens are ignored (RFC 7009 section 2.2).
+func (s *OAuthService) Revoke(client *models.OAuthClient, token, hint string) error {
+	if hint != TokenTypeRefresh+"_token" {
+		if claims, err := s.Auth.Verifier.VerifyAccessToken(token); err == nil {
+			if claims.ClientID != client.ID.String() {
+				return nil
+			}
+			return s.Auth.Verifier.Revoke(claims)
+		}
+	}
+
+	stored, err := s.Auth.RefreshTokenRepo.FindRefreshTokenByHash(hashToken(token))
+	if err != nil || stored.ClientID == nil || *stored.ClientID != client.ID {
+		return nil
+	}
+	if err := s.Auth.RefreshTokenRepo.RevokeFamily(stored.FamilyID); err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+
+	"fiber-gorm/internal/config"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/oidc"
+	"fiber-gorm/internal/repository"
+)
+
+// OIDCStateTTL is how long a user has to complete the login at the provider
+const OIDCStateTTL = 10 * time.Minute
+
+// Error types for OpenID Connect login
+var (
+	ErrUnknownProvider   = errors.New("Unknown identity provider")
+	ErrInvalidOIDCState  = errors.New("Invalid or expired login request")
+	ErrOIDCLoginFailed   = errors.New("Login with the identity provider failed")
+	ErrOIDCEmailRequired = errors.New("The identity provider did not share an email address")
+	ErrOIDCAccountExists = errors.New("An account with this email already exists, log in and link the provider from your profile")
+	ErrIdentityInUse     = errors.New("This identity is already linked to another account")
+	ErrIdentityNotFound  = errors.New("Identity not found")
+)
+
+// OIDCLoginResult is the outcome of a provider callback. Linked is set when
+// the callback attached an identity to an already logged-in user, in which
+// case no tokens are issued.
+type OIDCLoginResult struct {
+	User         *models.User
+	Identity     *models.Identity
+	Linked       bool
+	AccessToken  string
+	RefreshToken string
+}
+
+// OIDCService implements login and account linking with external OpenID Connect providers
+type OIDCService struct {
+	Cfg          config.Config
+	Auth         *AuthService
+	IdentityRepo *repository.IdentityRepository
+	Providers    map[string]*oidc.Provider
+}
+
+func NewOIDCService(cfg config.Config, auth *AuthService, identityRepo *repository.IdentityRepository) *OIDCService {
+	providers := make(map[string]*oidc.Provider, len(cfg.OIDCProviders))
+	for _, providerCfg := range cfg.OIDCProviders {
+		providers[providerCfg.Name] = oidc.NewProvider(providerCfg)
+	}
+
+	return &OIDCService{
+		Cfg:          cfg,
+		Auth:         auth,
+		IdentityRepo: identityRepo,
+		Providers:    providers,
+	}
+}
+
+// ProviderNames returns the configured provider names in alphabetical order
+func (s *OIDCService) ProviderNames() []string {
+	names := make([]string, 0, len(s.Providers))
+	for name := range s.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BeginLogin stores a new state, nonce and PKCE verifier and returns the
+// provider's authorization URL and the state. The caller must bind the
+// state to the browser, which presents it again to HandleCallback. When
+// linkUserID is set, the callback links the identity to that user instead
+// of logging in.
+func (s *OIDCService) BeginLogin(ctx context.Context, providerName string, linkUserID *uuid.UUID) (string, string, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate code verifier: %w", err)
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	record := models.OIDCState{
+		StateHash:    hashToken(state),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(OIDCStateTTL),
+	}
+	if err := s.IdentityRepo.CreateOIDCState(&record); err != nil {
+		return "", "", fmt.Errorf("failed to store login state: %w", err)
+	}
+
+	return authURL, state, nil
+}
+
+// HandleCallback completes the authorization code flow and logs the user in,
+// creating or linking an account as needed. browserState is the state bound
+// to the browser by BeginLogin's caller; a callback that does not come from
+// the browser that started the login is rejected, so nobody can be logged
+// in to another account with a callback URL they were sent.
+func (s *OIDCService) HandleCallback(ctx context.Context, providerName, code, state, browserState string, client ClientInfo) (*OIDCLoginResult, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return nil, ErrInvalidOIDCState
+	}
+
+	pending, err := s.IdentityRepo.ConsumeOIDCState(hashToken(state))
+	if err != nil || pending.Provider != providerName {
+		return nil, ErrInvalidOIDCState
+	}
+
+	tokens, err := provider.Exchange(ctx, code, pending.CodeVerifier)
+	if err != nil {
+		log.Warn().Err(err).Str("provider", providerName).Msg("OIDC code exchange failed")
+		return nil, ErrOIDCLoginFailed
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, tokens.IDToken, pending.Nonce)
+	if err != nil {
+		log.Warn().Err(err).Str("provider", providerName).Msg("OIDC ID token rejected")
+		return nil, ErrOIDCLoginFailed
+	}
+
+	if pending.LinkUserID != nil {
+		return s.linkIdentity(*pending.LinkUserID, providerName, claims)
+	}
+
+	user, identity, err := s.resolveUser(providerName, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, refreshToken, err := s.Auth.finishLogin(user, client, AuthMethodOIDC)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCLoginResult{
+		User:         user,
+		Identity:     identity,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// resolveUser finds the user for a verified ID token. A known identity logs in
+// its user; otherwise an existing account with the same verified email is
+// linked, and failing that a new account is created. An existing account
+// whose owner never verified the email is not linked: whoever registered it
+// may not own the address, and linking would hand them the SSO user.
+func (s *OIDCService) resolveUser(providerName string, claims *oidc.IDTokenClaims) (*models.User, *models.Identity, error) {
+	identity, err := s.IdentityRepo.FindIdentity(providerName, claims.Subject)
+	if err == nil {
+		user, err := s.Auth.UserRepo.FindUserById(identity.UserID.String())
+		if err != nil {
+			return nil, nil, ErrUserNotFound
+		}
+		return user, identity, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fmt.Errorf("failed to look up identity: %w", err)
+	}
+
+	if claims.Email == "" {
+		return nil, nil, ErrOIDCEmailRequired
+	}
+
+	user, err := s.Auth.UserRepo.FindUserByEmail(claims.Email)
+	switch {
+	case err == nil:
+		// Only an email both sides have verified proves the accounts belong together
+		if !claims.EmailVerified || user.EmailVerifiedAt == nil {
+			return nil, nil, ErrOIDCAccountExists
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if user, err = s.createUser(claims); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+
+	identity, err = s.createIdentity(user, providerName, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, identity, nil
+}
+
+func (s *OIDCService) createUser(claims *oidc.IDTokenClaims) (*models.User, error) {
+	// The account gets a random password; the user can set one with the reset flow
+	password, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate password: %w", err)
+	}
+	hashedPassword, err := s.Auth.HashPassword(password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	role, err := s.Auth.RoleRepo.FindRoleByName(models.RoleUser)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load default role: %w", err)
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+
+	user := models.User{
+		Name:     name,
+		Email:    claims.Email,
+		Password: hashedPassword,
+		RoleID:   &role.ID,
+		Role:     role,
+	}
+	if claims.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := s.Auth.UserRepo.CreateUser(&user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return &user, nil
+}
+
+func (s *OIDCService) createIdentity(user *models.User, providerName string, claims *oidc.IDTokenClaims) (*models.Identity, error) {
+	identity := &models.Identity{
+		UserID:   user.ID,
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+	if err := s.IdentityRepo.CreateIdentity(identity); err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	log.Info().Str("user_id", user.ID.String()).Str("provider", providerName).Msg("External identity linked")
+	return identity, nil
+}
+
+func (s *OIDCService) linkIdentity(userID uuid.UUID, providerName string, claims *oidc.IDTokenClaims) (*OIDCLoginResult, error) {
+	user, err := s.Auth.UserRepo.FindUserById(userID.String())
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	identity, err := s.IdentityRepo.FindIdentity(providerName, claims.Subject)
+	switch {
+	case err == nil:
+		if identity.UserID != user.ID {
+			return nil, ErrIdentityInUse
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if identity, err = s.createIdentity(user, providerName, claims); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("failed to look up identity: %w", err)
+	}
+
+	// The signed-in user linked a provider that verified their own address
+	if user.EmailVerifiedAt == nil && claims.EmailVerified && strings.EqualFold(claims.Email, user.Email) {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := s.Auth.UserRepo.UpdateUser(user); err != nil {
+			return nil, fmt.Errorf("failed to mark email as verified: %w", err)
+		}
+	}
+
+	return &OIDCLoginResult{User: user, Identity: identity, Linked: true}, nil
+}
+
+// ListIdentities returns the external identities linked to the user
+func (s *OIDCService) ListIdentities(userID string) ([]models.Identity, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return s.IdentityRepo.FindIdentitiesByUser(id)
+}
+
+// UnlinkIdentity removes one of the user's external identities
+func (s *OIDCService) UnlinkIdentity(userID, identityID string) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	iid, err := uuid.Parse(identityID)
+	if err != nil {
+		return ErrIdentityNotFound
+	}
+
+	deleted, err := s.IdentityRepo.DeleteIdentity(iid, uid)
+	if err != nil {
+		return fmt.Errorf("failed to unlink identity: %w", err)
+	}
+	if !deleted {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
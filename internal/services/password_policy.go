@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"fiber-gorm/internal/breached"
+	"fiber-gorm/internal/config"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/passhash"
+	"fiber-gorm/internal/repository"
+	"fiber-gorm/internal/validators"
+)
+
+// Reasons a new password can be refused besides the configured rules
+var (
+	ErrPasswordReused   = errors.New("Password was used recently, please choose a different one")
+	ErrPasswordBreached = errors.New("Password has appeared in a data breach, please choose a different one")
+)
+
+// PasswordRejectedError is returned when a new password does not satisfy the password policy
+type PasswordRejectedError struct {
+	Err error
+}
+
+func (e *PasswordRejectedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PasswordRejectedError) Unwrap() error {
+	return e.Err
+}
+
+// PasswordPolicy decides whether a password may be chosen: it applies the
+// configured rules, refuses recently used passwords and, when a breached
+// password list is configured, passwords known from data breaches.
+type PasswordPolicy struct {
+	Rules       validators.PasswordPolicy
+	HistorySize int
+	History     *repository.PasswordHistoryRepository
+	Hasher      *passhash.Hasher
+	// Breached is nil when no breached password list is configured
+	Breached *breached.List
+}
+
+// NewPasswordPolicy builds the policy from configuration, indexing the breached password list if one is set
+func NewPasswordPolicy(cfg config.Config, history *repository.PasswordHistoryRepository, hasher *passhash.Hasher) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		Rules:       validators.NewPasswordPolicy(cfg),
+		HistorySize: cfg.PasswordHistorySize,
+		History:     history,
+		Hasher:      hasher,
+	}
+
+	if cfg.PasswordBreachedFile != "" {
+		list, err := breached.Open(cfg.PasswordBreachedFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.Breached = list
+	}
+
+	return policy, nil
+}
+
+// Check returns a PasswordRejectedError if the user may not choose the
+// password. user may be an account that is not created yet.
+func (p *PasswordPolicy) Check(user *models.User, password string) error {
+	if err := p.Rules.ValidatePassword(password, user.Email, user.Name); err != nil {
+		return &PasswordRejectedError{Err: err}
+	}
+
+	if p.Breached != nil {
+		found, err := p.Breached.Contains(password)
+		if err != nil {
+			return fmt.Errorf("failed to check breached passwords: %w", err)
+		}
+		if found {
+			return &PasswordRejectedError{Err: ErrPasswordBreached}
+		}
+	}
+
+	if p.HistorySize > 0 && user.Password != "" {
+		hashes := []string{user.Password}
+		entries, err := p.History.FindRecentPasswords(user.ID, p.HistorySize)
+		if err != nil {
+			return fmt.Errorf("failed to load password history: %w", err)
+		}
+		for _, entry := range entries {
+			hashes = append(hashes, entry.PasswordHash)
+		}
+
+		for _, hash := range hashes {
+			if match, _, _ := p.Hasher.Verify(hash, password); match {
+				return &PasswordRejectedError{Err: ErrPasswordReused}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Remember records the user's current password hash in the history. Failures
+// are logged since the password itself has already been changed.
+func (p *PasswordPolicy) Remember(user *models.User) {
+	if p.HistorySize <= 0 {
+		return
+	}
+
+	if err := p.History.AddPassword(user.ID, user.Password, p.HistorySize); err != nil {
+		log.Error().Err(err).Str("user_id", user.ID.String()).Msg("Failed to record password history")
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"fiber-gorm/internal/config"
+	"fiber-gorm/internal/mailer"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/repository"
+)
+
+const (
+	passwordResetTTL = 30 * time.Minute
+	// passwordResetCooldown limits how often reset emails are sent to one account
+	passwordResetCooldown = time.Minute
+)
+
+// Error types for password management
+var (
+	ErrIncorrectPassword = errors.New("Current password is incorrect")
+)
+
+// PasswordService handles password resets and changes
+type PasswordService struct {
+	Cfg              config.Config
+	Auth             *AuthService
+	UserRepo         *repository.UserRepository
+	RefreshTokenRepo *repository.RefreshTokenRepository
+	TokenRepo        *repository.OneTimeTokenRepository
+	Mailer           mailer.Mailer
+}
+
+func NewPasswordService(cfg config.Config, auth *AuthService, userRepo *repository.UserRepository, refreshTokenRepo *repository.RefreshTokenRepository, tokenRepo *repository.OneTimeTokenRepository, m mailer.Mailer) *PasswordService {
+	return &PasswordService{
+		Cfg:              cfg,
+		Auth:             auth,
+		UserRepo:         userRepo,
+		RefreshTokenRepo: refreshTokenRepo,
+		TokenRepo:        tokenRepo,
+		Mailer:           m,
+	}
+}
+
+// RequestReset emails a short-lived, single-use reset link if the account
+// exists. It never reveals whether the account exists.
+func (s *PasswordService) RequestReset(email string) error {
+	user, err := s.UserRepo.FindUserByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	recent, err := s.TokenRepo.CountOneTimeTokensSince(user.ID, models.TokenPurposePasswordReset, time.Now().Add(-passwordResetCooldown))
+	if err != nil {
+		return fmt.Errorf("failed to check recent reset emails: %w", err)
+	}
+	if recent > 0 {
+		log.Debug().Str("user_id", user.ID.String()).Msg("Password reset recently requested, skipping")
+		return nil
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	if err := s.TokenRepo.InvalidateOneTimeTokens(user.ID, models.TokenPurposePasswordReset); err != nil {
+		return fmt.Errorf("failed to invalidate previous tokens: %w", err)
+	}
+
+	record := models.OneTimeToken{
+		UserID:    user.ID,
+		Purpose:   models.TokenPurposePasswordReset,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}
+	if err := s.TokenRepo.CreateOneTimeToken(&record); err != nil {
+		return fmt.Errorf("failed to store reset token: %w", err)
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", s.Cfg.AppBaseURL, url.QueryEscape(token))
+	return s.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone requested a password reset for your account. Open the link below to choose a new password:\n\n%s\n\nThe link expires in 30 minutes. If you did not request this, you can ignore this email.\n",
+			user.Name, link),
+	})
+}
+
+// ResetPassword sets a new password using a reset token and signs the user
+// out everywhere by revoking all refresh tokens
+func (s *PasswordService) ResetPassword(token, password string) error {
+	// Check the password before consuming the token, so a rejected password
+	// does not use up the reset link
+	record, err := s.TokenRepo.FindOneTimeToken(hashToken(token), models.TokenPurposePasswordReset)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	user, err := s.UserRepo.FindUserById(record.UserID.String())
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if err := s.Auth.Passwords.Check(user, password); err != nil {
+		return err
+	}
+
+	if _, err := s.TokenRepo.ConsumeOneTimeToken(hashToken(token), models.TokenPurposePasswordReset); err != nil {
+		return ErrInvalidToken
+	}
+
+	if err := s.setPassword(user, password); err != nil {
+		return err
+	}
+
+	if err := s.RefreshTokenRepo.RevokeAllForUser(user.ID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return nil
+}
+
+// ChangePassword replaces the password of an authenticated user after checking the current one
+func (s *PasswordService) ChangePassword(userID, currentPassword, newPassword string) error {
+	user, err := s.UserRepo.FindUserById(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if err := s.Auth.ComparePassword(user.Password, currentPassword); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	if err := s.Auth.Passwords.Check(user, newPassword); err != nil {
+		return err
+	}
+
+	return s.setPassword(user, newPassword)
+}
+
+func (s *PasswordService) setPassword(user *models.User, password string) error {
+	hashedPassword, err := s.Auth.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user.Password = hashedPassword
+	if err := s.UserRepo.UpdateUser(user); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	s.Auth.Passwords.Remember(user)
+
+	return nil
+}
+
+// generateToken returns a random URL-safe token with 256 bits of entropy
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/repository"
+)
+
+// ErrSessionNotFound is returned for sessions that do not exist, belong to
+// another user or have already ended
+var ErrSessionNotFound = errors.New("Session not found")
+
+// SessionService lists and revokes a user's logins
+type SessionService struct {
+	Verifier         *TokenVerifier
+	SessionRepo      *repository.SessionRepository
+	RefreshTokenRepo *repository.RefreshTokenRepository
+}
+
+func NewSessionService(verifier *TokenVerifier, sessionRepo *repository.SessionRepository, refreshTokenRepo *repository.RefreshTokenRepository) *SessionService {
+	return &SessionService{
+		Verifier:         verifier,
+		SessionRepo:      sessionRepo,
+		RefreshTokenRepo: refreshTokenRepo,
+	}
+}
+
+// ListSessions returns the user's active sessions, flagging currentSessionID
+func (s *SessionService) ListSessions(userID, currentSessionID string) ([]models.Session, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	sessions, err := s.SessionRepo.FindActiveSessionsByUser(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID.String() == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one of the user's sessions so its refresh token stops
+// working. When current belongs to that session its access token is revoked too.
+func (s *SessionService) RevokeSession(userID, sessionID string, current *TokenClaims) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	sid, err := uuid.Parse(sessionID)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+
+	if _, err := s.SessionRepo.FindActiveSessionForUser(sid, uid); err != nil {
+		return ErrSessionNotFound
+	}
+
+	if err := s.RefreshTokenRepo.RevokeFamily(sid); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	if current != nil && current.SessionID == sessionID {
+		if err := s.Verifier.Revoke(current); err != nil {
+			return fmt.Errorf("failed to revoke access token: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// RevokeOtherSessions ends every session of the user except the current one
+func (s *SessionService) RevokeOtherSessions(current *TokenClaims) error {
+	uid, err := uuid.Parse(current.Subject)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	sid, err := uuid.Parse(current.SessionID)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+
+	if err := s.RefreshTokenRepo.RevokeAllForUserExcept(uid, sid); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// DeviceLabel turns a User-Agent header into a short description such as "Firefox on Windows"
+func DeviceLabel(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	ua := strings.ToLower(userAgent)
+
+	browser := ""
+	for _, candidate := range []struct{ token, name string }{
+		{"edg/", "Edge"},
+		{"opr/", "Opera"},
+		{"firefox/", "Firefox"},
+		{"chrome/", "Chrome"},
+		{"safari/", "Safari"},
+		{"curl/", "curl"},
+		{"go-http-client", "Go client"},
+		{"python-requests", "Python client"},
+		{"postman", "Postman"},
+	} {
+		if strings.Contains(ua, candidate.token) {
+			browser = candidate.name
+			break
+		}
+	}
+
+	platform := ""
+	for _, candidate := range []struct{ token, name string }{
+		{"iphone", "iPhone"},
+		{"ipad", "iPad"},
+		{"android", "Android"},
+		{"windows", "Windows"},
+		{"mac os", "macOS"},
+		{"cros", "ChromeOS"},
+		{"linux", "Linux"},
+	} {
+		if strings.Contains(ua, candidate.token) {
+			platform = candidate.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+
+	// Fall back to the product token, e.g. "MyApp/1.2"
+	if fields := strings.Fields(userAgent); len(fields) > 0 && len(fields[0]) <= 40 {
+		return fields[0]
+	}
+	return "Unknown device"
+}
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fiber-gorm/internal/models"
+)
+
+// MaxPlanTasks limits the number of tasks a plan is requested for
+const MaxPlanTasks = 100
+
+// Error types for subtasks and dependencies
+var (
+	ErrParentNotFound     = errors.New("Parent task not found")
+	ErrSubtaskCycle       = errors.New("A task cannot be a subtask of itself or of its own subtasks")
+	ErrDependencyNotFound = errors.New("Dependency not found")
+	ErrDoneTaskBlocked    = errors.New("A done task cannot be blocked by an open task")
+	ErrPlanTooLarge       = fmt.Errorf("A plan can include at most %d tasks", MaxPlanTasks)
+)
+
+// DependencyCycleError is returned for a dependency that would close a
+// cycle. Cycle lists the tasks of the cycle, starting and ending with the
+// task that would be blocked.
+type DependencyCycleError struct {
+	Cycle []uuid.UUID
+}
+
+func (e *DependencyCycleError) Error() string {
+	return "The dependency would create a cycle"
+}
+
+// BlockedTaskError is returned when a task with open blockers is moved to done
+type BlockedTaskError struct {
+	BlockedBy []uuid.UUID
+}
+
+func (e *BlockedTaskError) Error() string {
+	return fmt.Sprintf("Task is blocked by %d open tasks", len(e.BlockedBy))
+}
+
+// checkParent checks that parentID is another task of the user that is not
+// one of the task's subtasks, at any depth. taskID is uuid.Nil for a new task.
+// It must run under the user's task lock, together with saving the parent.
+func (s *TaskService) checkParent(userID, taskID uuid.UUID, parentID *uuid.UUID) error {
+	if parentID == nil {
+		return nil
+	}
+
+	// The walk up also stops at a cycle that is already stored
+	visited := map[uuid.UUID]bool{}
+	for id := *parentID; ; {
+		if id == taskID || visited[id] {
+			return ErrSubtaskCycle
+		}
+		visited[id] = true
+		parent, err := s.findTask(userID, id)
+		if errors.Is(err, ErrTaskNotFound) {
+			return ErrParentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if parent.ParentID == nil {
+			return nil
+		}
+		id = *parent.ParentID
+	}
+}
+
+// addProgress sets the subtask progress of the tasks that have subtasks
+func (s *TaskService) addProgress(userID uuid.UUID, tasks ...*models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	progress, err := s.Repo.CountSubtasks(userID, ids)
+	if err != nil {
+		return fmt.Errorf("failed to count subtasks: %w", err)
+	}
+	for _, task := range tasks {
+		task.Progress = progress[task.ID]
+	}
+	return nil
+}
+
+// ListSubtasks returns the subtasks of one of the user's tasks
+func (s *TaskService) ListSubtasks(userID, id string) ([]models.Task, error) {
+	task, err := s.GetTask(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	subtasks, err := s.Repo.FindSubtasks(task.UserID, task.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subtasks: %w", err)
+	}
+	pointers := make([]*models.Task, len(subtasks))
+	for i := range subtasks {
+		pointers[i] = &subtasks[i]
+	}
+	return subtasks, s.addProgress(task.UserID, pointers...)
+}
+
+// ListBlockers returns the tasks one of the user's tasks is blocked by
+func (s *TaskService) ListBlockers(userID, id string) ([]models.Task, error) {
+	task, err := s.GetTask(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Repo.FindBlockers(task)
+}
+
+// AddDependency makes one of the user's tasks blocked by another. A
+// dependency that would make a task wait for itself, directly or through
+// other tasks, is refused with a DependencyCycleError, and so is an open
+// blocker for a task that is done. The check and the
+// insert run under the user's task lock, so concurrent dependencies in
+// opposite directions cannot both be added.
+func (s *TaskService) AddDependency(userID, id string, payload *models.AddTaskDependencyPayload) (*models.TaskDependency, error) {
+	owner, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrTaskNotFound
+	}
+
+	var dependency *models.TaskDependency
+	err = s.lockTasks(owner, func(s *TaskService) error {
+		task, err := s.GetTask(userID, id)
+		if err != nil {
+			return err
+		}
+		blocker, err := s.findTask(task.UserID, payload.BlockedByID)
+		if err != nil {
+			return err
+		}
+		if task.Status == models.TaskStatusDone && !blocker.Finished() {
+			return ErrDoneTaskBlocked
+		}
+
+		dependencies, err := s.Repo.FindDependencies(task.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to load task dependencies: %w", err)
+		}
+		if path := dependencyPath(blockersOf(dependencies), blocker.ID, task.ID); path != nil {
+			return &DependencyCycleError{Cycle: append([]uuid.UUID{task.ID}, path...)}
+		}
+
+		dependency = &models.TaskDependency{TaskID: task.ID, BlockedByID: blocker.ID, CreatedAt: time.Now()}
+		if err := s.Repo.CreateDependency(dependency); err != nil {
+			return fmt.Errorf("failed to create task dependency: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dependency, nil
+}
+
+// RemoveDependency removes a dependency of one of the user's tasks
+func (s *TaskService) RemoveDependency(userID, id, blockedByID string) error {
+	task, err := s.GetTask(userID, id)
+	if err != nil {
+		return err
+	}
+	blocker, err := uuid.Parse(blockedByID)
+	if err != nil {
+		return ErrDependencyNotFound
+	}
+
+	removed, err := s.Repo.DeleteDependency(task.ID, blocker)
+	if err != nil {
+		return fmt.Errorf("failed to delete task dependency: %w", err)
+	}
+	if !removed {
+		return ErrDependencyNotFound
+	}
+	return nil
+}
+
+// checkBlockers returns a BlockedTaskError when the task has unfinished blockers
+func (s *TaskService) checkBlockers(task *models.Task) error {
+	blockers, err := s.Repo.FindBlockers(task)
+	if err != nil {
+		return fmt.Errorf("failed to load task blockers: %w", err)
+	}
+
+	var open []uuid.UUID
+	for _, blocker := range blockers {
+		if !blocker.Finished() {
+			open = append(open, blocker.ID)
+		}
+	}
+	if len(open) > 0 {
+		return &BlockedTaskError{BlockedBy: open}
+	}
+	return nil
+}
+
+// PlanTasks returns the given tasks of the user together with the
+// unfinished tasks they are blocked by, directly or indirectly, ordered so
+// that every task comes after its blockers. Among tasks that are ready at
+// the same point, earlier due dates and then higher priorities come first.
+// Each task lists its open blockers in BlockedBy.
+func (s *TaskService) PlanTasks(userID string, ids []string) ([]models.Task, error) {
+	owner, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrTaskNotFound
+	}
+	if len(ids) > MaxPlanTasks {
+		return nil, ErrPlanTooLarge
+	}
+
+	requested := make(map[uuid.UUID]bool)
+	for _, id := range ids {
+		taskID, err := uuid.Parse(strings.TrimSpace(id))
+		if err != nil {
+			return nil, ErrTaskNotFound
+		}
+		requested[taskID] = true
+	}
+
+	dependencies, err := s.Repo.FindDependencies(owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load task dependencies: %w", err)
+	}
+	blockers := blockersOf(dependencies)
+
+	// Everything the requested tasks may wait for
+	var closure []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for id := range requested {
+		seen[id] = true
+		closure = append(closure, id)
+	}
+	for i := 0; i < len(closure); i++ {
+		for _, blocker := range blockers[closure[i]] {
+			if !seen[blocker] {
+				seen[blocker] = true
+				closure = append(closure, blocker)
+			}
+		}
+	}
+
+	tasks, err := s.Repo.FindTasksByIDs(owner, closure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %w", err)
+	}
+	byID := make(map[uuid.UUID]*models.Task, len(tasks))
+	for i := range tasks {
+		byID[tasks[i].ID] = &tasks[i]
+	}
+	for id := range requested {
+		if byID[id] == nil {
+			return nil, ErrTaskNotFound
+		}
+	}
+
+	// Keep the requested tasks and the open tasks reachable through open blockers
+	planned := make(map[uuid.UUID]*models.Task)
+	queue := make([]uuid.UUID, 0, len(requested))
+	for id := range requested {
+		planned[id] = byID[id]
+		queue = append(queue, id)
+	}
+	for i := 0; i < len(queue); i++ {
+		task := planned[queue[i]]
+		for _, id := range blockers[task.ID] {
+			blocker := byID[id]
+			if blocker == nil || blocker.Finished() {
+				continue
+			}
+			task.BlockedBy = append(task.BlockedBy, id)
+			if planned[id] == nil {
+				planned[id] = blocker
+				queue = append(queue, id)
+			}
+		}
+	}
+
+	return topologicalOrder(planned)
+}
+
+// topologicalOrder orders the tasks after the tasks in their BlockedBy
+// with Kahn's algorithm
+func topologicalOrder(tasks map[uuid.UUID]*models.Task) ([]models.Task, error) {
+	waiting := make(map[uuid.UUID]int, len(tasks))
+	unblocks := make(map[uuid.UUID][]uuid.UUID)
+	var ready []*models.Task
+	for _, task := range tasks {
+		waiting[task.ID] = len(task.BlockedBy)
+		for _, blocker := range task.BlockedBy {
+			unblocks[blocker] = append(unblocks[blocker], task.ID)
+		}
+		if len(task.BlockedBy) == 0 {
+			ready = append(ready, task)
+		}
+	}
+
+	plan := make([]models.Task, 0, len(tasks))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return plansBefore(ready[i], ready[j]) })
+		task := ready[0]
+		ready = ready[1:]
+		plan = append(plan, *task)
+
+		for _, id := range unblocks[task.ID] {
+			waiting[id]--
+			if waiting[id] == 0 {
+				ready = append(ready, tasks[id])
+			}
+		}
+	}
+
+	// Dependencies are checked for cycles when they are added
+	if len(plan) != len(tasks) {
+		return nil, errors.New("task dependencies contain a cycle")
+	}
+	return plan, nil
+}
+
+var priorityRank = map[string]int{
+	models.TaskPriorityUrgent: 0,
+	models.TaskPriorityHigh:   1,
+	models.TaskPriorityMedium: 2,
+	models.TaskPriorityLow:    3,
+}
+
+// plansBefore orders ready tasks by due date (undated last), priority and age
+func plansBefore(a, b *models.Task) bool {
+	switch {
+	case a.DueAt != nil && b.DueAt == nil:
+		return true
+	case a.DueAt == nil && b.DueAt != nil:
+		return false
+	case a.DueAt != nil && !a.DueAt.Equal(*b.DueAt):
+		return a.DueAt.Before(*b.DueAt)
+	case priorityRank[a.Priority] != priorityRank[b.Priority]:
+		return priorityRank[a.Priority] < priorityRank[b.Priority]
+	default:
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+}
+
+// blockersOf maps each task to the tasks it is blocked by
+func blockersOf(dependencies []models.TaskDependency) map[uuid.UUID][]uuid.UUID {
+	blockers := make(map[uuid.UUID][]uuid.UUID)
+	for _, dependency := range dependencies {
+		blockers[dependency.TaskID] = append(blockers[dependency.TaskID], dependency.BlockedByID)
+	}
+	return blockers
+}
+
+// dependencyPath returns the tasks on a chain of blockers from one task to
+// another, both included, or nil when from does not wait for to
+func dependencyPath(blockers map[uuid.UUID][]uuid.UUID, from, to uuid.UUID) []uuid.UUID {
+	visited := make(map[uuid.UUID]bool)
+	var visit func(id uuid.UUID) []uuid.UUID
+	visit = func(id uuid.UUID) []uuid.UUID {
+		if id == to {
+			return []uuid.UUID{id}
+		}
+		if visited[id] {
+			return nil
+		}
+		visited[id] = true
+		for _, blocker := range blockers[id] {
+			if path := visit(blocker); path != nil {
+				return append([]uuid.UUID{id}, path...)
+			}
+		}
+		return nil
+	}
+	return visit(from)
+}
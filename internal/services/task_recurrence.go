@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/rrule"
+)
+
+// ErrRecurrenceScope is returned when the recurrence of a single occurrence is edited
+var ErrRecurrenceScope = errors.New("The recurrence can only be changed for all future occurrences")
+
+// ErrRecurrenceDueDate is returned when a recurring task loses its due date
+var ErrRecurrenceDueDate = errors.New("A recurring task needs a due date")
+
+// applyTaskFields copies the editable fields of the payload to the task
+func applyTaskFields(task *models.Task, payload *models.UpdateTaskPayload) {
+	task.Name = payload.Name
+	task.Priority = payload.Priority
+	if task.Priority == "" {
+		task.Priority = models.TaskPriorityMedium
+	}
+	task.StartAt = payload.StartAt
+	task.DueAt = payload.DueAt
+	task.EstimatedMinutes = payload.EstimatedMinutes
+	task.ParentID = payload.ParentID
+}
+
+// recurrenceRule returns the rule as stored, without the optional "RRULE:" prefix
+func recurrenceRule(rule string) string {
+	return strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+}
+
+func recurrenceTimeZone(tz string) string {
+	if tz == "" {
+		return "UTC"
+	}
+	return tz
+}
+
+// startSeries makes the task the first occurrence of a new series that
+// starts at its due date
+func startSeries(task *models.Task, rule, tz string) {
+	series := &models.TaskSeries{
+		ID:             uuid.New(),
+		UserID:         task.UserID,
+		RecurrenceRule: rule,
+		TimeZone:       tz,
+		StartsAt:       *task.DueAt,
+	}
+	copySeriesFields(series, task)
+
+	task.SeriesID = &series.ID
+	task.Series = series
+	task.OccurrenceAt = task.DueAt
+}
+
+// copySeriesFields makes the task the template of the series' next occurrences
+func copySeriesFields(series *models.TaskSeries, task *models.Task) {
+	series.Name = task.Name
+	series.Priority = task.Priority
+	series.EstimatedMinutes = task.EstimatedMinutes
+	series.StartLead = nil
+	if task.StartAt != nil && task.DueAt != nil {
+		lead := task.DueAt.Sub(*task.StartAt)
+		series.StartLead = &lead
+	}
+}
+
+// updateSeries applies an edit to this and all future occurrences. When the
+// schedule changes (rule, time zone or due date), this occurrence starts a
+// new series and the later occurrences of the old one are deleted; the old
+// series stays with the finished occurrences. A series that keeps its rule
+// keeps the occurrences its COUNT has left, and an empty rule ends the
+// recurrence. Otherwise the edit is copied to the series and the later
+// occurrences, which keep their own dates.
+func (s *TaskService) updateSeries(task *models.Task, payload *models.UpdateTaskPayload, rule, tz string, scheduleChanged bool) error {
+	if scheduleChanged && rule != "" && payload.DueAt == nil {
+		return ErrRecurrenceDueDate
+	}
+
+	later, err := s.Repo.FindLaterOccurrences(task.UserID, *task.SeriesID, *task.OccurrenceAt)
+	if err != nil {
+		return fmt.Errorf("failed to load task occurrences: %w", err)
+	}
+
+	if scheduleChanged && rule == task.Series.RecurrenceRule {
+		if rule, err = remainingRule(task.Series, *task.OccurrenceAt); err != nil {
+			return err
+		}
+	}
+
+	applyTaskFields(task, payload)
+	if scheduleChanged {
+		task.SeriesID, task.Series, task.OccurrenceAt = nil, nil, nil
+		if rule != "" {
+			startSeries(task, rule, tz)
+		}
+		if err := s.Repo.UpdateTaskSeries(task.Series, []*models.Task{task}, later); err != nil {
+			return fmt.Errorf("failed to update task series: %w", err)
+		}
+		return nil
+	}
+
+	copySeriesFields(task.Series, task)
+	tasks := []*models.Task{task}
+	for i := range later {
+		occurrence := &later[i]
+		occurrence.Name = task.Name
+		occurrence.Priority = task.Priority
+		occurrence.EstimatedMinutes = task.EstimatedMinutes
+		tasks = append(tasks, occurrence)
+	}
+	if err := s.Repo.UpdateTaskSeries(task.Series, tasks, nil); err != nil {
+		return fmt.Errorf("failed to update task series: %w", err)
+	}
+	return nil
+}
+
+// remainingRule returns the series' rule for a series that continues it from
+// the given occurrence, with COUNT reduced by the occurrences before it
+func remainingRule(series *models.TaskSeries, occurrenceAt time.Time) (string, error) {
+	rule, err := rrule.Parse(series.RecurrenceRule)
+	if err != nil {
+		return "", fmt.Errorf("invalid recurrence rule: %w", err)
+	}
+	if rule.Count == 0 {
+		return series.RecurrenceRule, nil
+	}
+	loc, err := time.LoadLocation(series.TimeZone)
+	if err != nil {
+		return "", fmt.Errorf("invalid recurrence time zone: %w", err)
+	}
+
+	// This occurrence counts as the first of the new series
+	remaining := max(rule.Count-rule.CountBefore(series.StartsAt, occurrenceAt, loc), 1)
+	return rrule.WithCount(series.RecurrenceRule, remaining), nil
+}
+
+// nextOccurrence returns the occurrence of the task's series that follows
+// it, not yet created, or nil when the series has ended. It stays a subtask
+// of the task's parent.
+func nextOccurrence(task *models.Task) (*models.Task, error) {
+	series := task.Series
+	rule, err := rrule.Parse(series.RecurrenceRule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recurrence rule: %w", err)
+	}
+	loc, err := time.LoadLocation(series.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recurrence time zone: %w", err)
+	}
+
+	dueAt, ok := rule.Next(series.StartsAt, *task.OccurrenceAt, loc)
+	if !ok {
+		return nil, nil
+	}
+
+	next := &models.Task{
+		ID:               uuid.New(),
+		UserID:           task.UserID,
+		Name:             series.Name,
+		Status:           models.TaskStatusTodo,
+		Priority:         series.Priority,
+		DueAt:            &dueAt,
+		EstimatedMinutes: series.EstimatedMinutes,
+		SeriesID:         &series.ID,
+		Series:           series,
+		OccurrenceAt:     &dueAt,
+		ParentID:         task.ParentID,
+	}
+	if series.StartLead != nil {
+		startAt := dueAt.Add(-*series.StartLead)
+		next.StartAt = &startAt
+	}
+	return next, nil
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+
+	"fiber-gorm/internal/config"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/notifier"
+	"fiber-gorm/internal/repository"
+)
+
+// reminderBatchSize limits how many reminders one poll sends
+const reminderBatchSize = 100
+
+// MaxReminderAttempts is how often a reminder is tried before it is given up
+const MaxReminderAttempts = 8
+
+// reminderRetryDelay is the wait after the first failed delivery; it doubles
+// with every further failure up to maxReminderRetryDelay
+const (
+	reminderRetryDelay    = time.Minute
+	maxReminderRetryDelay = 6 * time.Hour
+)
+
+// errOwnerNotFound marks reminders whose task owner was deleted
+var errOwnerNotFound = errors.New("task owner not found")
+
+// TaskReminderScheduler notifies owners a while before their tasks are due.
+// It runs inside the server process and polls the database, so pending
+// reminders survive restarts. Each reminder is claimed with a conditional
+// update before it is sent, so when several instances poll the same
+// database only one of them sends it.
+type TaskReminderScheduler struct {
+	Repo     *repository.TaskRepository
+	UserRepo *repository.UserRepository
+	Notifier notifier.Notifier
+	// Before is how long before the due date the reminder is sent
+	Before       time.Duration
+	PollInterval time.Duration
+}
+
+func NewTaskReminderScheduler(cfg config.Config, repo *repository.TaskRepository, userRepo *repository.UserRepository, n notifier.Notifier) *TaskReminderScheduler {
+	return &TaskReminderScheduler{
+		Repo:         repo,
+		UserRepo:     userRepo,
+		Notifier:     n,
+		Before:       cfg.TaskReminderBefore,
+		PollInterval: cfg.TaskReminderPollInterval,
+	}
+}
+
+// Run sends due reminders every PollInterval until ctx is cancelled
+func (s *TaskReminderScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.SendDue(time.Now()); err != nil {
+			log.Error().Err(err).Msg("Failed to send task reminders")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue sends the reminders of unfinished tasks due within Before of now
+// and returns how many were sent. Overdue tasks are skipped, so a task
+// created or moved past its due date, or one whose retries ran past it, gets
+// no "due soon" reminder. A reminder that cannot be delivered is
+// released again and retried with exponential backoff, and given up after
+// MaxReminderAttempts, so failing reminders cannot crowd out the others.
+func (s *TaskReminderScheduler) SendDue(now time.Time) (int, error) {
+	tasks, err := s.Repo.FindTasksToRemind(now.Add(s.Before), now, MaxReminderAttempts, reminderBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find tasks to remind: %w", err)
+	}
+
+	sent := 0
+	for i := range tasks {
+		task := &tasks[i]
+		claimed, err := s.Repo.ClaimReminder(task.ID, now)
+		if err != nil {
+			return sent, fmt.Errorf("failed to claim task reminder: %w", err)
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := s.notify(task); err != nil {
+			s.deferReminder(task, now, err)
+			continue
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// deferReminder schedules the next attempt of a reminder that failed. A
+// reminder whose owner does not exist cannot succeed and is given up at once.
+func (s *TaskReminderScheduler) deferReminder(task *models.Task, now time.Time, cause error) {
+	attempts := task.ReminderAttempts + 1
+	if errors.Is(cause, errOwnerNotFound) {
+		attempts = MaxReminderAttempts
+	}
+
+	delay := reminderRetryDelay
+	for i := 1; i < attempts && delay < maxReminderRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxReminderRetryDelay)
+
+	logger := log.Error().Err(cause).Str("task_id", task.ID.String()).Int("attempts", attempts)
+	if attempts >= MaxReminderAttempts {
+		logger.Msg("Giving up on task reminder")
+	} else {
+		logger.Time("retry_at", now.Add(delay)).Msg("Failed to send task reminder")
+	}
+
+	if err := s.Repo.DeferReminder(task.ID, attempts, now.Add(delay)); err != nil {
+		log.Error().Err(err).Str("task_id", task.ID.String()).Msg("Failed to release task reminder")
+	}
+}
+
+func (s *TaskReminderScheduler) notify(task *models.Task) error {
+	user, err := s.UserRepo.FindUserById(task.UserID.String())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errOwnerNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load task owner: %w", err)
+	}
+
+	return s.Notifier.Notify(notifier.Reminder{
+		UserID:   user.ID,
+		Email:    user.Email,
+		Name:     user.Name,
+		TaskID:   task.ID,
+		TaskName: task.Name,
+		DueAt:    *task.DueAt,
+	})
+}
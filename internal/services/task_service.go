@@ -0,0 +1,342 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/repository"
+)
+
+// Error types for tasks
+var (
+	ErrTaskNotFound = errors.New("Task not found")
+	ErrTaskConflict = errors.New("Task was changed concurrently, try again")
+)
+
+// TaskService manages the tasks of the authenticated user. Tasks of other
+// users are reported as not found rather than forbidden, so task IDs cannot
+// be probed.
+type TaskService struct {
+	Repo     *repository.TaskRepository
+	Workflow *TaskWorkflow
+}
+
+func NewTaskService(repo *repository.TaskRepository, workflow *TaskWorkflow) *TaskService {
+	return &TaskService{Repo: repo, Workflow: workflow}
+}
+
+// CreateTask creates a task owned by the user
+func (s *TaskService) CreateTask(userID string, payload *models.CreateTaskPayload) (*models.Task, error) {
+	owner, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	task := &models.Task{
+		UserID:           owner,
+		Name:             payload.Name,
+		Status:           models.TaskStatusTodo,
+		Priority:         payload.Priority,
+		StartAt:          payload.StartAt,
+		DueAt:            payload.DueAt,
+		EstimatedMinutes: payload.EstimatedMinutes,
+		ParentID:         payload.ParentID,
+	}
+	if task.Priority == "" {
+		task.Priority = models.TaskPriorityMedium
+	}
+	if rule := recurrenceRule(payload.RecurrenceRule); rule != "" {
+		startSeries(task, rule, recurrenceTimeZone(payload.RecurrenceTimeZone))
+	}
+
+	err = s.lockTasks(owner, func(s *TaskService) error {
+		if err := s.checkParent(owner, uuid.Nil, payload.ParentID); err != nil {
+			return err
+		}
+		if err := s.Repo.CreateTask(task); err != nil {
+			return fmt.Errorf("failed to create task: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// lockTasks runs fn with a copy of the service whose repository works in a
+// transaction that holds the user's task lock (see TaskRepository.LockTasks)
+func (s *TaskService) lockTasks(userID uuid.UUID, fn func(s *TaskService) error) error {
+	return s.Repo.LockTasks(userID, func(repo *repository.TaskRepository) error {
+		locked := *s
+		locked.Repo = repo
+		return fn(&locked)
+	})
+}
+
+// ListTasks returns the user's tasks matching the query
+func (s *TaskService) ListTasks(userID string, query *models.TaskListQuery) ([]models.Task, error) {
+	owner, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	loc := time.UTC
+	if query.TimeZone != "" {
+		if loc, err = time.LoadLocation(query.TimeZone); err != nil {
+			return nil, fmt.Errorf("failed to load time zone: %w", err)
+		}
+	}
+
+	filter := models.TaskFilter{Status: query.Status, Priority: query.Priority}
+	now := time.Now().In(loc)
+	switch query.Due {
+	case "overdue":
+		filter.DueBefore = &now
+		filter.Unfinished = true
+	case "this_week":
+		start, end := weekOf(now)
+		filter.DueAfter = &start
+		filter.DueBefore = &end
+	}
+
+	tasks, err := s.Repo.FindTasksByUser(owner, filter)
+	if err != nil {
+		return nil, err
+	}
+	pointers := make([]*models.Task, len(tasks))
+	for i := range tasks {
+		pointers[i] = &tasks[i]
+	}
+	return tasks, s.addProgress(owner, pointers...)
+}
+
+// weekOf returns the start of the Monday-based week containing t, in t's
+// location, and the start of the following week
+func weekOf(t time.Time) (time.Time, time.Time) {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	start := time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 0, 7)
+}
+
+// GetTask returns one of the user's tasks with the progress of its subtasks
+func (s *TaskService) GetTask(userID, id string) (*models.Task, error) {
+	owner, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrTaskNotFound
+	}
+	taskID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrTaskNotFound
+	}
+
+	task, err := s.findTask(owner, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	return task, s.addProgress(owner, task)
+}
+
+func (s *TaskService) findTask(userID, id uuid.UUID) (*models.Task, error) {
+	task, err := s.Repo.FindTaskForUser(id, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTaskNotFound
+		}
+		return nil, fmt.Errorf("failed to load task: %w", err)
+	}
+
+	return task, nil
+}
+
+// UpdateTask replaces the editable fields of one of the user's tasks. For
+// an occurrence of a recurring task, scope selects whether only this
+// occurrence changes or all future ones too (see updateSeries).
+func (s *TaskService) UpdateTask(userID, id, scope string, payload *models.UpdateTaskPayload) (*models.Task, error) {
+	owner, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrTaskNotFound
+	}
+
+	var task *models.Task
+	err = s.lockTasks(owner, func(s *TaskService) error {
+		var err error
+		task, err = s.updateTask(userID, id, scope, payload)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (s *TaskService) updateTask(userID, id, scope string, payload *models.UpdateTaskPayload) (*models.Task, error) {
+	task, err := s.GetTask(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkParent(task.UserID, task.ID, payload.ParentID); err != nil {
+		return nil, err
+	}
+
+	// A new due date gets a new reminder
+	dueChanged := !sameTime(task.DueAt, payload.DueAt)
+
+	// The recurrence is only changed when the payload names it
+	rule, tz := "", recurrenceTimeZone(payload.RecurrenceTimeZone)
+	if task.Series != nil {
+		rule = task.Series.RecurrenceRule
+		if payload.RecurrenceTimeZone == "" {
+			tz = task.Series.TimeZone
+		}
+	}
+	if payload.RecurrenceRule != nil {
+		rule = recurrenceRule(*payload.RecurrenceRule)
+	}
+	recurrenceChanged := task.Series != nil && (rule != task.Series.RecurrenceRule || tz != task.Series.TimeZone)
+
+	switch {
+	case task.Series != nil && scope == models.TaskEditScopeFuture:
+		if err := s.updateSeries(task, payload, rule, tz, dueChanged || recurrenceChanged); err != nil {
+			return nil, err
+		}
+	case recurrenceChanged:
+		return nil, ErrRecurrenceScope
+	case task.Series == nil && rule != "":
+		applyTaskFields(task, payload)
+		startSeries(task, rule, tz)
+		if err := s.Repo.UpdateTaskSeries(task.Series, []*models.Task{task}, nil); err != nil {
+			return nil, fmt.Errorf("failed to update task: %w", err)
+		}
+	default:
+		applyTaskFields(task, payload)
+		if err := s.Repo.UpdateTask(task); err != nil {
+			return nil, fmt.Errorf("failed to update task: %w", err)
+		}
+	}
+
+	if dueChanged {
+		if err := s.Repo.ResetReminder(task.ID); err != nil {
+			return nil, fmt.Errorf("failed to reset task reminder: %w", err)
+		}
+		task.ReminderSentAt = nil
+	}
+
+	return task, nil
+}
+
+// DeleteTask deletes one of the user's tasks
+func (s *TaskService) DeleteTask(userID, id string) error {
+	task, err := s.GetTask(userID, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Repo.DeleteTask(task); err != nil {
+		return fmt.Errorf("failed to delete task: %w", err)
+	}
+
+	return nil
+}
+
+// TransitionTask moves one of the user's tasks to another status if the
+// workflow allows it. A task cannot be done while it has open blockers.
+// Reaching a terminal status stamps FinishedAt and leaving one clears it.
+// The history names actorID, which differs from the owner while an admin
+// impersonates them. It runs under the user's task lock, so a blocker added
+// at the same time is either seen or refused.
+func (s *TaskService) TransitionTask(userID, actorID, id string, payload *models.TransitionTaskPayload) (*models.Task, error) {
+	owner, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrTaskNotFound
+	}
+
+	var task *models.Task
+	err = s.lockTasks(owner, func(s *TaskService) error {
+		var err error
+		task, err = s.transitionTask(userID, actorID, id, payload)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (s *TaskService) transitionTask(userID, actorID, id string, payload *models.TransitionTaskPayload) (*models.Task, error) {
+	task, err := s.GetTask(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	actor, err := uuid.Parse(actorID)
+	if err != nil {
+		actor = task.UserID
+	}
+
+	if err := s.Workflow.Check(task.Status, payload.Status); err != nil {
+		return nil, err
+	}
+	if payload.Status == models.TaskStatusDone {
+		if err := s.checkBlockers(task); err != nil {
+			return nil, err
+		}
+	}
+
+	wasFinished := task.Finished()
+	now := time.Now()
+	if models.TerminalTaskStatus(payload.Status) {
+		task.FinishedAt = &now
+	} else {
+		task.FinishedAt = nil
+	}
+
+	// Finishing an occurrence creates the next one along with the transition
+	var next *models.Task
+	if task.Series != nil && models.TerminalTaskStatus(payload.Status) && !wasFinished {
+		if next, err = nextOccurrence(task); err != nil {
+			return nil, err
+		}
+	}
+
+	moved, err := s.Repo.TransitionTask(task, &models.TaskTransition{
+		TaskID:     task.ID,
+		FromStatus: task.Status,
+		ToStatus:   payload.Status,
+		ActorID:    actor,
+		Comment:    payload.Comment,
+		CreatedAt:  now,
+	}, next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transition task: %w", err)
+	}
+	if !moved {
+		return nil, ErrTaskConflict
+	}
+
+	return task, nil
+}
+
+// ListTransitions returns the status history of one of the user's tasks
+func (s *TaskService) ListTransitions(userID, id string) ([]models.TaskTransition, error) {
+	task, err := s.GetTask(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Repo.FindTaskTransitions(task.ID)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"fiber-gorm/internal/config"
+	"fiber-gorm/internal/models"
+)
+
+// DefaultTaskTransitions is the workflow used when TASK_TRANSITIONS is not set
+const DefaultTaskTransitions = "todo>in_progress, todo>blocked, todo>cancelled, " +
+	"in_progress>review, in_progress>blocked, in_progress>todo, in_progress>cancelled, " +
+	"review>done, review>in_progress, review>cancelled, " +
+	"blocked>todo, blocked>in_progress, blocked>cancelled, " +
+	"done>todo, cancelled>todo"
+
+// InvalidTransitionError is returned for a status change the workflow does not allow
+type InvalidTransitionError struct {
+	From    string
+	To      string
+	Allowed []string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	if len(e.Allowed) == 0 {
+		return fmt.Sprintf("Cannot move task from %s to %s", e.From, e.To)
+	}
+	return fmt.Sprintf("Cannot move task from %s to %s, allowed: %s", e.From, e.To, strings.Join(e.Allowed, ", "))
+}
+
+// TaskWorkflow is the graph of allowed task status transitions
+type TaskWorkflow struct {
+	Transitions map[string][]string
+}
+
+// NewTaskWorkflow parses the transition graph from the configuration, a
+// comma-separated list of "from>to" edges
+func NewTaskWorkflow(cfg config.Config) (*TaskWorkflow, error) {
+	spec := cfg.TaskTransitions
+	if strings.TrimSpace(spec) == "" {
+		spec = DefaultTaskTransitions
+	}
+
+	workflow := &TaskWorkflow{Transitions: make(map[string][]string)}
+	for _, edge := range config.SplitList(spec) {
+		from, to, ok := strings.Cut(edge, ">")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == to {
+			return nil, fmt.Errorf("invalid task transition %q", edge)
+		}
+		for _, status := range []string{from, to} {
+			if !containsString(models.TaskStatuses, status) {
+				return nil, fmt.Errorf("unknown task status %q in transition %q", status, edge)
+			}
+		}
+		if !containsString(workflow.Transitions[from], to) {
+			workflow.Transitions[from] = append(workflow.Transitions[from], to)
+		}
+	}
+
+	return workflow, nil
+}
+
+// Check returns an InvalidTransitionError unless a task may move from one status to the other
+func (w *TaskWorkflow) Check(from, to string) error {
+	if containsString(w.Transitions[from], to) {
+		return nil
+	}
+	return &InvalidTransitionError{From: from, To: to, Allowed: w.Transitions[from]}
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"fiber-gorm/internal/keys"
+	"fiber-gorm/internal/repository"
+)
+
+// Token types carried in the typ claim
+const (
+	TokenTypeAccess            = "access"
+	TokenTypeRefresh           = "refresh"
+	TokenTypeEmailVerification = "email_verification"
+	TokenTypeMFAChallenge      = "mfa_challenge"
+)
+
+// Authentication methods recorded in the amr claim (RFC 8176, plus "oidc" and
+// "email" for magic links). AuthMethodAPIKey is never put in a JWT; it marks
+// claims built from an API key.
+const (
+	AuthMethodPassword = "pwd"
+	AuthMethodOTP      = "otp"
+	AuthMethodOIDC     = "oidc"
+	AuthMethodEmail    = "email"
+	AuthMethodAPIKey   = "api_key"
+	AuthMethodService  = "hmac"
+)
+
+// tokenAudiences maps each token type to the audience it is issued for,
+// so a token of one type can never be accepted where another is expected
+var tokenAudiences = map[string]string{
+	TokenTypeAccess:            "api",
+	TokenTypeRefresh:           "auth",
+	TokenTypeEmailVerification: "email",
+	TokenTypeMFAChallenge:      "mfa",
+}
+
+// ErrTokenRevoked is returned for access tokens that were revoked before expiry
+var ErrTokenRevoked = errors.New("Token has been revoked")
+
+// ErrDenylistUnavailable is returned when the denylist cannot be checked.
+// It is not a problem with the token itself.
+var ErrDenylistUnavailable = errors.New("Token denylist is unavailable")
+
+// Custom token claims to include additional data
+type TokenClaims struct {
+	jwt.RegisteredClaims
+	Type        string   `json:"typ"`
+	Email       string   `json:"email,omitempty"`
+	Username    string   `json:"username,omitempty"`
+	Role        string   `json:"role,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	SessionID   string   `json:"sid,omitempty"`
+
+	EmailVerified bool     `json:"email_verified,omitempty"`
+	AuthMethods   []string `json:"amr,omitempty"`
+
+	// ClientID and Scope are set on tokens issued to third-party apps (RFC 9068)
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+
+	// Actor identifies the admin behind an impersonation token (RFC 8693)
+	Actor *ActorClaim `json:"act,omitempty"`
+
+	// APIKeyID is set when the request was authenticated with an API key
+	APIKeyID string `json:"-"`
+	// ServiceName is set when the request was signed by an internal service
+	ServiceName string `json:"-"`
+}
+
+// ActorClaim identifies who is acting on behalf of the token subject
+type ActorClaim struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+}
+
+// HasPermission reports whether the token grants the named permission
+func (c *TokenClaims) HasPermission(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAuthMethod reports whether the user authenticated with the given method
+func (c *TokenClaims) HasAuthMethod(method string) bool {
+	for _, m := range c.AuthMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// ThirdParty reports whether the token was issued to a registered OAuth client
+// rather than to the user directly
+func (c *TokenClaims) ThirdParty() bool {
+	return c.ClientID != ""
+}
+
+// ClientCredentials reports whether the token was issued to an OAuth client
+// acting on its own behalf, with no user behind it
+func (c *TokenClaims) ClientCredentials() bool {
+	return c.ThirdParty() && c.Subject == c.ClientID
+}
+
+// Service reports whether the request was made by an internal service rather than a user
+func (c *TokenClaims) Service() bool {
+	return c.ServiceName != ""
+}
+
+// Impersonated reports whether the token was issued to an admin acting as the subject
+func (c *TokenClaims) Impersonated() bool {
+	return c.Actor != nil
+}
+
+// RestrictToScope drops permissions outside the scope granted to a third-party
+// app, so a token can never be used beyond what the user consented to
+func (c *TokenClaims) RestrictToScope() {
+	if !c.ThirdParty() {
+		return
+	}
+
+	scopes := strings.Fields(c.Scope)
+	permissions := make([]string, 0, len(c.Permissions))
+	for _, p := range c.Permissions {
+		if containsString(scopes, p) {
+			permissions = append(permissions, p)
+		}
+	}
+	c.Permissions = permissions
+}
+
+// TokenVerifier is the single place where tokens issued by this service
+// are parsed and checked. It is shared by AuthService and the auth middleware.
+type TokenVerifier struct {
+	Keys     *keys.Manager
+	Issuer   string
+	Denylist repository.TokenDenylist
+}
+
+func NewTokenVerifier(keyManager *keys.Manager, issuer string, denylist repository.TokenDenylist) *TokenVerifier {
+	return &TokenVerifier{
+		Keys:     keyManager,
+		Issuer:   issuer,
+		Denylist: denylist,
+	}
+}
+
+// NewClaims returns claims for a token of the given type with the standard
+// registered claims (issuer, audience, subject, lifetime and a unique ID) set
+func (v *TokenVerifier) NewClaims(tokenType, subject string, ttl time.Duration) *TokenClaims {
+	now := time.Now()
+	return &TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    v.Issuer,
+			Audience:  jwt.ClaimStrings{tokenAudiences[tokenType]},
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        uuid.NewString(),
+		},
+		Type: tokenType,
+	}
+}
+
+// Verify parses a token and checks its signature, lifetime, issuer, audience and type
+func (v *TokenVerifier) Verify(tokenString, tokenType string) (*TokenClaims, error) {
+	audience, ok := tokenAudiences[tokenType]
+	if !ok {
+		return nil, fmt.Errorf("unknown token type: %s", tokenType)
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, v.Keys.Keyfunc,
+		jwt.WithValidMethods(v.Keys.ValidMethods()),
+		jwt.WithIssuer(v.Issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*TokenClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Type != tokenType {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// VerifyAccessToken verifies an access token and rejects revoked ones
+func (v *TokenVerifier) VerifyAccessToken(tokenString string) (*TokenClaims, error) {
+	return v.VerifyActive(tokenString, TokenTypeAccess)
+}
+
+// VerifyActive verifies a token like Verify and additionally rejects revoked tokens
+func (v *TokenVerifier) VerifyActive(tokenString, tokenType string) (*TokenClaims, error) {
+	claims, err := v.Verify(tokenString, tokenType)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := v.Denylist.Contains(claims.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDenylistUnavailable, err)
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// Revoke denylists a token until it expires
+func (v *TokenVerifier) Revoke(claims *TokenClaims) error {
+	return v.Denylist.Add(claims.ID, claims.ExpiresAt.Time)
+}
@@ -1,16 +1,27 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
 	"fiber-gorm/internal/models"
 	"fiber-gorm/internal/repository"
 )
 
+// Error types for user management
+var (
+	ErrRoleNotFound = errors.New("Role not found")
+)
+
 type UserService struct {
-	Repo *repository.UserRepository
+	Repo     *repository.UserRepository
+	RoleRepo *repository.RoleRepository
 }
 
-func NewUserService(repo *repository.UserRepository) *UserService {
-	return &UserService{Repo: repo}
+func NewUserService(repo *repository.UserRepository, roleRepo *repository.RoleRepository) *UserService {
+	return &UserService{Repo: repo, RoleRepo: roleRepo}
 }
 
 func (s *UserService) CreateUser(user *models.User) error {
@@ -33,3 +44,27 @@ func (s *UserService) UpdateUser(user *models.User) error {
 func (s *UserService) DeleteUser(user *models.User) error {
 	return s.Repo.DeleteUser(user)
 }
+
+// AssignRole replaces the role of a user. The change is reflected in tokens issued afterwards.
+func (s *UserService) AssignRole(userID string, roleName string) (*models.User, error) {
+	user, err := s.Repo.FindUserById(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	role, err := s.RoleRepo.FindRoleByName(roleName)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, fmt.Errorf("failed to load role: %w", err)
+	}
+
+	user.RoleID = &role.ID
+	user.Role = role
+	if err := s.Repo.UpdateUser(user); err != nil {
+		return nil, fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"fiber-gorm/internal/middleware"
+	"fiber-gorm/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type createAPIKeyResponse struct {
+	Key    string        `json:"key"`
+	APIKey models.APIKey `json:"api_key"`
+}
+
+func TestAPIKeys(t *testing.T) {
+	app := SetupTestApp(t)
+	authResp := app.RegisterTestUser(t)
+	token := authResp.Token.AccessToken
+
+	var created createAPIKeyResponse
+
+	t.Run("Create", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/me/tokens", models.CreateAPIKeyPayload{
+			Name:   "ci",
+			Scopes: []string{models.PermissionTasksRead},
+		}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		ParseResponse(t, resp, &created)
+		assert.True(t, strings.HasPrefix(created.Key, "fgk_"))
+		assert.True(t, strings.HasPrefix(created.Key, created.APIKey.Prefix+"_"))
+		assert.Equal(t, []string{models.PermissionTasksRead}, created.APIKey.Scopes)
+	})
+
+	t.Run("Scopes Must Be Held By The User", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/me/tokens", models.CreateAPIKeyPayload{
+			Name:   "too much",
+			Scopes: []string{models.PermissionUsersWrite},
+		}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Authenticate With Bearer", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/me", nil, created.Key)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Authenticate With Header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+		req.Header.Set(middleware.APIKeyHeader, created.Key)
+		resp, err := app.App.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		keys, err := app.APIKeySvc.ListAPIKeys(authResp.User.ID.String())
+		assert.NoError(t, err)
+		if assert.Len(t, keys, 1) {
+			assert.NotNil(t, keys[0].LastUsedAt)
+			assert.NotEmpty(t, keys[0].LastUsedIP)
+		}
+	})
+
+	t.Run("Permissions Are Limited To Scopes", func(t *testing.T) {
+		claims, err := app.APIKeySvc.Authenticate(created.Key, "127.0.0.1")
+		assert.NoError(t, err)
+		assert.Equal(t, authResp.User.ID.String(), claims.Subject)
+		assert.True(t, claims.HasPermission(models.PermissionTasksRead))
+		assert.False(t, claims.HasPermission(models.PermissionTasksWrite))
+	})
+
+	t.Run("Keys Cannot Manage The Account", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/me/tokens", nil, created.Key)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodPost, "/api/auth/logout", nil, created.Key)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Tampered Key Is Rejected", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/me", nil, created.Key+"x")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("List And Revoke", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/me/tokens", nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var keys []models.APIKey
+		ParseResponse(t, resp, &keys)
+		assert.Len(t, keys, 1)
+
+		resp, err = app.MakeRequest(http.MethodDelete, "/api/me/tokens/"+created.APIKey.ID.String(), nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodGet, "/api/me", nil, created.Key)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodDelete, "/api/me/tokens/"+created.APIKey.ID.String(), nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Expired Key Is Rejected", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour)
+		raw, key, err := app.APIKeySvc.CreateAPIKey(authResp.User.ID.String(), &models.CreateAPIKeyPayload{
+			Name:      "short lived",
+			Scopes:    []string{models.PermissionTasksRead},
+			ExpiresAt: &expiresAt,
+		})
+		assert.NoError(t, err)
+
+		app.DB.Model(key).Update("expires_at", time.Now().Add(-time.Minute))
+
+		resp, err := app.MakeRequest(http.MethodGet, "/api/me", nil, raw)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Other Users Cannot Revoke", func(t *testing.T) {
+		raw, key, err := app.APIKeySvc.CreateAPIKey(authResp.User.ID.String(), &models.CreateAPIKeyPayload{
+			Name:   "mine",
+			Scopes: []string{models.PermissionTasksRead},
+		})
+		assert.NoError(t, err)
+
+		other := app.RegisterTestUser(t)
+		resp, err := app.MakeRequest(http.MethodDelete, "/api/me/tokens/"+key.ID.String(), nil, other.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodGet, "/api/me", nil, raw)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
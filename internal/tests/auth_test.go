@@ -3,25 +3,12 @@ package tests
 import (
 	"fiber-gorm/internal/handlers"
 	"fiber-gorm/internal/models"
-	"fmt"
-	"math/rand"
 	"net/http"
 	"testing"
-	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func init() {
-	// Seed the random number generator for test emails
-	rand.Seed(time.Now().UnixNano())
-}
-
-// Generate a unique email for tests
-func randomEmail() string {
-	return fmt.Sprintf("test-%d@example.com", rand.Int())
-}
-
 func TestAuthFlow(t *testing.T) {
 	// Setup test application
 	app := SetupTestApp(t)
@@ -45,8 +32,9 @@ func TestAuthFlow(t *testing.T) {
 		assert.Equal(t, http.StatusCreated, resp.StatusCode)
 
 		// Parse response
-		var tokenResp handlers.TokenResponse
-		ParseResponse(t, resp, &tokenResp)
+		var authResp AuthResponse
+		ParseResponse(t, resp, &authResp)
+		tokenResp := authResp.Token
 
 		// Verify token response
 		assert.NotEmpty(t, tokenResp.AccessToken)
@@ -71,8 +59,9 @@ func TestAuthFlow(t *testing.T) {
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 
 		// Parse response
-		var tokenResp handlers.TokenResponse
-		ParseResponse(t, resp, &tokenResp)
+		var authResp AuthResponse
+		ParseResponse(t, resp, &authResp)
+		tokenResp := authResp.Token
 
 		// Verify token response
 		assert.NotEmpty(t, tokenResp.AccessToken)
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fiber-gorm/internal/middleware"
+	"fiber-gorm/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// browser keeps the cookies a browser would store between requests
+type browser struct {
+	app     *TestApp
+	cookies map[string]*http.Cookie
+}
+
+func newBrowser(app *TestApp) *browser {
+	return &browser{app: app, cookies: map[string]*http.Cookie{}}
+}
+
+// do sends a request with the stored cookies, adding the CSRF header when
+// withCSRF is set, and stores the cookies of the response
+func (b *browser) do(t *testing.T, method, url string, body interface{}, withCSRF bool) *http.Response {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		assert.NoError(t, err)
+	}
+
+	req := httptest.NewRequest(method, url, bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	for _, cookie := range b.cookies {
+		req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	if withCSRF {
+		if csrf, ok := b.cookies[middleware.CSRFCookie]; ok {
+			req.Header.Set(middleware.CSRFHeader, csrf.Value)
+		}
+	}
+
+	resp, err := b.app.App.Test(req)
+	assert.NoError(t, err)
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Value == "" {
+			delete(b.cookies, cookie.Name)
+		} else {
+			b.cookies[cookie.Name] = cookie
+		}
+	}
+	return resp
+}
+
+func TestCookieAuthMode(t *testing.T) {
+	app := SetupTestApp(t)
+	userResp := app.RegisterTestUser(t)
+	email := userResp.User.Email
+
+	// Enable the cookie mode after registering, so the bearer token above stays usable
+	app.AuthSvc.Cfg.AuthCookieMode = true
+	app.AuthSvc.Cfg.AuthCookieSecure = true
+	app.AuthSvc.Cfg.AuthCookieSameSite = "Strict"
+	b := newBrowser(app)
+
+	t.Run("Login Sets Cookies Instead Of Returning Tokens", func(t *testing.T) {
+		resp := b.do(t, http.MethodPost, "/api/auth/login", models.LoginUserPayload{
+			Email:    email,
+			Password: "Password123!",
+		}, false)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var authResp AuthResponse
+		ParseResponse(t, resp, &authResp)
+		assert.Empty(t, authResp.Token.AccessToken)
+		assert.Empty(t, authResp.Token.RefreshToken)
+		assert.Equal(t, "cookie", authResp.Token.TokenType)
+
+		access := b.cookies[middleware.AccessTokenCookie]
+		if assert.NotNil(t, access) {
+			assert.True(t, access.HttpOnly)
+			assert.True(t, access.Secure)
+			assert.Equal(t, http.SameSiteStrictMode, access.SameSite)
+			assert.Equal(t, "/", access.Path)
+		}
+		refresh := b.cookies[middleware.RefreshTokenCookie]
+		if assert.NotNil(t, refresh) {
+			assert.True(t, refresh.HttpOnly)
+			assert.Equal(t, "/api/auth", refresh.Path)
+		}
+		csrf := b.cookies[middleware.CSRFCookie]
+		if assert.NotNil(t, csrf) {
+			assert.False(t, csrf.HttpOnly)
+		}
+	})
+
+	t.Run("Access Cookie Authenticates Reads", func(t *testing.T) {
+		resp := b.do(t, http.MethodGet, "/api/me", nil, false)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("State Changes Require The CSRF Token", func(t *testing.T) {
+		payload := models.CreateAPIKeyPayload{Name: "ci", Scopes: []string{models.PermissionTasksRead}}
+
+		resp := b.do(t, http.MethodPost, "/api/me/tokens", payload, false)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		// A forged header does not match the cookie
+		req := httptest.NewRequest(http.MethodPost, "/api/me/tokens", bytes.NewBufferString(`{"name":"ci","scopes":["tasks:read"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.CSRFHeader, "forged")
+		for _, cookie := range b.cookies {
+			req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		}
+		forged, err := app.App.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, forged.StatusCode)
+
+		resp = b.do(t, http.MethodPost, "/api/me/tokens", payload, true)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	})
+
+	t.Run("Bearer Tokens Need No CSRF Token", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/me/tokens", models.CreateAPIKeyPayload{Name: "cli", Scopes: []string{models.PermissionTasksRead}}, userResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	})
+
+	t.Run("Refresh Uses The Refresh Cookie", func(t *testing.T) {
+		previous := b.cookies[middleware.AccessTokenCookie].Value
+
+		resp := b.do(t, http.MethodPost, "/api/auth/refresh", nil, false)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp = b.do(t, http.MethodPost, "/api/auth/refresh", nil, true)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body map[string]interface{}
+		ParseResponse(t, resp, &body)
+		assert.NotContains(t, body, "access_token")
+		assert.NotEqual(t, previous, b.cookies[middleware.AccessTokenCookie].Value)
+
+		resp = b.do(t, http.MethodGet, "/api/me", nil, false)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Logout Clears The Cookies", func(t *testing.T) {
+		resp := b.do(t, http.MethodPost, "/api/auth/logout", nil, true)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, b.cookies)
+
+		resp = b.do(t, http.MethodGet, "/api/me", nil, false)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Register Sets Cookies", func(t *testing.T) {
+		registering := newBrowser(app)
+		resp := registering.do(t, http.MethodPost, "/api/auth/register", models.CreateUserPayload{
+			Name:     "Cookie User",
+			Email:    randomEmail(),
+			Password: "Password123!",
+		}, false)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Contains(t, registering.cookies, middleware.AccessTokenCookie)
+		assert.Contains(t, registering.cookies, middleware.RefreshTokenCookie)
+		assert.Contains(t, registering.cookies, middleware.CSRFCookie)
+	})
+}
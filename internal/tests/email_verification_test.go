@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/services"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var tokenLinkPattern = regexp.MustCompile(`token=(\S+)`)
+
+// LastMailedToken extracts the token from the link in the last email sent to the address
+func (ta *TestApp) LastMailedToken(t *testing.T, email string) string {
+	msg, ok := ta.Mailer.Last(email)
+	if !assert.True(t, ok, "no email sent to %s", email) {
+		return ""
+	}
+
+	match := tokenLinkPattern.FindStringSubmatch(msg.Body)
+	if !assert.Len(t, match, 2, "no token link in email") {
+		return ""
+	}
+
+	token, err := url.QueryUnescape(match[1])
+	assert.NoError(t, err)
+	return token
+}
+
+func TestEmailVerification(t *testing.T) {
+	app := SetupTestApp(t)
+	app.AuthSvc.Cfg.EmailVerificationPolicy = services.VerificationPolicyBlock
+
+	email := randomEmail()
+	password := "Password123!"
+
+	resp, err := app.MakeRequest(http.MethodPost, "/api/auth/register", models.CreateUserPayload{
+		Name:     "Test User",
+		Email:    email,
+		Password: password,
+	}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var authResp AuthResponse
+	ParseResponse(t, resp, &authResp)
+	assert.Empty(t, authResp.Token.AccessToken)
+	assert.Nil(t, authResp.User.EmailVerifiedAt)
+
+	t.Run("Login Is Blocked Until Verified", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/login", models.LoginUserPayload{
+			Email:    email,
+			Password: password,
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Resend Invalidates The Previous Link", func(t *testing.T) {
+		first := app.LastMailedToken(t, email)
+
+		// Bypass the resend cooldown for the test
+		app.DB.Exec("UPDATE one_time_tokens SET created_at = datetime('now', '-1 hour')")
+
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/resend-verification", models.ResendVerificationPayload{Email: email}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		second := app.LastMailedToken(t, email)
+		assert.NotEqual(t, first, second)
+
+		resp, err = app.MakeRequest(http.MethodPost, "/api/auth/verify-email", models.VerifyEmailPayload{Token: first}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Verify Email", func(t *testing.T) {
+		token := app.LastMailedToken(t, email)
+
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/verify-email", models.VerifyEmailPayload{Token: token}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// Tokens are single use
+		resp, err = app.MakeRequest(http.MethodPost, "/api/auth/verify-email", models.VerifyEmailPayload{Token: token}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		loginResp := app.LoginTestUser(t, email, password)
+		assert.NotNil(t, loginResp.User.EmailVerifiedAt)
+	})
+
+	t.Run("Unknown Email Gets The Same Response", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/resend-verification", models.ResendVerificationPayload{Email: randomEmail()}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	})
+}
+
+func TestRestrictedUnverifiedAccount(t *testing.T) {
+	app := SetupTestApp(t)
+	app.AuthSvc.Cfg.EmailVerificationPolicy = services.VerificationPolicyRestrict
+
+	authResp := app.RegisterTestUser(t)
+
+	claims, err := app.AuthSvc.ValidateAccessToken(authResp.Token.AccessToken)
+	assert.NoError(t, err)
+	assert.False(t, claims.EmailVerified)
+	assert.Empty(t, claims.Permissions)
+}
@@ -6,29 +6,58 @@ import (
 	"fiber-gorm/internal/config"
 	"fiber-gorm/internal/database"
 	"fiber-gorm/internal/handlers"
+	"fiber-gorm/internal/keys"
+	"fiber-gorm/internal/mailer"
 	"fiber-gorm/internal/middleware"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/notifier"
+	"fiber-gorm/internal/oidc"
+	"fiber-gorm/internal/passhash"
 	"fiber-gorm/internal/repository"
 	"fiber-gorm/internal/services"
+	"fiber-gorm/internal/validators"
+	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 // TestApp contains all dependencies for testing the API
 type TestApp struct {
-	App         *fiber.App
-	Config      config.Config
-	AuthSvc     *services.AuthService
-	UserSvc     *services.UserService
-	UserRepo    *repository.UserRepository
-	AuthHandler *handlers.AuthHandler
-	UserHandler *handlers.UserHandler
-	TestData    map[string]interface{} // Store test data between test cases
+	App          *fiber.App
+	Config       config.Config
+	AuthSvc      *services.AuthService
+	AuditSvc     *services.AuditService
+	UserSvc      *services.UserService
+	UserRepo     *repository.UserRepository
+	Mailer       *mailer.LogMailer
+	MFASvc       *services.MFAService
+	APIKeySvc    *services.APIKeyService
+	OIDCSvc      *services.OIDCService
+	OAuthSvc     *services.OAuthService
+	MagicLinkSvc *services.MagicLinkService
+	ServiceAuth  *services.ServiceAuthenticator
+	TaskSvc      *services.TaskService
+	Reminders    *services.TaskReminderScheduler
+	Notifier     *notifier.LogNotifier
+	DB           *gorm.DB
+	AuthHandler  *handlers.AuthHandler
+	UserHandler  *handlers.UserHandler
+	TestData     map[string]interface{} // Store test data between test cases
+}
+
+// AuthResponse mirrors the body returned by the register and login endpoints
+type AuthResponse struct {
+	Token handlers.TokenResponse `json:"token"`
+	User  models.User            `json:"user"`
 }
 
 // SetupTestApp creates a test instance of the application with a test database
@@ -41,27 +70,175 @@ func SetupTestApp(t *testing.T) *TestApp {
 		ServerPort:  "3000",
 		LogLevel:    "error",
 		JWTSecret:   "test-jwt-secret",
+		JWTIssuer:   "fiber-gorm-test",
+		AppBaseURL:  "http://localhost:3000",
+
+		EmailVerificationPolicy: "none",
+		MagicLinkEnabled:        true,
+
+		LoginMaxFailures:     5,
+		LoginIPMaxFailures:   50,
+		LoginFailureWindow:   15 * time.Minute,
+		LoginLockoutDuration: 15 * time.Minute,
+
+		// Cheap hashing parameters keep the tests fast
+		PasswordHashAlgorithm: "argon2id",
+		Argon2Memory:          1024,
+		Argon2Iterations:      1,
+		Argon2Parallelism:     1,
+		BcryptCost:            4,
+
+		PasswordMinLength:            8,
+		PasswordMaxLength:            128,
+		PasswordRequireUpper:         true,
+		PasswordRequireDigit:         true,
+		PasswordRequireSymbol:        true,
+		PasswordDisallowPersonalInfo: true,
+		PasswordHistorySize:          3,
+
+		ServiceCredentials: []config.ServiceCredentialConfig{
+			{KeyID: "billing", Secret: "billing-secret", Permissions: []string{models.PermissionTasksRead}},
+			{KeyID: "support", Secret: "support-secret", Permissions: []string{models.PermissionUsersRead, models.PermissionUsersWrite}},
+		},
+		ServiceAuthMaxSkew: 5 * time.Minute,
+
+		TaskReminderBefore: time.Hour,
 	}
 
 	// Connect to test database
-	db, err := database.Connect()
+	db, err := database.Connect(cfg)
 	if err != nil {
 		t.Fatalf("Failed to connect to test database: %v", err)
 	}
 
 	// Setup test repositories
 	userRepo := &repository.UserRepository{DB: db}
+	refreshTokenRepo := &repository.RefreshTokenRepository{DB: db}
+	sessionRepo := &repository.SessionRepository{DB: db}
+	identityRepo := &repository.IdentityRepository{DB: db}
+	roleRepo := &repository.RoleRepository{DB: db}
+	oneTimeTokenRepo := &repository.OneTimeTokenRepository{DB: db}
+	recoveryCodeRepo := &repository.RecoveryCodeRepository{DB: db}
+	denylist := repository.NewMemoryTokenDenylist()
+	auditLogRepo := &repository.AuditLogRepository{DB: db}
+	apiKeyRepo := &repository.APIKeyRepository{DB: db}
+	oauthRepo := &repository.OAuthRepository{DB: db}
+	passwordHistoryRepo := &repository.PasswordHistoryRepository{DB: db}
+	taskRepo := &repository.TaskRepository{DB: db}
 
 	// Setup test services
-	userSvc := &services.UserService{Repo: userRepo}
+	userSvc := &services.UserService{Repo: userRepo, RoleRepo: roleRepo}
+	auditSvc := &services.AuditService{Repo: auditLogRepo}
+	loginThrottle := &services.LoginThrottle{
+		Store:         repository.NewGormLoginAttemptStore(db),
+		Audit:         auditSvc,
+		MaxFailures:   cfg.LoginMaxFailures,
+		MaxIPFailures: cfg.LoginIPMaxFailures,
+		Window:        cfg.LoginFailureWindow,
+		Lockout:       cfg.LoginLockoutDuration,
+		DelayBase:     cfg.LoginDelayBase,
+	}
+	tokenVerifier := &services.TokenVerifier{
+		Keys:     keys.NewHMACManager(cfg.JWTSecret),
+		Issuer:   cfg.JWTIssuer,
+		Denylist: denylist,
+	}
+	hasher, err := passhash.New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to setup password hashing: %v", err)
+	}
+	passwordPolicy := &services.PasswordPolicy{
+		Rules:       validators.NewPasswordPolicy(cfg),
+		HistorySize: cfg.PasswordHistorySize,
+		History:     passwordHistoryRepo,
+		Hasher:      hasher,
+	}
+	mail := mailer.NewLogMailer()
+	verificationSvc := &services.EmailVerificationService{
+		Cfg:       cfg,
+		Verifier:  tokenVerifier,
+		UserRepo:  userRepo,
+		TokenRepo: oneTimeTokenRepo,
+		Mailer:    mail,
+	}
 	authSvc := &services.AuthService{
-		Cfg:      cfg, // Pass the config directly (not a pointer)
+		Cfg:              cfg, // Pass the config directly (not a pointer)
+		Verifier:         tokenVerifier,
+		UserRepo:         userRepo,
+		RefreshTokenRepo: refreshTokenRepo,
+		SessionRepo:      sessionRepo,
+		RoleRepo:         roleRepo,
+		Hasher:           hasher,
+		Passwords:        passwordPolicy,
+
+		EmailVerification: verificationSvc,
+		Throttle:          loginThrottle,
+	}
+	passwordSvc := &services.PasswordService{
+		Cfg:              cfg,
+		Auth:             authSvc,
+		UserRepo:         userRepo,
+		RefreshTokenRepo: refreshTokenRepo,
+		TokenRepo:        oneTimeTokenRepo,
+		Mailer:           mail,
+	}
+	magicLinkSvc := &services.MagicLinkService{
+		Cfg:       cfg,
+		Auth:      authSvc,
+		UserRepo:  userRepo,
+		TokenRepo: oneTimeTokenRepo,
+		Mailer:    mail,
+	}
+	mfaSvc := &services.MFAService{
+		Cfg:              cfg,
+		Auth:             authSvc,
+		UserRepo:         userRepo,
+		RecoveryCodeRepo: recoveryCodeRepo,
+	}
+	sessionSvc := &services.SessionService{
+		Verifier:         tokenVerifier,
+		SessionRepo:      sessionRepo,
+		RefreshTokenRepo: refreshTokenRepo,
+	}
+	oidcSvc := &services.OIDCService{
+		Cfg:          cfg,
+		Auth:         authSvc,
+		IdentityRepo: identityRepo,
+		Providers:    map[string]*oidc.Provider{},
+	}
+	apiKeySvc := &services.APIKeyService{
+		Cfg:      cfg,
+		Repo:     apiKeyRepo,
 		UserRepo: userRepo,
 	}
+	oauthSvc := &services.OAuthService{Auth: authSvc, Repo: oauthRepo}
+	serviceAuth := services.NewServiceAuthenticator(cfg, repository.NewGormNonceStore(db))
+	taskWorkflow, err := services.NewTaskWorkflow(cfg)
+	if err != nil {
+		t.Fatalf("Failed to setup task workflow: %v", err)
+	}
+	taskSvc := &services.TaskService{Repo: taskRepo, Workflow: taskWorkflow}
+	reminderNotifier := notifier.NewLogNotifier()
+	reminders := &services.TaskReminderScheduler{
+		Repo:     taskRepo,
+		UserRepo: userRepo,
+		Notifier: reminderNotifier,
+		Before:   cfg.TaskReminderBefore,
+	}
 
 	// Setup test handlers
-	userHandler := &handlers.UserHandler{Svc: userSvc}
+	userHandler := &handlers.UserHandler{Svc: userSvc, PasswordPolicy: passwordPolicy.Rules}
 	authHandler := &handlers.AuthHandler{AuthSvc: authSvc}
+	adminHandler := &handlers.AdminHandler{UserSvc: userSvc, AuthSvc: authSvc, AuditSvc: auditSvc}
+	verificationHandler := &handlers.VerificationHandler{VerificationSvc: verificationSvc}
+	passwordHandler := &handlers.PasswordHandler{PasswordSvc: passwordSvc}
+	magicLinkHandler := &handlers.MagicLinkHandler{MagicLinkSvc: magicLinkSvc}
+	mfaHandler := &handlers.MFAHandler{MFASvc: mfaSvc}
+	apiKeyHandler := &handlers.APIKeyHandler{APIKeySvc: apiKeySvc}
+	sessionHandler := &handlers.SessionHandler{SessionSvc: sessionSvc}
+	oidcHandler := &handlers.OIDCHandler{OIDCSvc: oidcSvc}
+	oauthHandler := &handlers.OAuthHandler{OAuthSvc: oauthSvc}
+	taskHandler := &handlers.TaskHandler{TaskSvc: taskSvc}
 
 	// Create test Fiber app with required settings for testing
 	app := fiber.New(fiber.Config{
@@ -83,23 +260,102 @@ func SetupTestApp(t *testing.T) *TestApp {
 	auth := api.Group("/auth")
 	auth.Post("/register", authHandler.Register)
 	auth.Post("/login", authHandler.Login)
+	auth.Post("/login/mfa", mfaHandler.VerifyLogin)
 	auth.Post("/refresh", authHandler.RefreshToken)
-	auth.Post("/logout", authHandler.Logout)
+	auth.Post("/verify-email", verificationHandler.VerifyEmail)
+	auth.Post("/resend-verification", verificationHandler.ResendVerification)
+	auth.Post("/forgot-password", passwordHandler.ForgotPassword)
+	auth.Post("/reset-password", passwordHandler.ResetPassword)
+	auth.Post("/magic-link", magicLinkHandler.RequestLink)
+	auth.Post("/magic-link/consume", magicLinkHandler.ConsumeLink)
+	auth.Post("/logout", middleware.JWTAuthMiddleware(tokenVerifier, nil), authHandler.Logout)
+	auth.Get("/oidc", oidcHandler.Providers)
+	auth.Get("/oidc/:provider/login", oidcHandler.Login)
+	auth.Get("/oidc/:provider/callback", oidcHandler.Callback)
+	auth.Get("/service", middleware.ServiceAuthMiddleware(serviceAuth, nil), authHandler.ServiceIdentity)
+
+	// OAuth authorization server routes
+	oauth := api.Group("/oauth")
+	oauth.Get("/authorize", middleware.JWTAuthMiddleware(tokenVerifier, nil), middleware.DenyAPIKeys(), oauthHandler.Authorize)
+	oauth.Post("/authorize", middleware.JWTAuthMiddleware(tokenVerifier, nil), middleware.DenyAPIKeys(), oauthHandler.Consent)
+	oauth.Post("/token", oauthHandler.Token)
+	oauth.Post("/introspect", oauthHandler.Introspect)
+	oauth.Post("/revoke", oauthHandler.Revoke)
 
 	// Protected routes - match the structure in main.go
 	protected := api.Group("/") 
-	protected.Use(middleware.JWTAuthMiddleware(&cfg))
+	protected.Use("/me", middleware.JWTAuthMiddleware(tokenVerifier, apiKeySvc))
+	interactive := middleware.DenyAPIKeys()
 	protected.Get("me", authHandler.Me) // Path is /api/me
+	protected.Delete("me/impersonation", authHandler.StopImpersonation)
+	protected.Put("me/password", interactive, passwordHandler.ChangePassword)
+	protected.Post("me/mfa/totp", interactive, mfaHandler.EnrollTOTP)
+	protected.Post("me/mfa/totp/confirm", interactive, mfaHandler.ConfirmTOTP)
+	protected.Delete("me/mfa/totp", interactive, mfaHandler.DisableTOTP)
+	protected.Post("me/mfa/recovery-codes", interactive, mfaHandler.RegenerateRecoveryCodes)
+	protected.Get("me/tokens", interactive, apiKeyHandler.ListAPIKeys)
+	protected.Post("me/tokens", interactive, apiKeyHandler.CreateAPIKey)
+	protected.Delete("me/tokens/:id", interactive, apiKeyHandler.RevokeAPIKey)
+	protected.Get("me/sessions", interactive, sessionHandler.ListSessions)
+	protected.Delete("me/sessions", interactive, sessionHandler.RevokeOtherSessions)
+	protected.Delete("me/sessions/:sessionId", interactive, sessionHandler.RevokeSession)
+	protected.Get("me/identities", interactive, oidcHandler.ListIdentities)
+	protected.Post("me/identities/:provider", interactive, oidcHandler.LinkIdentity)
+	protected.Delete("me/identities/:id", interactive, oidcHandler.UnlinkIdentity)
+
+	// Task routes
+	tasks := api.Group("/tasks", middleware.JWTAuthMiddleware(tokenVerifier, apiKeySvc), middleware.RequireUser())
+	tasks.Get("/", middleware.RequirePermission(models.PermissionTasksRead), taskHandler.ListTasks)
+	tasks.Post("/", middleware.RequirePermission(models.PermissionTasksWrite), taskHandler.CreateTask)
+	tasks.Get("/plan", middleware.RequirePermission(models.PermissionTasksRead), taskHandler.PlanTasks)
+	tasks.Get("/:id", middleware.RequirePermission(models.PermissionTasksRead), taskHandler.GetTask)
+	tasks.Put("/:id", middleware.RequirePermission(models.PermissionTasksWrite), taskHandler.UpdateTask)
+	tasks.Delete("/:id", middleware.RequirePermission(models.PermissionTasksWrite), taskHandler.DeleteTask)
+	tasks.Get("/:id/transitions", middleware.RequirePermission(models.PermissionTasksRead), taskHandler.ListTransitions)
+	tasks.Post("/:id/transitions", middleware.RequirePermission(models.PermissionTasksWrite), taskHandler.TransitionTask)
+	tasks.Get("/:id/subtasks", middleware.RequirePermission(models.PermissionTasksRead), taskHandler.ListSubtasks)
+	tasks.Get("/:id/dependencies", middleware.RequirePermission(models.PermissionTasksRead), taskHandler.ListDependencies)
+	tasks.Post("/:id/dependencies", middleware.RequirePermission(models.PermissionTasksWrite), taskHandler.AddDependency)
+	tasks.Delete("/:id/dependencies/:blockerId", middleware.RequirePermission(models.PermissionTasksWrite), taskHandler.RemoveDependency)
+
+	// Admin routes
+	admin := api.Group("/admin",
+		middleware.ServiceAuthMiddleware(serviceAuth, middleware.JWTAuthMiddleware(tokenVerifier, nil)),
+		middleware.ForUsers(middleware.RequireRole(models.RoleAdmin)),
+		middleware.ForUsers(middleware.RequireMFA()))
+	adminOnly := middleware.RequireUser()
+	admin.Get("/users", middleware.RequirePermission(models.PermissionUsersRead), adminHandler.ListUsers)
+	admin.Put("/users/:id/role", adminOnly, middleware.RequirePermission(models.PermissionUsersWrite), adminHandler.AssignRole)
+	admin.Post("/users/:id/unlock", middleware.RequirePermission(models.PermissionUsersWrite), adminHandler.UnlockUser)
+	admin.Post("/users/:id/impersonate", adminOnly, middleware.RequirePermission(models.PermissionUsersWrite), adminHandler.Impersonate)
+	admin.Get("/users/:id/sessions", middleware.RequirePermission(models.PermissionUsersRead), sessionHandler.ListUserSessions)
+	admin.Delete("/users/:id/sessions/:sessionId", middleware.RequirePermission(models.PermissionUsersWrite), sessionHandler.RevokeUserSession)
+	admin.Get("/audit-logs", middleware.RequirePermission(models.PermissionUsersRead), adminHandler.ListAuditLogs)
+	admin.Get("/oauth/clients", middleware.RequirePermission(models.PermissionUsersRead), oauthHandler.ListClients)
+	admin.Post("/oauth/clients", adminOnly, middleware.RequirePermission(models.PermissionUsersWrite), oauthHandler.CreateClient)
+	admin.Delete("/oauth/clients/:id", adminOnly, middleware.RequirePermission(models.PermissionUsersWrite), oauthHandler.DeleteClient)
 
 	return &TestApp{
-		App:         app,
-		Config:      cfg,
-		AuthSvc:     authSvc,
-		UserSvc:     userSvc,
-		UserRepo:    userRepo,
-		AuthHandler: authHandler,
-		UserHandler: userHandler,
-		TestData:    make(map[string]interface{}), // Initialize test data storage
+		App:          app,
+		Config:       cfg,
+		AuthSvc:      authSvc,
+		AuditSvc:     auditSvc,
+		UserSvc:      userSvc,
+		UserRepo:     userRepo,
+		Mailer:       mail,
+		MFASvc:       mfaSvc,
+		APIKeySvc:    apiKeySvc,
+		OIDCSvc:      oidcSvc,
+		OAuthSvc:     oauthSvc,
+		MagicLinkSvc: magicLinkSvc,
+		ServiceAuth:  serviceAuth,
+		TaskSvc:      taskSvc,
+		Reminders:    reminders,
+		Notifier:     reminderNotifier,
+		DB:           db,
+		AuthHandler:  authHandler,
+		UserHandler:  userHandler,
+		TestData:     make(map[string]interface{}), // Initialize test data storage
 	}
 }
 
@@ -174,3 +430,46 @@ func ParseResponse(t *testing.T, resp *http.Response, v interface{}) {
 		assert.NoError(t, err, "Failed to parse response JSON")
 	}
 }
+
+func init() {
+	// Seed the random number generator for test emails
+	rand.Seed(time.Now().UnixNano())
+}
+
+// Generate a unique email for tests
+func randomEmail() string {
+	return fmt.Sprintf("test-%d@example.com", rand.Int())
+}
+
+// RegisterTestUser registers a user with a random email and returns the auth response
+func (ta *TestApp) RegisterTestUser(t *testing.T) AuthResponse {
+	payload := models.CreateUserPayload{
+		Name:     "Test User",
+		Email:    randomEmail(),
+		Password: "Password123!",
+	}
+
+	resp, err := ta.MakeRequest(http.MethodPost, "/api/auth/register", payload, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var authResp AuthResponse
+	ParseResponse(t, resp, &authResp)
+	return authResp
+}
+
+// LoginTestUser logs in with the given credentials and returns the auth response
+func (ta *TestApp) LoginTestUser(t *testing.T, email, password string) AuthResponse {
+	payload := models.LoginUserPayload{
+		Email:    email,
+		Password: password,
+	}
+
+	resp, err := ta.MakeRequest(http.MethodPost, "/api/auth/login", payload, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var authResp AuthResponse
+	ParseResponse(t, resp, &authResp)
+	return authResp
+}
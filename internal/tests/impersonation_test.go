@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"fiber-gorm/internal/middleware"
+	"fiber-gorm/internal/models"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// impersonate starts an impersonation of the target user with the admin's token
+func (ta *TestApp) impersonate(t *testing.T, adminToken, targetID string) *http.Response {
+	resp, err := ta.MakeRequest(http.MethodPost, "/api/admin/users/"+targetID+"/impersonate", models.ImpersonatePayload{
+		Reason: "Reproduce support ticket",
+	}, adminToken)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestImpersonation(t *testing.T) {
+	app := SetupTestApp(t)
+	userResp := app.RegisterTestUser(t)
+	targetID := userResp.User.ID.String()
+
+	adminEmail := randomEmail()
+	admin, err := app.AuthSvc.EnsureAdmin("Admin User", adminEmail, "Sup3rSecret!Pass")
+	assert.NoError(t, err)
+	app.EnableTestMFA(t, admin.ID)
+	adminResp := app.LoginTestUserMFA(t, adminEmail, "Sup3rSecret!Pass")
+
+	t.Run("Only Admins Can Impersonate", func(t *testing.T) {
+		resp := app.impersonate(t, userResp.Token.AccessToken, admin.ID.String())
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Reason Is Required", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/admin/users/"+targetID+"/impersonate", models.ImpersonatePayload{}, adminResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Admins Cannot Be Impersonated", func(t *testing.T) {
+		resp := app.impersonate(t, adminResp.Token.AccessToken, admin.ID.String())
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	var impersonation AuthResponse
+	t.Run("Admin Can Impersonate A User", func(t *testing.T) {
+		resp := app.impersonate(t, adminResp.Token.AccessToken, targetID)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		ParseResponse(t, resp, &impersonation)
+		assert.Equal(t, userResp.User.ID, impersonation.User.ID)
+		assert.Empty(t, impersonation.Token.RefreshToken)
+
+		claims, err := app.AuthSvc.ValidateAccessToken(impersonation.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, targetID, claims.Subject)
+		if assert.NotNil(t, claims.Actor) {
+			assert.Equal(t, admin.ID.String(), claims.Actor.Subject)
+			assert.Equal(t, adminEmail, claims.Actor.Email)
+		}
+		assert.Empty(t, claims.SessionID)
+	})
+
+	t.Run("Profile Shows The Impersonator", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/me", nil, impersonation.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, admin.ID.String(), resp.Header.Get(middleware.ImpersonatedByHeader))
+
+		var body map[string]interface{}
+		ParseResponse(t, resp, &body)
+		assert.Equal(t, userResp.User.Email, body["email"])
+		impersonator, _ := body["impersonator"].(map[string]interface{})
+		assert.Equal(t, admin.ID.String(), impersonator["sub"])
+
+		// The user's own token does not
+		resp, err = app.MakeRequest(http.MethodGet, "/api/me", nil, userResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Empty(t, resp.Header.Get(middleware.ImpersonatedByHeader))
+		body = nil
+		ParseResponse(t, resp, &body)
+		assert.NotContains(t, body, "impersonator")
+	})
+
+	t.Run("Sensitive Operations Are Blocked", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPut, "/api/me/password", models.ChangePasswordPayload{
+			CurrentPassword: "Password123!",
+			NewPassword:     "NewPassword123!",
+		}, impersonation.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		for _, path := range []string{"/api/me/mfa/totp", "/api/me/tokens"} {
+			resp, err = app.MakeRequest(http.MethodPost, path, map[string]interface{}{"name": "x"}, impersonation.Token.AccessToken)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode, path)
+		}
+
+		resp, err = app.MakeRequest(http.MethodGet, "/api/me/sessions", nil, impersonation.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Stop Ends The Impersonation", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodDelete, "/api/me/impersonation", nil, userResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodDelete, "/api/me/impersonation", nil, impersonation.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodGet, "/api/me", nil, impersonation.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Logout Also Ends It", func(t *testing.T) {
+		resp := app.impersonate(t, adminResp.Token.AccessToken, targetID)
+		var second AuthResponse
+		ParseResponse(t, resp, &second)
+
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/logout", nil, second.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// The user's own login is not affected
+		resp, err = app.MakeRequest(http.MethodGet, "/api/me", nil, userResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Start And Stop Are Audited", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/admin/audit-logs?action=impersonation.started", nil, adminResp.Token.AccessToken)
+		assert.NoError(t, err)
+		var started []models.AuditLog
+		ParseResponse(t, resp, &started)
+		if assert.Len(t, started, 2) {
+			assert.Equal(t, admin.ID, *started[0].ActorID)
+			assert.Equal(t, userResp.User.ID, *started[0].TargetID)
+			assert.Equal(t, "Reproduce support ticket", started[0].Details)
+		}
+
+		resp, err = app.MakeRequest(http.MethodGet, "/api/admin/audit-logs?action=impersonation.stopped", nil, adminResp.Token.AccessToken)
+		assert.NoError(t, err)
+		var stopped []models.AuditLog
+		ParseResponse(t, resp, &stopped)
+		assert.Len(t, stopped, 2)
+	})
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fiber-gorm/internal/keys"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// writePrivateKey stores a PKCS#8 encoded private key as <kid>.pem in dir
+func writePrivateKey(t *testing.T, dir, kid string, key interface{}) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600))
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	writePrivateKey(t, dir, "2025-rsa", rsaKey)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	writePrivateKey(t, dir, "2026-ed25519", edKey)
+
+	load := func(active string, retired ...string) *keys.Manager {
+		loaded, err := keys.LoadDir(dir)
+		assert.NoError(t, err)
+		manager, err := keys.NewKeyManager(loaded, active, retired)
+		assert.NoError(t, err)
+		return manager
+	}
+
+	verify := func(manager *keys.Manager, tokenString string) error {
+		_, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, manager.Keyfunc, jwt.WithValidMethods(manager.ValidMethods()))
+		return err
+	}
+
+	claims := &jwt.RegisteredClaims{
+		Subject:   "user-1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+
+	// Tokens are signed with the RSA key while it is active
+	oldManager := load("2025-rsa")
+	oldToken, err := oldManager.Sign(claims)
+	assert.NoError(t, err)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(oldToken, &jwt.RegisteredClaims{})
+	assert.NoError(t, err)
+	assert.Equal(t, "RS256", parsed.Method.Alg())
+	assert.Equal(t, "2025-rsa", parsed.Header["kid"])
+
+	t.Run("Old Tokens Verify After Rotation", func(t *testing.T) {
+		rotated := load("2026-ed25519")
+
+		newToken, err := rotated.Sign(claims)
+		assert.NoError(t, err)
+		assert.NoError(t, verify(rotated, newToken))
+		assert.NoError(t, verify(rotated, oldToken))
+	})
+
+	t.Run("Retired Keys Are Rejected", func(t *testing.T) {
+		retired := load("2026-ed25519", "2025-rsa")
+		assert.Error(t, verify(retired, oldToken))
+	})
+
+	t.Run("JWKS Publishes Non-Retired Public Keys", func(t *testing.T) {
+		set := load("2026-ed25519").JWKS()
+		assert.Len(t, set.Keys, 2)
+		assert.Equal(t, "RSA", set.Keys[0].Kty)
+		assert.Equal(t, "OKP", set.Keys[1].Kty)
+
+		set = load("2026-ed25519", "2025-rsa").JWKS()
+		assert.Len(t, set.Keys, 1)
+		assert.Equal(t, "2026-ed25519", set.Keys[0].Kid)
+	})
+
+	t.Run("HMAC Tokens Are Rejected By Asymmetric Keys", func(t *testing.T) {
+		hmacToken, err := keys.NewHMACManager("secret").Sign(claims)
+		assert.NoError(t, err)
+		assert.Error(t, verify(load("2026-ed25519"), hmacToken))
+	})
+}
@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/repository"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (ta *TestApp) attemptLogin(t *testing.T, email, password string) *http.Response {
+	resp, err := ta.MakeRequest(http.MethodPost, "/api/auth/login", models.LoginUserPayload{
+		Email:    email,
+		Password: password,
+	}, "")
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestAccountLockout(t *testing.T) {
+	app := SetupTestApp(t)
+	userResp := app.RegisterTestUser(t)
+	email := userResp.User.Email
+
+	adminEmail := randomEmail()
+	admin, err := app.AuthSvc.EnsureAdmin("Admin User", adminEmail, "Sup3rSecret!Pass")
+	assert.NoError(t, err)
+	app.EnableTestMFA(t, admin.ID)
+	adminResp := app.LoginTestUserMFA(t, adminEmail, "Sup3rSecret!Pass")
+
+	t.Run("Failures Lock The Account", func(t *testing.T) {
+		for i := 0; i < app.Config.LoginMaxFailures; i++ {
+			resp := app.attemptLogin(t, email, "WrongPassword123!")
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		}
+
+		// Even the correct password is refused while locked
+		resp := app.attemptLogin(t, email, "Password123!")
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+		entries, err := app.AuditSvc.List(models.AuditActionAccountLocked, 10)
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, userResp.User.ID, *entries[0].TargetID)
+		}
+	})
+
+	t.Run("Other Accounts Are Unaffected", func(t *testing.T) {
+		other := app.RegisterTestUser(t)
+		app.LoginTestUser(t, other.User.Email, "Password123!")
+	})
+
+	t.Run("Admin Unlocks The Account", func(t *testing.T) {
+		url := "/api/admin/users/" + userResp.User.ID.String() + "/unlock"
+		resp, err := app.MakeRequest(http.MethodPost, url, nil, userResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodPost, url, nil, adminResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		app.LoginTestUser(t, email, "Password123!")
+
+		resp, err = app.MakeRequest(http.MethodGet, "/api/admin/audit-logs?action="+models.AuditActionAccountUnlocked, nil, adminResp.Token.AccessToken)
+		assert.NoError(t, err)
+		var entries []models.AuditLog
+		ParseResponse(t, resp, &entries)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, admin.ID, *entries[0].ActorID)
+		}
+	})
+
+	t.Run("Unknown Emails Are Throttled Too", func(t *testing.T) {
+		unknown := randomEmail()
+		for i := 0; i < app.Config.LoginMaxFailures; i++ {
+			app.attemptLogin(t, unknown, "WrongPassword123!")
+		}
+
+		resp := app.attemptLogin(t, unknown, "WrongPassword123!")
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	})
+}
+
+func TestProgressiveLoginDelay(t *testing.T) {
+	app := SetupTestApp(t)
+	app.AuthSvc.Throttle.DelayBase = 10 * time.Second
+	userResp := app.RegisterTestUser(t)
+
+	for i := 0; i < 3; i++ {
+		resp := app.attemptLogin(t, userResp.User.Email, "WrongPassword123!")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	resp := app.attemptLogin(t, userResp.User.Email, "Password123!")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "10", resp.Header.Get("Retry-After"))
+}
+
+func TestIPLockout(t *testing.T) {
+	app := SetupTestApp(t)
+	app.AuthSvc.Throttle.MaxIPFailures = 3
+	userResp := app.RegisterTestUser(t)
+
+	// Spraying different accounts from one IP trips the IP limit
+	for i := 0; i < 3; i++ {
+		app.attemptLogin(t, randomEmail(), "WrongPassword123!")
+	}
+
+	resp := app.attemptLogin(t, userResp.User.Email, "Password123!")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
+
+func TestMFAFailuresCountTowardsLockout(t *testing.T) {
+	app := SetupTestApp(t)
+	userResp := app.RegisterTestUser(t)
+	app.EnableTestMFA(t, userResp.User.ID)
+
+	resp := app.attemptLogin(t, userResp.User.Email, "Password123!")
+	var challenge mfaChallengeResponse
+	ParseResponse(t, resp, &challenge)
+
+	for i := 0; i < app.Config.LoginMaxFailures; i++ {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/login/mfa", models.MFALoginPayload{
+			MFAToken: challenge.MFAToken,
+			Code:     "000000",
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	resp, err := app.MakeRequest(http.MethodPost, "/api/auth/login/mfa", models.MFALoginPayload{
+		MFAToken: challenge.MFAToken,
+		Code:     app.NextTOTPCode(t, userResp.User.ID),
+	}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
+
+func TestMemoryLoginAttemptStore(t *testing.T) {
+	store := repository.NewMemoryLoginAttemptStore()
+
+	attempt, err := store.Get("account:a@example.com")
+	assert.NoError(t, err)
+	assert.Nil(t, attempt)
+
+	for i := 1; i <= 3; i++ {
+		attempt, err = store.RecordFailure("account:a@example.com", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, i, attempt.Failures)
+	}
+
+	// An expired window starts counting again
+	attempt, err = store.RecordFailure("account:a@example.com", -time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempt.Failures)
+
+	assert.NoError(t, store.Lock("account:a@example.com", time.Now().Add(time.Minute)))
+	attempt, err = store.Get("account:a@example.com")
+	assert.NoError(t, err)
+	assert.True(t, attempt.Locked(time.Now()))
+
+	assert.NoError(t, store.Reset("account:a@example.com"))
+	attempt, err = store.Get("account:a@example.com")
+	assert.NoError(t, err)
+	assert.Nil(t, attempt)
+}
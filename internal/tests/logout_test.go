@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogout(t *testing.T) {
+	app := SetupTestApp(t)
+	authResp := app.RegisterTestUser(t)
+
+	resp, err := app.MakeRequest(http.MethodPost, "/api/auth/logout", nil, authResp.Token.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	t.Run("Access Token Is Revoked", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/me", nil, authResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Refresh Token Is Revoked", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/refresh", map[string]string{
+			"refresh_token": authResp.Token.RefreshToken,
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Logout Requires Authentication", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/logout", nil, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+// failingDenylist is a denylist whose store is down
+type failingDenylist struct{}
+
+func (failingDenylist) Add(jti string, expiresAt time.Time) error {
+	return errors.New("database is down")
+}
+
+func (failingDenylist) Contains(jti string) (bool, error) {
+	return false, errors.New("database is down")
+}
+
+func TestDenylistOutage(t *testing.T) {
+	app := SetupTestApp(t)
+	authResp := app.RegisterTestUser(t)
+
+	denylist := app.AuthSvc.Verifier.Denylist
+	app.AuthSvc.Verifier.Denylist = failingDenylist{}
+	defer func() { app.AuthSvc.Verifier.Denylist = denylist }()
+
+	// An outage is not reported as a bad token
+	resp, err := app.MakeRequest(http.MethodGet, "/api/me", nil, authResp.Token.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
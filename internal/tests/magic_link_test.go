@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"fiber-gorm/internal/models"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (ta *TestApp) requestMagicLink(t *testing.T, email string) {
+	resp, err := ta.MakeRequest(http.MethodPost, "/api/auth/magic-link", models.MagicLinkPayload{Email: email}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+}
+
+func (ta *TestApp) consumeMagicLink(t *testing.T, token string) *http.Response {
+	resp, err := ta.MakeRequest(http.MethodPost, "/api/auth/magic-link/consume", models.ConsumeMagicLinkPayload{Token: token}, "")
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestMagicLinkLogin(t *testing.T) {
+	app := SetupTestApp(t)
+	user := app.RegisterTestUser(t)
+	email := user.User.Email
+
+	t.Run("Link Logs The User In Once", func(t *testing.T) {
+		app.requestMagicLink(t, email)
+		token := app.LastMailedToken(t, email)
+
+		msg, _ := app.Mailer.Last(email)
+		assert.True(t, strings.Contains(msg.Body, "http://localhost:3000/magic-link?token="))
+
+		resp := app.consumeMagicLink(t, token)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var authResp AuthResponse
+		ParseResponse(t, resp, &authResp)
+		assert.Equal(t, user.User.ID, authResp.User.ID)
+		assert.NotNil(t, authResp.User.EmailVerifiedAt)
+
+		claims, err := app.AuthSvc.ValidateAccessToken(authResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"email"}, claims.AuthMethods)
+
+		resp = app.consumeMagicLink(t, token)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Password Login Still Works", func(t *testing.T) {
+		app.LoginTestUser(t, email, "Password123!")
+	})
+
+	t.Run("Unknown Email Gets The Same Response", func(t *testing.T) {
+		unknown := randomEmail()
+		app.requestMagicLink(t, unknown)
+		_, sent := app.Mailer.Last(unknown)
+		assert.False(t, sent)
+	})
+
+	t.Run("Requests Are Throttled Per Email", func(t *testing.T) {
+		other := app.RegisterTestUser(t)
+		app.requestMagicLink(t, other.User.Email)
+		first := app.LastMailedToken(t, other.User.Email)
+
+		// A second request within the cooldown sends nothing new
+		app.requestMagicLink(t, other.User.Email)
+		assert.Equal(t, first, app.LastMailedToken(t, other.User.Email))
+
+		// After the cooldown a new link replaces the previous one
+		err := app.DB.Model(&models.OneTimeToken{}).
+			Where("user_id = ? AND purpose = ?", other.User.ID, models.TokenPurposeMagicLink).
+			Update("created_at", time.Now().Add(-2*time.Minute)).Error
+		assert.NoError(t, err)
+
+		app.requestMagicLink(t, other.User.Email)
+		second := app.LastMailedToken(t, other.User.Email)
+		assert.NotEqual(t, first, second)
+
+		resp := app.consumeMagicLink(t, first)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		resp = app.consumeMagicLink(t, second)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Expired Link Is Rejected", func(t *testing.T) {
+		other := app.RegisterTestUser(t)
+		app.requestMagicLink(t, other.User.Email)
+		token := app.LastMailedToken(t, other.User.Email)
+
+		err := app.DB.Model(&models.OneTimeToken{}).
+			Where("user_id = ? AND purpose = ?", other.User.ID, models.TokenPurposeMagicLink).
+			Update("expires_at", time.Now().Add(-time.Minute)).Error
+		assert.NoError(t, err)
+
+		resp := app.consumeMagicLink(t, token)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("MFA Still Applies", func(t *testing.T) {
+		other := app.RegisterTestUser(t)
+		app.EnableTestMFA(t, other.User.ID)
+
+		app.requestMagicLink(t, other.User.Email)
+		resp := app.consumeMagicLink(t, app.LastMailedToken(t, other.User.Email))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var challenge mfaChallengeResponse
+		ParseResponse(t, resp, &challenge)
+		assert.True(t, challenge.MFARequired)
+		assert.NotEmpty(t, challenge.MFAToken)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		app.MagicLinkSvc.Cfg.MagicLinkEnabled = false
+		defer func() { app.MagicLinkSvc.Cfg.MagicLinkEnabled = true }()
+
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/magic-link", models.MagicLinkPayload{Email: email}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp = app.consumeMagicLink(t, "anything")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
@@ -0,0 +1,236 @@
+package tests
+
+import (
+	"encoding/base32"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/services"
+	"fiber-gorm/internal/totp"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// mfaChallengeResponse mirrors the login body returned when a second factor is required
+type mfaChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+// NextTOTPCode returns a valid TOTP code for the user that has not been used yet
+func (ta *TestApp) NextTOTPCode(t *testing.T, userID uuid.UUID) string {
+	user, err := ta.UserRepo.FindUserById(userID.String())
+	assert.NoError(t, err)
+
+	step := totp.Step(time.Now())
+	if user.TOTPLastStep >= step {
+		step = user.TOTPLastStep + 1
+	}
+
+	code, err := totp.CodeAt(user.TOTPSecret, step)
+	assert.NoError(t, err)
+	return code
+}
+
+// EnableTestMFA enrolls the user in TOTP and returns the recovery codes
+func (ta *TestApp) EnableTestMFA(t *testing.T, userID uuid.UUID) []string {
+	_, err := ta.MFASvc.BeginTOTPEnrollment(userID.String())
+	assert.NoError(t, err)
+
+	codes, err := ta.MFASvc.ConfirmTOTPEnrollment(userID.String(), ta.NextTOTPCode(t, userID))
+	assert.NoError(t, err)
+	return codes
+}
+
+// LoginTestUserMFA performs both login steps for a user with TOTP enabled
+func (ta *TestApp) LoginTestUserMFA(t *testing.T, email, password string) AuthResponse {
+	resp, err := ta.MakeRequest(http.MethodPost, "/api/auth/login", models.LoginUserPayload{
+		Email:    email,
+		Password: password,
+	}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var challenge mfaChallengeResponse
+	ParseResponse(t, resp, &challenge)
+	assert.True(t, challenge.MFARequired)
+
+	user, err := ta.UserRepo.FindUserByEmail(email)
+	assert.NoError(t, err)
+
+	resp, err = ta.MakeRequest(http.MethodPost, "/api/auth/login/mfa", models.MFALoginPayload{
+		MFAToken: challenge.MFAToken,
+		Code:     ta.NextTOTPCode(t, user.ID),
+	}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var authResp AuthResponse
+	ParseResponse(t, resp, &authResp)
+	return authResp
+}
+
+func TestTOTPReferenceVector(t *testing.T) {
+	// RFC 6238 appendix B, SHA1, truncated to 6 digits
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	code, err := totp.CodeAt(secret, totp.Step(time.Unix(59, 0)))
+	assert.NoError(t, err)
+	assert.Equal(t, "287082", code)
+
+	code, err = totp.CodeAt(secret, totp.Step(time.Unix(1111111109, 0)))
+	assert.NoError(t, err)
+	assert.Equal(t, "081804", code)
+
+	_, ok := totp.Validate(secret, "287082", time.Unix(59+totp.Period, 0), 1)
+	assert.True(t, ok, "previous step is accepted within skew")
+
+	_, ok = totp.Validate(secret, "287082", time.Unix(59+3*totp.Period, 0), 1)
+	assert.False(t, ok, "codes outside the skew window are rejected")
+}
+
+func TestTOTPEnrollmentAndLogin(t *testing.T) {
+	app := SetupTestApp(t)
+	authResp := app.RegisterTestUser(t)
+	email := authResp.User.Email
+	password := "Password123!"
+
+	var recoveryCodes []string
+
+	t.Run("Enroll", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/me/mfa/totp", nil, authResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var enrollment services.TOTPEnrollment
+		ParseResponse(t, resp, &enrollment)
+		assert.NotEmpty(t, enrollment.Secret)
+		assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/"))
+		assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+
+		// Not enabled until confirmed, so login still issues tokens directly
+		app.LoginTestUser(t, email, password)
+	})
+
+	t.Run("Confirm Rejects Wrong Code", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/me/mfa/totp/confirm", models.TOTPCodePayload{
+			Code: "000000",
+		}, authResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Confirm", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/me/mfa/totp/confirm", models.TOTPCodePayload{
+			Code: app.NextTOTPCode(t, authResp.User.ID),
+		}, authResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}
+		ParseResponse(t, resp, &body)
+		assert.Len(t, body.RecoveryCodes, 10)
+		recoveryCodes = body.RecoveryCodes
+
+		user, err := app.UserRepo.FindUserById(authResp.User.ID.String())
+		assert.NoError(t, err)
+		assert.NotNil(t, user.TOTPEnabledAt)
+	})
+
+	t.Run("Login Requires Second Factor", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/login", models.LoginUserPayload{
+			Email:    email,
+			Password: password,
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var challenge mfaChallengeResponse
+		ParseResponse(t, resp, &challenge)
+		assert.True(t, challenge.MFARequired)
+		assert.NotEmpty(t, challenge.MFAToken)
+
+		// The challenge is not an access token
+		resp, err = app.MakeRequest(http.MethodGet, "/api/me", nil, challenge.MFAToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodPost, "/api/auth/login/mfa", models.MFALoginPayload{
+			MFAToken: challenge.MFAToken,
+			Code:     "000000",
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		code := app.NextTOTPCode(t, authResp.User.ID)
+		resp, err = app.MakeRequest(http.MethodPost, "/api/auth/login/mfa", models.MFALoginPayload{
+			MFAToken: challenge.MFAToken,
+			Code:     code,
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var mfaResp AuthResponse
+		ParseResponse(t, resp, &mfaResp)
+		assert.NotEmpty(t, mfaResp.Token.AccessToken)
+
+		claims, err := app.AuthSvc.ValidateAccessToken(mfaResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.True(t, claims.HasAuthMethod(services.AuthMethodOTP))
+
+		// Neither the challenge nor the code can be replayed
+		resp, err = app.MakeRequest(http.MethodPost, "/api/auth/login/mfa", models.MFALoginPayload{
+			MFAToken: challenge.MFAToken,
+			Code:     code,
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Login With Recovery Code", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/login", models.LoginUserPayload{
+			Email:    email,
+			Password: password,
+		}, "")
+		assert.NoError(t, err)
+		var challenge mfaChallengeResponse
+		ParseResponse(t, resp, &challenge)
+
+		resp, err = app.MakeRequest(http.MethodPost, "/api/auth/login/mfa", models.MFALoginPayload{
+			MFAToken:     challenge.MFAToken,
+			RecoveryCode: strings.ToUpper(recoveryCodes[0]),
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// Recovery codes are single use
+		resp, err = app.MakeRequest(http.MethodPost, "/api/auth/login", models.LoginUserPayload{
+			Email:    email,
+			Password: password,
+		}, "")
+		assert.NoError(t, err)
+		ParseResponse(t, resp, &challenge)
+
+		resp, err = app.MakeRequest(http.MethodPost, "/api/auth/login/mfa", models.MFALoginPayload{
+			MFAToken:     challenge.MFAToken,
+			RecoveryCode: recoveryCodes[0],
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodDelete, "/api/me/mfa/totp", models.TOTPCodePayload{
+			Code: recoveryCodes[1],
+		}, authResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		app.LoginTestUser(t, email, password)
+	})
+}
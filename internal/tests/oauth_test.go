@@ -0,0 +1,493 @@
+package tests
+
+import (
+	"encoding/base64"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/oidc"
+	"fiber-gorm/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRedirectURI = "https://partner.example.com/callback"
+
+// oauthTokenError mirrors the error body of the token endpoint
+type oauthTokenError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// postForm sends a form-encoded request, authenticating the client with HTTP Basic when a secret is given
+func (ta *TestApp) postForm(t *testing.T, path string, form url.Values, clientID, secret string) *http.Response {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if secret != "" {
+		credentials := url.QueryEscape(clientID) + ":" + url.QueryEscape(secret)
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	} else if clientID != "" {
+		form.Set("client_id", clientID)
+		req = httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := ta.App.Test(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+// createOAuthClient registers a client directly through the service
+func (ta *TestApp) createOAuthClient(t *testing.T, payload models.CreateOAuthClientPayload) (*models.OAuthClient, string) {
+	client, secret, err := ta.OAuthSvc.CreateClient(&payload)
+	assert.NoError(t, err)
+	return client, secret
+}
+
+// authorizeCode walks the user through the consent step and returns the authorization code
+func (ta *TestApp) authorizeCode(t *testing.T, clientID, userToken, scope, verifier string) string {
+	resp, err := ta.MakeRequest(http.MethodPost, "/api/oauth/authorize", models.ConsentPayload{
+		AuthorizeRequest: models.AuthorizeRequest{
+			ResponseType:        "code",
+			ClientID:            clientID,
+			RedirectURI:         testRedirectURI,
+			Scope:               scope,
+			State:               "xyz",
+			CodeChallenge:       oidc.CodeChallenge(verifier),
+			CodeChallengeMethod: "S256",
+		},
+		Approve: true,
+	}, userToken)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		RedirectURI string `json:"redirect_uri"`
+	}
+	ParseResponse(t, resp, &body)
+
+	redirect, err := url.Parse(body.RedirectURI)
+	assert.NoError(t, err)
+	assert.Equal(t, "xyz", redirect.Query().Get("state"))
+	return redirect.Query().Get("code")
+}
+
+func TestOAuthAuthorizationCodeFlow(t *testing.T) {
+	app := SetupTestApp(t)
+	user := app.RegisterTestUser(t)
+	client, secret := app.createOAuthClient(t, models.CreateOAuthClientPayload{
+		Name:         "Partner App",
+		RedirectURIs: []string{testRedirectURI},
+		GrantTypes:   []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken},
+		Scopes:       []string{models.PermissionTasksRead, models.PermissionTasksWrite},
+	})
+	clientID := client.ID.String()
+	verifier, err := oidc.RandomString()
+	assert.NoError(t, err)
+
+	authorizeQuery := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {testRedirectURI},
+		"scope":                 {models.PermissionTasksRead},
+		"state":                 {"xyz"},
+		"code_challenge":        {oidc.CodeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	t.Run("Consent Screen Details", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/oauth/authorize?"+authorizeQuery.Encode(), nil, user.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var details services.AuthorizationDetails
+		ParseResponse(t, resp, &details)
+		assert.Equal(t, "Partner App", details.Client.Name)
+		assert.Equal(t, []string{models.PermissionTasksRead}, details.Scopes)
+		assert.True(t, details.ConsentRequired)
+	})
+
+	t.Run("Invalid Requests Are Rejected", func(t *testing.T) {
+		for name, change := range map[string][2]string{
+			"Unregistered Redirect": {"redirect_uri", "https://evil.example.com/callback"},
+			"Plain PKCE":            {"code_challenge_method", "plain"},
+			"Unknown Scope":         {"scope", models.PermissionUsersWrite},
+			"Implicit Flow":         {"response_type", "token"},
+		} {
+			query := url.Values{}
+			for k, v := range authorizeQuery {
+				query[k] = v
+			}
+			query.Set(change[0], change[1])
+
+			resp, err := app.MakeRequest(http.MethodGet, "/api/oauth/authorize?"+query.Encode(), nil, user.Token.AccessToken)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, name)
+		}
+	})
+
+	t.Run("Denied Consent Redirects With Error", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/oauth/authorize", models.ConsentPayload{
+			AuthorizeRequest: models.AuthorizeRequest{
+				ResponseType:        "code",
+				ClientID:            clientID,
+				RedirectURI:         testRedirectURI,
+				State:               "xyz",
+				CodeChallenge:       oidc.CodeChallenge(verifier),
+				CodeChallengeMethod: "S256",
+			},
+		}, user.Token.AccessToken)
+		assert.NoError(t, err)
+
+		var body struct {
+			RedirectURI string `json:"redirect_uri"`
+		}
+		ParseResponse(t, resp, &body)
+		assert.Equal(t, testRedirectURI+"?error=access_denied&state=xyz", body.RedirectURI)
+	})
+
+	var tokens services.OAuthTokenResponse
+
+	t.Run("Code Exchange Requires The PKCE Verifier", func(t *testing.T) {
+		code := app.authorizeCode(t, clientID, user.Token.AccessToken, models.PermissionTasksRead, verifier)
+
+		otherVerifier, _ := oidc.RandomString()
+		resp := app.postForm(t, "/api/oauth/token", url.Values{
+			"grant_type":    {models.GrantTypeAuthorizationCode},
+			"code":          {code},
+			"redirect_uri":  {testRedirectURI},
+			"code_verifier": {otherVerifier},
+		}, clientID, secret)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		var errResp oauthTokenError
+		ParseResponse(t, resp, &errResp)
+		assert.Equal(t, "invalid_grant", errResp.Error)
+
+		resp = app.postForm(t, "/api/oauth/token", url.Values{
+			"grant_type":    {models.GrantTypeAuthorizationCode},
+			"code":          {code},
+			"redirect_uri":  {testRedirectURI},
+			"code_verifier": {verifier},
+		}, clientID, secret)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+		ParseResponse(t, resp, &tokens)
+		assert.Equal(t, "Bearer", tokens.TokenType)
+		assert.Equal(t, models.PermissionTasksRead, tokens.Scope)
+		assert.NotEmpty(t, tokens.RefreshToken)
+	})
+
+	t.Run("Access Token Is Limited To The Granted Scope", func(t *testing.T) {
+		claims, err := app.AuthSvc.ValidateAccessToken(tokens.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, clientID, claims.ClientID)
+		assert.Equal(t, user.User.ID.String(), claims.Subject)
+		assert.Equal(t, []string{models.PermissionTasksRead}, claims.Permissions)
+
+		resp, err := app.MakeRequest(http.MethodGet, "/api/me", nil, tokens.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// Account management stays with the user
+		resp, err = app.MakeRequest(http.MethodGet, "/api/me/sessions", nil, tokens.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodGet, "/api/oauth/authorize?"+authorizeQuery.Encode(), nil, tokens.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Consent Is Remembered", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/oauth/authorize?"+authorizeQuery.Encode(), nil, user.Token.AccessToken)
+		assert.NoError(t, err)
+
+		var details services.AuthorizationDetails
+		ParseResponse(t, resp, &details)
+		assert.False(t, details.ConsentRequired)
+	})
+
+	t.Run("Refresh Token Grant", func(t *testing.T) {
+		// Refresh tokens issued to a client cannot be used at the first-party endpoint
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/refresh", map[string]string{
+			"refresh_token": tokens.RefreshToken,
+		}, "")
+		assert.NoError(t, err)
+		assert.NotEqual(t, http.StatusOK, resp.StatusCode)
+
+		resp = app.postForm(t, "/api/oauth/token", url.Values{
+			"grant_type":    {models.GrantTypeRefreshToken},
+			"refresh_token": {tokens.RefreshToken},
+			"scope":         {models.PermissionTasksWrite},
+		}, clientID, secret)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		var errResp oauthTokenError
+		ParseResponse(t, resp, &errResp)
+		assert.Equal(t, "invalid_scope", errResp.Error)
+
+		resp = app.postForm(t, "/api/oauth/token", url.Values{
+			"grant_type":    {models.GrantTypeRefreshToken},
+			"refresh_token": {tokens.RefreshToken},
+		}, clientID, secret)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var refreshed services.OAuthTokenResponse
+		ParseResponse(t, resp, &refreshed)
+		assert.Equal(t, models.PermissionTasksRead, refreshed.Scope)
+		assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+		tokens = refreshed
+	})
+
+	t.Run("Introspection And Revocation", func(t *testing.T) {
+		code := app.authorizeCode(t, clientID, user.Token.AccessToken, "", verifier)
+		resp := app.postForm(t, "/api/oauth/token", url.Values{
+			"grant_type":    {models.GrantTypeAuthorizationCode},
+			"code":          {code},
+			"redirect_uri":  {testRedirectURI},
+			"code_verifier": {verifier},
+		}, clientID, secret)
+		var fresh services.OAuthTokenResponse
+		ParseResponse(t, resp, &fresh)
+		assert.Equal(t, "tasks:read tasks:write", fresh.Scope)
+
+		resp = app.postForm(t, "/api/oauth/introspect", url.Values{"token": {fresh.AccessToken}}, clientID, secret)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var info services.IntrospectionResponse
+		ParseResponse(t, resp, &info)
+		assert.True(t, info.Active)
+		assert.Equal(t, clientID, info.ClientID)
+		assert.Equal(t, user.User.ID.String(), info.Subject)
+
+		// Other clients cannot inspect the token
+		other, otherSecret := app.createOAuthClient(t, models.CreateOAuthClientPayload{
+			Name:       "Other App",
+			GrantTypes: []string{models.GrantTypeClientCredentials},
+			Scopes:     []string{models.PermissionTasksRead},
+		})
+		resp = app.postForm(t, "/api/oauth/introspect", url.Values{"token": {fresh.AccessToken}}, other.ID.String(), otherSecret)
+		ParseResponse(t, resp, &info)
+		assert.False(t, info.Active)
+
+		resp = app.postForm(t, "/api/oauth/revoke", url.Values{
+			"token":           {fresh.RefreshToken},
+			"token_type_hint": {"refresh_token"},
+		}, clientID, secret)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = app.postForm(t, "/api/oauth/introspect", url.Values{"token": {fresh.RefreshToken}}, clientID, secret)
+		ParseResponse(t, resp, &info)
+		assert.False(t, info.Active)
+
+		resp = app.postForm(t, "/api/oauth/token", url.Values{
+			"grant_type":    {models.GrantTypeRefreshToken},
+			"refresh_token": {fresh.RefreshToken},
+		}, clientID, secret)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		// Revoking an access token denylists it; unknown tokens are ignored
+		resp = app.postForm(t, "/api/oauth/revoke", url.Values{"token": {fresh.AccessToken}}, clientID, secret)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp, err := app.MakeRequest(http.MethodGet, "/api/me", nil, fresh.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp = app.postForm(t, "/api/oauth/revoke", url.Values{"token": {"not-a-token"}}, clientID, secret)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Replayed Code Revokes Issued Tokens", func(t *testing.T) {
+		code := app.authorizeCode(t, clientID, user.Token.AccessToken, "", verifier)
+		form := url.Values{
+			"grant_type":    {models.GrantTypeAuthorizationCode},
+			"code":          {code},
+			"redirect_uri":  {testRedirectURI},
+			"code_verifier": {verifier},
+		}
+
+		resp := app.postForm(t, "/api/oauth/token", form, clientID, secret)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var first services.OAuthTokenResponse
+		ParseResponse(t, resp, &first)
+
+		resp = app.postForm(t, "/api/oauth/token", form, clientID, secret)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp = app.postForm(t, "/api/oauth/token", url.Values{
+			"grant_type":    {models.GrantTypeRefreshToken},
+			"refresh_token": {first.RefreshToken},
+		}, clientID, secret)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Redirect URI Is Only Required When It Was Sent", func(t *testing.T) {
+		exchange := func(code, redirectURI string) *http.Response {
+			form := url.Values{
+				"grant_type":    {models.GrantTypeAuthorizationCode},
+				"code":          {code},
+				"code_verifier": {verifier},
+			}
+			if redirectURI != "" {
+				form.Set("redirect_uri", redirectURI)
+			}
+			return app.postForm(t, "/api/oauth/token", form, clientID, secret)
+		}
+		// authorizeWithDefault omits redirect_uri so the registered one is used
+		authorizeWithDefault := func() string {
+			resp, err := app.MakeRequest(http.MethodPost, "/api/oauth/authorize", models.ConsentPayload{
+				AuthorizeRequest: models.AuthorizeRequest{
+					ResponseType:        "code",
+					ClientID:            clientID,
+					CodeChallenge:       oidc.CodeChallenge(verifier),
+					CodeChallengeMethod: "S256",
+				},
+				Approve: true,
+			}, user.Token.AccessToken)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var body struct {
+				RedirectURI string `json:"redirect_uri"`
+			}
+			ParseResponse(t, resp, &body)
+			redirect, err := url.Parse(body.RedirectURI)
+			assert.NoError(t, err)
+			assert.Equal(t, testRedirectURI, redirect.Scheme+"://"+redirect.Host+redirect.Path)
+			return redirect.Query().Get("code")
+		}
+
+		resp := exchange(authorizeWithDefault(), "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = exchange(authorizeWithDefault(), testRedirectURI)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = exchange(authorizeWithDefault(), "https://evil.example.com/callback")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		// A redirect URI named in the authorization request must be repeated
+		resp = exchange(app.authorizeCode(t, clientID, user.Token.AccessToken, "", verifier), "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Client Authentication", func(t *testing.T) {
+		resp := app.postForm(t, "/api/oauth/token", url.Values{
+			"grant_type":    {models.GrantTypeRefreshToken},
+			"refresh_token": {tokens.RefreshToken},
+		}, clientID, "wrong-secret")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		var errResp oauthTokenError
+		ParseResponse(t, resp, &errResp)
+		assert.Equal(t, "invalid_client", errResp.Error)
+
+		resp = app.postForm(t, "/api/oauth/token", url.Values{
+			"grant_type": {models.GrantTypeClientCredentials},
+		}, clientID, secret)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		ParseResponse(t, resp, &errResp)
+		assert.Equal(t, "unauthorized_client", errResp.Error)
+	})
+}
+
+func TestOAuthPublicClient(t *testing.T) {
+	app := SetupTestApp(t)
+	user := app.RegisterTestUser(t)
+	client, secret := app.createOAuthClient(t, models.CreateOAuthClientPayload{
+		Name:         "Mobile App",
+		Public:       true,
+		RedirectURIs: []string{testRedirectURI},
+		GrantTypes:   []string{models.GrantTypeAuthorizationCode},
+		Scopes:       []string{models.PermissionTasksRead},
+	})
+	assert.Empty(t, secret)
+
+	verifier, _ := oidc.RandomString()
+	code := app.authorizeCode(t, client.ID.String(), user.Token.AccessToken, "", verifier)
+
+	resp := app.postForm(t, "/api/oauth/token", url.Values{
+		"grant_type":    {models.GrantTypeAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {verifier},
+	}, client.ID.String(), "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var tokens services.OAuthTokenResponse
+	ParseResponse(t, resp, &tokens)
+	assert.NotEmpty(t, tokens.AccessToken)
+	// The client is not registered for the refresh_token grant
+	assert.Empty(t, tokens.RefreshToken)
+
+	_, _, err := app.OAuthSvc.CreateClient(&models.CreateOAuthClientPayload{
+		Name:       "Public Machine",
+		Public:     true,
+		GrantTypes: []string{models.GrantTypeClientCredentials},
+		Scopes:     []string{models.PermissionTasksRead},
+	})
+	assert.Error(t, err)
+}
+
+func TestOAuthClientCredentials(t *testing.T) {
+	app := SetupTestApp(t)
+
+	adminEmail := randomEmail()
+	admin, err := app.AuthSvc.EnsureAdmin("Admin User", adminEmail, "Sup3rSecret!Pass")
+	assert.NoError(t, err)
+	app.EnableTestMFA(t, admin.ID)
+	adminResp := app.LoginTestUserMFA(t, adminEmail, "Sup3rSecret!Pass")
+
+	resp, err := app.MakeRequest(http.MethodPost, "/api/admin/oauth/clients", models.CreateOAuthClientPayload{
+		Name:       "Reporting Service",
+		GrantTypes: []string{models.GrantTypeClientCredentials},
+		Scopes:     []string{models.PermissionTasksRead, "unknown:scope"},
+	}, adminResp.Token.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = app.MakeRequest(http.MethodPost, "/api/admin/oauth/clients", models.CreateOAuthClientPayload{
+		Name:       "Reporting Service",
+		GrantTypes: []string{models.GrantTypeClientCredentials},
+		Scopes:     []string{models.PermissionTasksRead},
+	}, adminResp.Token.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var created struct {
+		Client       models.OAuthClient `json:"client"`
+		ClientSecret string             `json:"client_secret"`
+	}
+	ParseResponse(t, resp, &created)
+	assert.NotEmpty(t, created.ClientSecret)
+	clientID := created.Client.ID.String()
+
+	// Credentials may also be sent in the request body
+	form := url.Values{
+		"grant_type":    {models.GrantTypeClientCredentials},
+		"client_id":     {clientID},
+		"client_secret": {created.ClientSecret},
+	}
+	resp = app.postForm(t, "/api/oauth/token", form, "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var tokens services.OAuthTokenResponse
+	ParseResponse(t, resp, &tokens)
+	assert.Empty(t, tokens.RefreshToken)
+
+	claims, err := app.AuthSvc.ValidateAccessToken(tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, clientID, claims.Subject)
+	assert.Equal(t, []string{models.PermissionTasksRead}, claims.Permissions)
+
+	form.Set("scope", models.PermissionTasksWrite)
+	resp = app.postForm(t, "/api/oauth/token", form, "", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = app.MakeRequest(http.MethodDelete, "/api/admin/oauth/clients/"+clientID, nil, adminResp.Token.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	form.Del("scope")
+	resp = app.postForm(t, "/api/oauth/token", form, "", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,426 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fiber-gorm/internal/config"
+	"fiber-gorm/internal/handlers"
+	"fiber-gorm/internal/keys"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/oidc"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubUser is the account a user "logs in" with at the stub provider
+type stubUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type stubAuthorization struct {
+	user          stubUser
+	nonce         string
+	codeChallenge string
+	redirectURI   string
+}
+
+// stubOIDCProvider is a minimal OpenID provider running inside the test process
+type stubOIDCProvider struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+	// NonceOverride, when set, replaces the nonce in issued ID tokens
+	NonceOverride string
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]stubAuthorization
+}
+
+func newStubOIDCProvider(t *testing.T) *stubOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	p := &stubOIDCProvider{
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		key:          key,
+		codes:        make(map[string]stubAuthorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidc.Discovery{
+			Issuer:                p.Server.URL,
+			AuthorizationEndpoint: p.Server.URL + "/authorize",
+			TokenEndpoint:         p.Server.URL + "/token",
+			JWKSURI:               p.Server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(keys.JWKSet{Keys: []keys.JWK{{
+			Kty: "RSA",
+			Kid: "stub-key",
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", p.token)
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Server.Close)
+	return p
+}
+
+// Authorize plays the user approving the login at the provider and returns
+// the code and state the provider would redirect back with
+func (p *stubOIDCProvider) Authorize(t *testing.T, authURL string, user stubUser) (string, string) {
+	parsed, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	query := parsed.Query()
+
+	assert.Equal(t, p.ClientID, query.Get("client_id"))
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.NotEmpty(t, query.Get("nonce"))
+
+	code, err := oidc.RandomString()
+	assert.NoError(t, err)
+
+	p.mu.Lock()
+	p.codes[code] = stubAuthorization{
+		user:          user,
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		redirectURI:   query.Get("redirect_uri"),
+	}
+	p.mu.Unlock()
+
+	return code, query.Get("state")
+}
+
+func (p *stubOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != p.ClientID || clientSecret != p.ClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	p.mu.Lock()
+	auth, found := p.codes[r.FormValue("code")]
+	delete(p.codes, r.FormValue("code"))
+	p.mu.Unlock()
+
+	if !found || r.FormValue("grant_type") != "authorization_code" ||
+		r.FormValue("redirect_uri") != auth.redirectURI ||
+		oidc.CodeChallenge(r.FormValue("code_verifier")) != auth.codeChallenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	nonce := auth.nonce
+	if p.NonceOverride != "" {
+		nonce = p.NonceOverride
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, oidc.IDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.Server.URL,
+			Subject:   auth.user.Subject,
+			Audience:  jwt.ClaimStrings{p.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+		Nonce:         nonce,
+		Email:         auth.user.Email,
+		EmailVerified: auth.user.EmailVerified,
+		Name:          auth.user.Name,
+	})
+	idToken.Header["kid"] = "stub-key"
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(oidc.TokenResponse{
+		AccessToken: "stub-access-token",
+		TokenType:   "Bearer",
+		IDToken:     signed,
+	})
+}
+
+// useStubProvider registers the stub provider under the name "stub"
+func (ta *TestApp) useStubProvider(t *testing.T) *stubOIDCProvider {
+	stub := newStubOIDCProvider(t)
+	ta.OIDCSvc.Providers["stub"] = oidc.NewProvider(config.OIDCProviderConfig{
+		Name:         "stub",
+		Issuer:       stub.Server.URL,
+		ClientID:     stub.ClientID,
+		ClientSecret: stub.ClientSecret,
+		RedirectURL:  "http://localhost:3000/api/auth/oidc/stub/callback",
+	})
+	return stub
+}
+
+// oidcLogin runs the whole browser flow against the stub provider and returns the callback response
+func (ta *TestApp) oidcLogin(t *testing.T, stub *stubOIDCProvider, user stubUser) *http.Response {
+	resp, err := ta.MakeRequest(http.MethodGet, "/api/auth/oidc/stub/login", nil, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	code, state := stub.Authorize(t, resp.Header.Get("Location"), user)
+	return ta.oidcCallback(t, code, state)
+}
+
+// oidcCallback sends the provider's redirect back from the browser that
+// started the login, which holds the state cookie
+func (ta *TestApp) oidcCallback(t *testing.T, code, state string) *http.Response {
+	return ta.oidcCallbackFrom(t, code, state, state)
+}
+
+// oidcCallbackFrom sends the provider's redirect back from a browser holding
+// the given state cookie, or none when it is empty
+func (ta *TestApp) oidcCallbackFrom(t *testing.T, code, state, browserState string) *http.Response {
+	query := url.Values{"code": {code}, "state": {state}}
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/stub/callback?"+query.Encode(), nil)
+	if browserState != "" {
+		req.AddCookie(&http.Cookie{Name: handlers.OIDCStateCookie, Value: browserState})
+	}
+	resp, err := ta.App.Test(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestOIDCLogin(t *testing.T) {
+	app := SetupTestApp(t)
+	stub := app.useStubProvider(t)
+
+	newUser := stubUser{Subject: "stub-1", Email: randomEmail(), EmailVerified: true, Name: "Stub User"}
+	var created AuthResponse
+
+	t.Run("First Login Creates Account", func(t *testing.T) {
+		resp := app.oidcLogin(t, stub, newUser)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		ParseResponse(t, resp, &created)
+		assert.NotEmpty(t, created.Token.AccessToken)
+		assert.Equal(t, newUser.Email, created.User.Email)
+		assert.Equal(t, "Stub User", created.User.Name)
+		assert.NotNil(t, created.User.EmailVerifiedAt)
+
+		claims, err := app.AuthSvc.ValidateAccessToken(created.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"oidc"}, claims.AuthMethods)
+	})
+
+	t.Run("Second Login Finds The Same Account", func(t *testing.T) {
+		resp := app.oidcLogin(t, stub, newUser)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var again AuthResponse
+		ParseResponse(t, resp, &again)
+		assert.Equal(t, created.User.ID, again.User.ID)
+	})
+
+	t.Run("Verified Email Links Existing Account", func(t *testing.T) {
+		existing := app.RegisterTestUser(t)
+		assert.NoError(t, app.DB.Model(&models.User{}).Where("id = ?", existing.User.ID).Update("email_verified_at", time.Now()).Error)
+
+		resp := app.oidcLogin(t, stub, stubUser{Subject: "stub-2", Email: existing.User.Email, EmailVerified: true})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var linked AuthResponse
+		ParseResponse(t, resp, &linked)
+		assert.Equal(t, existing.User.ID, linked.User.ID)
+	})
+
+	t.Run("Unverified Email Does Not Link", func(t *testing.T) {
+		existing := app.RegisterTestUser(t)
+
+		resp := app.oidcLogin(t, stub, stubUser{Subject: "stub-3", Email: existing.User.Email})
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("Unverified Local Account Does Not Link", func(t *testing.T) {
+		// Registered by someone who may not own the address
+		existing := app.RegisterTestUser(t)
+
+		resp := app.oidcLogin(t, stub, stubUser{Subject: "stub-4", Email: existing.User.Email, EmailVerified: true})
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		identities, err := app.OIDCSvc.ListIdentities(existing.User.ID.String())
+		assert.NoError(t, err)
+		assert.Empty(t, identities)
+	})
+
+	t.Run("State Is Single Use", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/auth/oidc/stub/login", nil, "")
+		assert.NoError(t, err)
+		code, state := stub.Authorize(t, resp.Header.Get("Location"), newUser)
+
+		resp = app.oidcCallback(t, code, state)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodGet, "/api/auth/oidc/stub/login", nil, "")
+		assert.NoError(t, err)
+		code, _ = stub.Authorize(t, resp.Header.Get("Location"), newUser)
+		resp = app.oidcCallback(t, code, state)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp = app.oidcCallback(t, code, "forged-state")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Login Sets The State Cookie", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/auth/oidc/stub/login", nil, "")
+		assert.NoError(t, err)
+		_, state := stub.Authorize(t, resp.Header.Get("Location"), newUser)
+
+		var cookie *http.Cookie
+		for _, c := range resp.Cookies() {
+			if c.Name == handlers.OIDCStateCookie {
+				cookie = c
+			}
+		}
+		if assert.NotNil(t, cookie) {
+			assert.Equal(t, state, cookie.Value)
+			assert.True(t, cookie.HttpOnly)
+			assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+		}
+	})
+
+	t.Run("Callback From Another Browser Is Rejected", func(t *testing.T) {
+		// The attacker starts a login and sends the victim the callback URL
+		resp, err := app.MakeRequest(http.MethodGet, "/api/auth/oidc/stub/login", nil, "")
+		assert.NoError(t, err)
+		code, state := stub.Authorize(t, resp.Header.Get("Location"), newUser)
+
+		resp = app.oidcCallbackFrom(t, code, state, "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		// The victim's own pending login does not help either
+		resp, err = app.MakeRequest(http.MethodGet, "/api/auth/oidc/stub/login", nil, "")
+		assert.NoError(t, err)
+		_, victimState := stub.Authorize(t, resp.Header.Get("Location"), newUser)
+		resp = app.oidcCallbackFrom(t, code, state, victimState)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Nonce Mismatch Is Rejected", func(t *testing.T) {
+		stub.NonceOverride = "replayed-nonce"
+		defer func() { stub.NonceOverride = "" }()
+
+		resp := app.oidcLogin(t, stub, newUser)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Unknown Provider", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/auth/oidc/nope/login", nil, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("MFA Still Applies", func(t *testing.T) {
+		app.EnableTestMFA(t, created.User.ID)
+
+		resp := app.oidcLogin(t, stub, newUser)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var challenge mfaChallengeResponse
+		ParseResponse(t, resp, &challenge)
+		assert.True(t, challenge.MFARequired)
+
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/login/mfa", models.MFALoginPayload{
+			MFAToken: challenge.MFAToken,
+			Code:     app.NextTOTPCode(t, created.User.ID),
+		}, "")
+		assert.NoError(t, err)
+		var authResp AuthResponse
+		ParseResponse(t, resp, &authResp)
+
+		claims, err := app.AuthSvc.ValidateAccessToken(authResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"oidc", "otp"}, claims.AuthMethods)
+	})
+}
+
+func TestOIDCAccountLinking(t *testing.T) {
+	app := SetupTestApp(t)
+	stub := app.useStubProvider(t)
+	user := app.RegisterTestUser(t)
+	token := user.Token.AccessToken
+
+	// The provider account uses a different email than the local account
+	external := stubUser{Subject: "work-account", Email: randomEmail(), EmailVerified: true}
+
+	resp, err := app.MakeRequest(http.MethodPost, "/api/me/identities/stub", nil, token)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var link struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	ParseResponse(t, resp, &link)
+
+	code, state := stub.Authorize(t, link.AuthorizationURL, external)
+	resp = app.oidcCallback(t, code, state)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = app.MakeRequest(http.MethodGet, "/api/me/identities", nil, token)
+	assert.NoError(t, err)
+	var identities []models.Identity
+	ParseResponse(t, resp, &identities)
+	if !assert.Len(t, identities, 1) {
+		return
+	}
+	assert.Equal(t, "stub", identities[0].Provider)
+	assert.Equal(t, "work-account", identities[0].Subject)
+
+	// Logging in with the provider now reaches the local account
+	resp = app.oidcLogin(t, stub, external)
+	var loggedIn AuthResponse
+	ParseResponse(t, resp, &loggedIn)
+	assert.Equal(t, user.User.ID, loggedIn.User.ID)
+
+	// Linking a provider that verified the user's own address verifies it
+	other := app.RegisterTestUser(t)
+	resp, err = app.MakeRequest(http.MethodPost, "/api/me/identities/stub", nil, other.Token.AccessToken)
+	assert.NoError(t, err)
+	ParseResponse(t, resp, &link)
+	code, state = stub.Authorize(t, link.AuthorizationURL, stubUser{Subject: "own-account", Email: other.User.Email, EmailVerified: true})
+	resp = app.oidcCallback(t, code, state)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	verified, err := app.UserSvc.FindUserById(other.User.ID.String())
+	assert.NoError(t, err)
+	assert.NotNil(t, verified.EmailVerifiedAt)
+
+	// Another user cannot take over the linked identity
+	resp, err = app.MakeRequest(http.MethodPost, "/api/me/identities/stub", nil, other.Token.AccessToken)
+	assert.NoError(t, err)
+	ParseResponse(t, resp, &link)
+	code, state = stub.Authorize(t, link.AuthorizationURL, external)
+	resp = app.oidcCallback(t, code, state)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp, err = app.MakeRequest(http.MethodDelete, "/api/me/identities/"+identities[0].ID.String(), nil, token)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
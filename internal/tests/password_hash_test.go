@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/passhash"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// storedPasswordHash reads a user's password hash from the database
+func (ta *TestApp) storedPasswordHash(t *testing.T, email string) string {
+	user, err := ta.UserRepo.FindUserByEmail(email)
+	assert.NoError(t, err)
+	return user.Password
+}
+
+func TestPasswordHashing(t *testing.T) {
+	app := SetupTestApp(t)
+	authResp := app.RegisterTestUser(t)
+	email := authResp.User.Email
+
+	t.Run("New Passwords Use Argon2id", func(t *testing.T) {
+		hash := app.storedPasswordHash(t, email)
+		assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"), hash)
+
+		match, rehash, err := app.AuthSvc.Hasher.Verify(hash, "Password123!")
+		assert.NoError(t, err)
+		assert.True(t, match)
+		assert.False(t, rehash)
+	})
+
+	t.Run("Legacy Bcrypt Hash Is Upgraded On Login", func(t *testing.T) {
+		legacy, err := bcrypt.GenerateFromPassword([]byte("Password123!"), bcrypt.MinCost)
+		assert.NoError(t, err)
+
+		user, err := app.UserRepo.FindUserByEmail(email)
+		assert.NoError(t, err)
+		user.Password = string(legacy)
+		assert.NoError(t, app.UserRepo.UpdateUser(user))
+
+		app.LoginTestUser(t, email, "Password123!")
+		assert.True(t, strings.HasPrefix(app.storedPasswordHash(t, email), "$argon2id$"))
+
+		// The upgraded hash keeps working
+		app.LoginTestUser(t, email, "Password123!")
+	})
+
+	t.Run("Changed Parameters Trigger Rehash", func(t *testing.T) {
+		stronger, err := passhash.NewHasher(&passhash.Argon2id{
+			Memory:      2048,
+			Iterations:  2,
+			Parallelism: 1,
+			SaltLength:  16,
+			KeyLength:   32,
+		})
+		assert.NoError(t, err)
+		previous := app.AuthSvc.Hasher
+		app.AuthSvc.Hasher = stronger
+		defer func() { app.AuthSvc.Hasher = previous }()
+
+		app.LoginTestUser(t, email, "Password123!")
+		assert.True(t, strings.HasPrefix(app.storedPasswordHash(t, email), "$argon2id$v=19$m=2048,t=2,p=1$"))
+	})
+
+	t.Run("Wrong Password Does Not Rehash", func(t *testing.T) {
+		before := app.storedPasswordHash(t, email)
+
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/login", models.LoginUserPayload{
+			Email:    email,
+			Password: "WrongPassword123!",
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, before, app.storedPasswordHash(t, email))
+	})
+
+	t.Run("Unknown Email Gets The Same Response", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/login", models.LoginUserPayload{
+			Email:    randomEmail(),
+			Password: "Password123!",
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		var body map[string]interface{}
+		ParseResponse(t, resp, &body)
+		assert.Equal(t, "Invalid email or password", body["error"])
+	})
+
+	t.Run("Unknown Algorithm Is Rejected", func(t *testing.T) {
+		cfg := app.Config
+		cfg.PasswordHashAlgorithm = "md5"
+		_, err := passhash.New(cfg)
+		assert.Error(t, err)
+	})
+}
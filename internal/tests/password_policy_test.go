@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fiber-gorm/internal/breached"
+	"fiber-gorm/internal/models"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// registerWithPassword attempts to register a new account with the given password
+func (ta *TestApp) registerWithPassword(t *testing.T, password string) *http.Response {
+	resp, err := ta.MakeRequest(http.MethodPost, "/api/auth/register", models.CreateUserPayload{
+		Name:     "Policy Tester",
+		Email:    "jdoe-" + strings.TrimPrefix(randomEmail(), "test-"),
+		Password: password,
+	}, "")
+	assert.NoError(t, err)
+	return resp
+}
+
+// changePassword changes the password of the user behind the access token
+func (ta *TestApp) changePassword(t *testing.T, accessToken, current, next string) *http.Response {
+	resp, err := ta.MakeRequest(http.MethodPut, "/api/me/password", models.ChangePasswordPayload{
+		CurrentPassword: current,
+		NewPassword:     next,
+	}, accessToken)
+	assert.NoError(t, err)
+	return resp
+}
+
+// writeBreachedList writes a sorted breached password file containing the given passwords
+func writeBreachedList(t *testing.T, passwords ...string) string {
+	lines := []string{
+		"0000000000000000000000000000000000000000:1",
+		"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1",
+	}
+	for _, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(sum[:]))+":42")
+	}
+	sort.Strings(lines)
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	assert.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600))
+	return path
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	app := SetupTestApp(t)
+
+	t.Run("Rules Apply On Registration", func(t *testing.T) {
+		for _, password := range []string{
+			"Sh0rt!",           // too short
+			"NoDigitsHere!",    // no digit
+			"nouppercase123!",  // no uppercase letter
+			"NoSpecial12345",   // no special character
+			"Policy-Tester123", // contains the name
+			"Jdoe-Secret123",   // contains the email address
+		} {
+			resp := app.registerWithPassword(t, password)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, password)
+		}
+
+		resp := app.registerWithPassword(t, "Correct-Horse-42")
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	})
+
+	t.Run("Login Ignores Creation Rules", func(t *testing.T) {
+		authResp := app.RegisterTestUser(t)
+
+		previous := app.AuthSvc.Passwords.Rules
+		app.AuthSvc.Passwords.Rules.MinLength = 20
+		app.AuthSvc.Passwords.Rules.RequireLower = true
+		defer func() { app.AuthSvc.Passwords.Rules = previous }()
+
+		app.LoginTestUser(t, authResp.User.Email, "Password123!")
+	})
+
+	t.Run("Recent Passwords Cannot Be Reused", func(t *testing.T) {
+		authResp := app.RegisterTestUser(t)
+		token := authResp.Token.AccessToken
+
+		resp := app.changePassword(t, token, "Password123!", "Password123!")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		// History size is 3: the current and two previous passwords are refused
+		assert.Equal(t, http.StatusOK, app.changePassword(t, token, "Password123!", "Second-Pass-2").StatusCode)
+		assert.Equal(t, http.StatusOK, app.changePassword(t, token, "Second-Pass-2", "Third-Pass-3").StatusCode)
+
+		resp = app.changePassword(t, token, "Third-Pass-3", "Password123!")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		var body map[string]interface{}
+		ParseResponse(t, resp, &body)
+		assert.Contains(t, body["error"], "used recently")
+
+		assert.Equal(t, http.StatusOK, app.changePassword(t, token, "Third-Pass-3", "Fourth-Pass-4").StatusCode)
+		assert.Equal(t, http.StatusOK, app.changePassword(t, token, "Fourth-Pass-4", "Password123!").StatusCode)
+	})
+
+	t.Run("Reset Refuses Reuse Without Burning The Link", func(t *testing.T) {
+		authResp := app.RegisterTestUser(t)
+		email := authResp.User.Email
+
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/forgot-password", models.ForgotPasswordPayload{Email: email}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		token := app.LastMailedToken(t, email)
+
+		resp, err = app.MakeRequest(http.MethodPost, "/api/auth/reset-password", models.ResetPasswordPayload{
+			Token:    token,
+			Password: "Password123!",
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodPost, "/api/auth/reset-password", models.ResetPasswordPayload{
+			Token:    token,
+			Password: "Brand-New-Pass-1",
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Breached Passwords Are Refused", func(t *testing.T) {
+		list, err := breached.Open(writeBreachedList(t, "Summer2024!", "Welcome-123"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer list.Close()
+
+		app.AuthSvc.Passwords.Breached = list
+		defer func() { app.AuthSvc.Passwords.Breached = nil }()
+
+		for _, password := range []string{"Summer2024!", "Welcome-123"} {
+			resp := app.registerWithPassword(t, password)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			var body map[string]interface{}
+			ParseResponse(t, resp, &body)
+			assert.Contains(t, body["error"], "data breach")
+		}
+
+		resp := app.registerWithPassword(t, "Winter2024!")
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	})
+
+	t.Run("Unsorted Breached List Is Rejected", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "unsorted.txt")
+		assert.NoError(t, os.WriteFile(path, []byte("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF\n0000000000000000000000000000000000000000\n"), 0o600))
+
+		_, err := breached.Open(path)
+		assert.Error(t, err)
+	})
+}
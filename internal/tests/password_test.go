@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"fiber-gorm/internal/models"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordReset(t *testing.T) {
+	app := SetupTestApp(t)
+	authResp := app.RegisterTestUser(t)
+	email := authResp.User.Email
+
+	resp, err := app.MakeRequest(http.MethodPost, "/api/auth/forgot-password", models.ForgotPasswordPayload{Email: email}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	token := app.LastMailedToken(t, email)
+
+	t.Run("Weak Password Is Rejected", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/reset-password", models.ResetPasswordPayload{
+			Token:    token,
+			Password: "weakpassword",
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Reset Password", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/reset-password", models.ResetPasswordPayload{
+			Token:    token,
+			Password: "NewPassword123!",
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		app.LoginTestUser(t, email, "NewPassword123!")
+	})
+
+	t.Run("Token Is Single Use", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/reset-password", models.ResetPasswordPayload{
+			Token:    token,
+			Password: "OtherPassword123!",
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Refresh Tokens Are Revoked", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/refresh", map[string]string{
+			"refresh_token": authResp.Token.RefreshToken,
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	app := SetupTestApp(t)
+	authResp := app.RegisterTestUser(t)
+
+	t.Run("Current Password Is Required", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPut, "/api/me/password", models.ChangePasswordPayload{
+			CurrentPassword: "WrongPassword123!",
+			NewPassword:     "NewPassword123!",
+		}, authResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Change Password", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPut, "/api/me/password", models.ChangePasswordPayload{
+			CurrentPassword: "Password123!",
+			NewPassword:     "NewPassword123!",
+		}, authResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		app.LoginTestUser(t, authResp.User.Email, "NewPassword123!")
+	})
+}
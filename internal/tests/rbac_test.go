@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"fiber-gorm/internal/handlers"
+	"fiber-gorm/internal/models"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleBasedAccess(t *testing.T) {
+	app := SetupTestApp(t)
+	userResp := app.RegisterTestUser(t)
+
+	adminEmail := randomEmail()
+	admin, err := app.AuthSvc.EnsureAdmin("Admin User", adminEmail, "Sup3rSecret!Pass")
+	assert.NoError(t, err)
+	passwordOnlyResp := app.LoginTestUser(t, adminEmail, "Sup3rSecret!Pass")
+	app.EnableTestMFA(t, admin.ID)
+	adminResp := app.LoginTestUserMFA(t, adminEmail, "Sup3rSecret!Pass")
+
+	t.Run("New Users Get The User Role", func(t *testing.T) {
+		assert.NotNil(t, userResp.User.Role)
+		assert.Equal(t, models.RoleUser, userResp.User.Role.Name)
+	})
+
+	t.Run("Regular User Is Forbidden", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/admin/users", nil, userResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Admin Without MFA Is Forbidden", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/admin/users", nil, passwordOnlyResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Admin Can List Users", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/admin/users", nil, adminResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var users []models.User
+		ParseResponse(t, resp, &users)
+		assert.Len(t, users, 2)
+	})
+
+	t.Run("Admin Can Assign Roles", func(t *testing.T) {
+		url := "/api/admin/users/" + userResp.User.ID.String() + "/role"
+
+		resp, err := app.MakeRequest(http.MethodPut, url, models.AssignRolePayload{Role: "unknown"}, adminResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodPut, url, models.AssignRolePayload{Role: models.RoleAdmin}, adminResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// The new role takes effect once the user obtains a new token
+		resp, err = app.MakeRequest(http.MethodPost, "/api/auth/refresh", map[string]string{
+			"refresh_token": userResp.Token.RefreshToken,
+		}, "")
+		assert.NoError(t, err)
+		var tokenResp handlers.TokenResponse
+		ParseResponse(t, resp, &tokenResp)
+
+		claims, err := app.AuthSvc.ValidateAccessToken(tokenResp.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, models.RoleAdmin, claims.Role)
+
+		// but admin routes stay closed until the new admin enables two-factor authentication
+		resp, err = app.MakeRequest(http.MethodGet, "/api/admin/users", nil, tokenResp.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		app.EnableTestMFA(t, userResp.User.ID)
+		promotedResp := app.LoginTestUserMFA(t, userResp.User.Email, "Password123!")
+		resp, err = app.MakeRequest(http.MethodGet, "/api/admin/users", nil, promotedResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"fiber-gorm/internal/handlers"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	app := SetupTestApp(t)
+	authResp := app.RegisterTestUser(t)
+
+	refresh := func(token string) (*http.Response, handlers.TokenResponse) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/refresh", map[string]string{
+			"refresh_token": token,
+		}, "")
+		assert.NoError(t, err)
+
+		var tokenResp handlers.TokenResponse
+		ParseResponse(t, resp, &tokenResp)
+		return resp, tokenResp
+	}
+
+	// First use rotates the token
+	resp, rotated := refresh(authResp.Token.RefreshToken)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEmpty(t, rotated.RefreshToken)
+	assert.NotEqual(t, authResp.Token.RefreshToken, rotated.RefreshToken)
+
+	t.Run("Reused Token Is Rejected", func(t *testing.T) {
+		resp, _ := refresh(authResp.Token.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Reuse Revokes The Family", func(t *testing.T) {
+		resp, _ := refresh(rotated.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Unknown Token Is Rejected", func(t *testing.T) {
+		resp, _ := refresh("not-a-token")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
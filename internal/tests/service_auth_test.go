@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"bytes"
+	"fiber-gorm/internal/middleware"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/pkg/svcauth"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// signedRequest builds a request signed by the given credential
+func signedRequest(t *testing.T, signer *svcauth.Signer, method, url, body string) *http.Request {
+	req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	assert.NoError(t, signer.SignRequest(req))
+	return req
+}
+
+func TestServiceAuth(t *testing.T) {
+	app := SetupTestApp(t)
+	signer := &svcauth.Signer{KeyID: "billing", Secret: []byte("billing-secret")}
+
+	t.Run("Valid Signature Authenticates The Service", func(t *testing.T) {
+		resp, err := app.App.Test(signedRequest(t, signer, http.MethodGet, "/api/auth/service?verbose=1", ""))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body map[string]interface{}
+		ParseResponse(t, resp, &body)
+		assert.Equal(t, "service:billing", body["subject"])
+		assert.Equal(t, []interface{}{models.PermissionTasksRead}, body["permissions"])
+	})
+
+	t.Run("Replayed Request Is Rejected", func(t *testing.T) {
+		req := signedRequest(t, signer, http.MethodGet, "/api/auth/service", "")
+		resp, err := app.App.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		replay := httptest.NewRequest(http.MethodGet, "/api/auth/service", nil)
+		replay.Header = req.Header.Clone()
+		resp, err = app.App.Test(replay)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Stale Timestamp Is Rejected", func(t *testing.T) {
+		stale := &svcauth.Signer{KeyID: "billing", Secret: []byte("billing-secret"), Now: func() time.Time {
+			return time.Now().Add(-10 * time.Minute)
+		}}
+		resp, err := app.App.Test(signedRequest(t, stale, http.MethodGet, "/api/auth/service", ""))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Wrong Secret Or Unknown Key Is Rejected", func(t *testing.T) {
+		for _, bad := range []*svcauth.Signer{
+			{KeyID: "billing", Secret: []byte("wrong-secret")},
+			{KeyID: "unknown", Secret: []byte("billing-secret")},
+		} {
+			resp, err := app.App.Test(signedRequest(t, bad, http.MethodGet, "/api/auth/service", ""))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, bad.KeyID)
+		}
+	})
+
+	t.Run("Tampered Path Is Rejected", func(t *testing.T) {
+		req := signedRequest(t, signer, http.MethodGet, "/api/auth/service?verbose=1", "")
+		tampered := httptest.NewRequest(http.MethodGet, "/api/auth/service?verbose=0", nil)
+		tampered.Header = req.Header.Clone()
+		resp, err := app.App.Test(tampered)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Unsigned Request Is Rejected", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/auth/service", nil, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestServiceAuthPermissions(t *testing.T) {
+	app := SetupTestApp(t)
+	userResp := app.RegisterTestUser(t)
+	signer := &svcauth.Signer{KeyID: "billing", Secret: []byte("billing-secret")}
+
+	// A route shared by users and services, guarded by permissions
+	routes := fiber.New()
+	authenticate := middleware.ServiceAuthMiddleware(app.ServiceAuth, middleware.JWTAuthMiddleware(app.AuthSvc.Verifier, nil))
+	ok := func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"subject": middleware.GetUserID(c)})
+	}
+	routes.Get("/read", authenticate, middleware.RequirePermission(models.PermissionTasksRead), ok)
+	routes.Post("/write", authenticate, middleware.RequirePermission(models.PermissionTasksWrite), ok)
+	routes.Post("/account", authenticate, middleware.DenyAPIKeys(), ok)
+
+	t.Run("Service Has Its Configured Permissions", func(t *testing.T) {
+		resp, err := routes.Test(signedRequest(t, signer, http.MethodGet, "/read", ""))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = routes.Test(signedRequest(t, signer, http.MethodPost, "/write", `{"title":"x"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Tampered Body Is Rejected", func(t *testing.T) {
+		req := signedRequest(t, signer, http.MethodGet, "/read", `{"a":1}`)
+		tampered := httptest.NewRequest(http.MethodGet, "/read", bytes.NewBufferString(`{"a":2}`))
+		tampered.Header = req.Header.Clone()
+		resp, err := routes.Test(tampered)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Users Fall Back To Token Auth", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/read", nil)
+		req.Header.Set("Authorization", "Bearer "+userResp.Token.AccessToken)
+		resp, err := routes.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Services Cannot Use Account Routes", func(t *testing.T) {
+		resp, err := routes.Test(signedRequest(t, signer, http.MethodPost, "/account", ""))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func TestServiceAuthAdminRoutes(t *testing.T) {
+	app := SetupTestApp(t)
+	target := app.RegisterTestUser(t)
+	support := &svcauth.Signer{KeyID: "support", Secret: []byte("support-secret")}
+	billing := &svcauth.Signer{KeyID: "billing", Secret: []byte("billing-secret")}
+
+	t.Run("Service Reads Users With Its Permissions", func(t *testing.T) {
+		resp, err := app.App.Test(signedRequest(t, support, http.MethodGet, "/api/admin/users", ""))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var users []models.User
+		ParseResponse(t, resp, &users)
+		assert.NotEmpty(t, users)
+
+		path := "/api/admin/users/" + target.User.ID.String() + "/unlock"
+		resp, err = app.App.Test(signedRequest(t, support, http.MethodPost, path, ""))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Service Without The Permission Is Refused", func(t *testing.T) {
+		resp, err := app.App.Test(signedRequest(t, billing, http.MethodGet, "/api/admin/users", ""))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Service Cannot Act As An Admin", func(t *testing.T) {
+		path := "/api/admin/users/" + target.User.ID.String()
+		resp, err := app.App.Test(signedRequest(t, support, http.MethodPut, path+"/role", `{"role":"admin"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp, err = app.App.Test(signedRequest(t, support, http.MethodPost, path+"/impersonate", `{"reason":"support"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Users Still Need The Admin Role", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/admin/users", nil, target.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodGet, "/api/admin/users", nil, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestServiceAuthClient(t *testing.T) {
+	secret := []byte("billing-secret")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		canonical := svcauth.Canonical(r.Method, r.URL.RequestURI(), r.Header.Get(svcauth.HeaderKeyID),
+			r.Header.Get(svcauth.HeaderTimestamp), r.Header.Get(svcauth.HeaderNonce), svcauth.HashBody(body))
+		if !svcauth.Verify(secret, canonical, r.Header.Get(svcauth.HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	client := svcauth.NewClient("billing", secret)
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/hook?id=1", bytes.NewBufferString(`{"ok":true}`))
+	assert.NoError(t, err)
+
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	echoed, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"ok":true}`, string(echoed))
+
+	// The caller's request is left unsigned
+	assert.Empty(t, req.Header.Get(svcauth.HeaderSignature))
+}
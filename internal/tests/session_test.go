@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// loginFrom logs in with the given User-Agent header
+func (ta *TestApp) loginFrom(t *testing.T, email, password, userAgent string) AuthResponse {
+	body, err := json.Marshal(models.LoginUserPayload{Email: email, Password: password})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := ta.App.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var authResp AuthResponse
+	ParseResponse(t, resp, &authResp)
+	return authResp
+}
+
+func TestSessions(t *testing.T) {
+	app := SetupTestApp(t)
+	registered := app.RegisterTestUser(t)
+	email := registered.User.Email
+
+	laptop := app.loginFrom(t, email, "Password123!", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36")
+	phone := app.loginFrom(t, email, "Password123!", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1")
+	script := app.loginFrom(t, email, "Password123!", "curl/8.4.0")
+
+	listSessions := func(t *testing.T, token string) []models.Session {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/me/sessions", nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var sessions []models.Session
+		ParseResponse(t, resp, &sessions)
+		return sessions
+	}
+
+	laptopClaims, err := app.AuthSvc.ValidateAccessToken(laptop.Token.AccessToken)
+	assert.NoError(t, err)
+	phoneClaims, err := app.AuthSvc.ValidateAccessToken(phone.Token.AccessToken)
+	assert.NoError(t, err)
+
+	t.Run("List", func(t *testing.T) {
+		sessions := listSessions(t, laptop.Token.AccessToken)
+		assert.Len(t, sessions, 4) // registration, laptop, phone, script
+
+		labels := map[string]bool{}
+		for _, session := range sessions {
+			labels[session.DeviceLabel] = true
+			assert.Equal(t, session.ID.String() == laptopClaims.SessionID, session.Current)
+		}
+		assert.True(t, labels["Chrome on Windows"])
+		assert.True(t, labels["Safari on iPhone"])
+		assert.True(t, labels["curl"])
+	})
+
+	t.Run("Refresh Updates Last Seen", func(t *testing.T) {
+		before := listSessions(t, laptop.Token.AccessToken)
+
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/refresh", map[string]string{
+			"refresh_token": script.Token.RefreshToken,
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var tokenResp struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		ParseResponse(t, resp, &tokenResp)
+		script.Token.RefreshToken = tokenResp.RefreshToken
+
+		after := listSessions(t, laptop.Token.AccessToken)
+		assert.Len(t, after, len(before), "rotation keeps the same session")
+		assert.Equal(t, "curl", after[0].DeviceLabel, "most recently used first")
+	})
+
+	t.Run("Revoke One", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodDelete, "/api/me/sessions/"+phoneClaims.SessionID, nil, laptop.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodPost, "/api/auth/refresh", map[string]string{
+			"refresh_token": phone.Token.RefreshToken,
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		assert.Len(t, listSessions(t, laptop.Token.AccessToken), 3)
+
+		resp, err = app.MakeRequest(http.MethodDelete, "/api/me/sessions/"+phoneClaims.SessionID, nil, laptop.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Cannot Revoke Another User's Session", func(t *testing.T) {
+		other := app.RegisterTestUser(t)
+		resp, err := app.MakeRequest(http.MethodDelete, "/api/me/sessions/"+laptopClaims.SessionID, nil, other.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Revoke All Others", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodDelete, "/api/me/sessions", nil, laptop.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		sessions := listSessions(t, laptop.Token.AccessToken)
+		if assert.Len(t, sessions, 1) {
+			assert.True(t, sessions[0].Current)
+		}
+
+		resp, err = app.MakeRequest(http.MethodPost, "/api/auth/refresh", map[string]string{
+			"refresh_token": script.Token.RefreshToken,
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Revoke Current", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodDelete, "/api/me/sessions/"+laptopClaims.SessionID, nil, laptop.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodGet, "/api/me", nil, laptop.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestDeviceLabel(t *testing.T) {
+	assert.Equal(t, "Firefox on Linux", services.DeviceLabel("Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"))
+	assert.Equal(t, "Edge on Windows", services.DeviceLabel("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36 Edg/120.0"))
+	assert.Equal(t, "Chrome on Android", services.DeviceLabel("Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Mobile Safari/537.36"))
+	assert.Equal(t, "MyApp/1.2", services.DeviceLabel("MyApp/1.2"))
+	assert.Equal(t, "Unknown device", services.DeviceLabel(""))
+	for _, blank := range []string{"\v", "\f", "\u00a0", " \t "} {
+		assert.Equal(t, "Unknown device", services.DeviceLabel(blank))
+	}
+}
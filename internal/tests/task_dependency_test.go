@@ -0,0 +1,286 @@
+package tests
+
+import (
+	"fiber-gorm/internal/models"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// addDependency makes task blocked by blocker
+func (ta *TestApp) addDependency(t *testing.T, token string, task, blocker models.Task) *http.Response {
+	resp, err := ta.MakeRequest(http.MethodPost, "/api/tasks/"+task.ID.String()+"/dependencies", models.AddTaskDependencyPayload{
+		BlockedByID: blocker.ID,
+	}, token)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestSubtasks(t *testing.T) {
+	app := SetupTestApp(t)
+	token := app.RegisterTestUser(t).Token.AccessToken
+
+	createSubtask := func(t *testing.T, name string, parent models.Task) models.Task {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/tasks", models.CreateTaskPayload{Name: name, ParentID: &parent.ID}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var task models.Task
+		ParseResponse(t, resp, &task)
+		return task
+	}
+
+	getTask := func(t *testing.T, task models.Task) models.Task {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/tasks/"+task.ID.String(), nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var loaded models.Task
+		ParseResponse(t, resp, &loaded)
+		return loaded
+	}
+
+	parent := app.createTask(t, token, "Release 2.0")
+	changelog := createSubtask(t, "Write changelog", parent)
+	tag := createSubtask(t, "Tag release", parent)
+	announce := createSubtask(t, "Announce", parent)
+
+	t.Run("Parent Rolls Up Progress", func(t *testing.T) {
+		app.finishTask(t, token, changelog)
+		assert.Equal(t, http.StatusOK, app.transitionTask(t, token, announce, models.TaskStatusCancelled).StatusCode)
+
+		loaded := getTask(t, parent)
+		if assert.NotNil(t, loaded.Progress) {
+			assert.Equal(t, models.TaskProgress{Total: 2, Done: 1, Percent: 50}, *loaded.Progress)
+		}
+		assert.Nil(t, getTask(t, tag).Progress)
+	})
+
+	t.Run("List Subtasks", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/tasks/"+parent.ID.String()+"/subtasks", nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var subtasks []models.Task
+		ParseResponse(t, resp, &subtasks)
+		assert.Len(t, subtasks, 3)
+	})
+
+	t.Run("Parent Cycles Are Refused", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPut, "/api/tasks/"+parent.ID.String(), models.UpdateTaskPayload{Name: "Release 2.0", ParentID: &tag.ID}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodPut, "/api/tasks/"+parent.ID.String(), models.UpdateTaskPayload{Name: "Release 2.0", ParentID: &parent.ID}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("Stored Parent Cycles Are Detected", func(t *testing.T) {
+		first := app.createTask(t, token, "First")
+		second := createSubtask(t, "Second", first)
+		assert.NoError(t, app.DB.Model(&models.Task{}).Where("id = ?", first.ID).Update("parent_id", second.ID).Error)
+
+		resp, err := app.MakeRequest(http.MethodPost, "/api/tasks", models.CreateTaskPayload{Name: "Third", ParentID: &second.ID}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("Parent Must Belong To The User", func(t *testing.T) {
+		otherToken := app.RegisterTestUser(t).Token.AccessToken
+		resp, err := app.MakeRequest(http.MethodPost, "/api/tasks", models.CreateTaskPayload{Name: "Sneaky", ParentID: &parent.ID}, otherToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Deleting A Parent Keeps Its Subtasks", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodDelete, "/api/tasks/"+parent.ID.String(), nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Nil(t, getTask(t, tag).ParentID)
+	})
+}
+
+func TestTaskDependencies(t *testing.T) {
+	app := SetupTestApp(t)
+	token := app.RegisterTestUser(t).Token.AccessToken
+
+	design := app.createTask(t, token, "Design")
+	build := app.createTask(t, token, "Build")
+	ship := app.createTask(t, token, "Ship")
+
+	t.Run("Add Dependencies", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, app.addDependency(t, token, build, design).StatusCode)
+		assert.Equal(t, http.StatusCreated, app.addDependency(t, token, ship, build).StatusCode)
+		// Adding a dependency again is harmless
+		assert.Equal(t, http.StatusCreated, app.addDependency(t, token, ship, build).StatusCode)
+
+		resp, err := app.MakeRequest(http.MethodGet, "/api/tasks/"+ship.ID.String()+"/dependencies", nil, token)
+		assert.NoError(t, err)
+		var blockers []models.Task
+		ParseResponse(t, resp, &blockers)
+		if assert.Len(t, blockers, 1) {
+			assert.Equal(t, build.ID, blockers[0].ID)
+		}
+	})
+
+	t.Run("Cycles Are Refused", func(t *testing.T) {
+		resp := app.addDependency(t, token, design, ship)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		var body struct {
+			Cycle []uuid.UUID `json:"cycle"`
+		}
+		ParseResponse(t, resp, &body)
+		assert.Equal(t, []uuid.UUID{design.ID, ship.ID, build.ID, design.ID}, body.Cycle)
+
+		assert.Equal(t, http.StatusConflict, app.addDependency(t, token, design, design).StatusCode)
+	})
+
+	t.Run("Concurrent Opposite Dependencies", func(t *testing.T) {
+		left := app.createTask(t, token, "Left")
+		right := app.createTask(t, token, "Right")
+
+		statuses := make([]int, 2)
+		var wg sync.WaitGroup
+		for i, pair := range [][2]models.Task{{left, right}, {right, left}} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				statuses[i] = app.addDependency(t, token, pair[0], pair[1]).StatusCode
+			}()
+		}
+		wg.Wait()
+
+		assert.ElementsMatch(t, []int{http.StatusCreated, http.StatusConflict}, statuses)
+	})
+
+	t.Run("Blockers Must Belong To The User", func(t *testing.T) {
+		otherToken := app.RegisterTestUser(t).Token.AccessToken
+		other := app.createTask(t, otherToken, "Other")
+		assert.Equal(t, http.StatusNotFound, app.addDependency(t, token, design, other).StatusCode)
+		assert.Equal(t, http.StatusNotFound, app.addDependency(t, otherToken, other, design).StatusCode)
+	})
+
+	t.Run("Open Blockers Prevent Done", func(t *testing.T) {
+		for _, status := range []string{models.TaskStatusInProgress, models.TaskStatusReview} {
+			assert.Equal(t, http.StatusOK, app.transitionTask(t, token, build, status).StatusCode)
+		}
+		resp := app.transitionTask(t, token, build, models.TaskStatusDone)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		var body struct {
+			BlockedBy []uuid.UUID `json:"blocked_by"`
+		}
+		ParseResponse(t, resp, &body)
+		assert.Equal(t, []uuid.UUID{design.ID}, body.BlockedBy)
+
+		// A cancelled blocker no longer blocks
+		assert.Equal(t, http.StatusOK, app.transitionTask(t, token, design, models.TaskStatusCancelled).StatusCode)
+		assert.Equal(t, http.StatusOK, app.transitionTask(t, token, build, models.TaskStatusDone).StatusCode)
+	})
+
+	t.Run("Done Tasks Cannot Get Open Blockers", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, app.addDependency(t, token, build, ship).StatusCode)
+		assert.Equal(t, http.StatusCreated, app.addDependency(t, token, build, design).StatusCode)
+	})
+
+	t.Run("Concurrent Blocker And Done", func(t *testing.T) {
+		task := app.createTask(t, token, "Publish")
+		blocker := app.createTask(t, token, "Proofread")
+		for _, status := range []string{models.TaskStatusInProgress, models.TaskStatusReview} {
+			assert.Equal(t, http.StatusOK, app.transitionTask(t, token, task, status).StatusCode)
+		}
+
+		var added, finished int
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			added = app.addDependency(t, token, task, blocker).StatusCode
+		}()
+		go func() {
+			defer wg.Done()
+			finished = app.transitionTask(t, token, task, models.TaskStatusDone).StatusCode
+		}()
+		wg.Wait()
+
+		assert.False(t, added == http.StatusCreated && finished == http.StatusOK, "finished with an open blocker")
+	})
+
+	t.Run("Remove Dependency", func(t *testing.T) {
+		url := "/api/tasks/" + ship.ID.String() + "/dependencies/" + build.ID.String()
+		resp, err := app.MakeRequest(http.MethodDelete, url, nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodDelete, url, nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestTaskPlan(t *testing.T) {
+	app := SetupTestApp(t)
+	token := app.RegisterTestUser(t).Token.AccessToken
+
+	plan := func(t *testing.T, tasks ...models.Task) []models.Task {
+		ids := make([]string, len(tasks))
+		for i, task := range tasks {
+			ids[i] = task.ID.String()
+		}
+		resp, err := app.MakeRequest(http.MethodGet, "/api/tasks/plan?ids="+strings.Join(ids, ","), nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var planned []models.Task
+		ParseResponse(t, resp, &planned)
+		return planned
+	}
+	names := func(tasks []models.Task) []string {
+		out := []string{}
+		for _, task := range tasks {
+			out = append(out, task.Name)
+		}
+		return out
+	}
+
+	now := time.Now().UTC()
+	deploy := app.createScheduledTask(t, token, "Deploy", now.Add(72*time.Hour))
+	migrate := app.createScheduledTask(t, token, "Migrate database", now.Add(48*time.Hour))
+	backup := app.createScheduledTask(t, token, "Backup database", now.Add(24*time.Hour))
+	review := app.createScheduledTask(t, token, "Code review", now.Add(12*time.Hour))
+	docs := app.createTask(t, token, "Update docs")
+
+	app.addDependency(t, token, deploy, migrate)
+	app.addDependency(t, token, deploy, review)
+	app.addDependency(t, token, migrate, backup)
+
+	t.Run("Blockers Come First", func(t *testing.T) {
+		planned := plan(t, deploy, docs)
+		assert.Equal(t, []string{"Code review", "Backup database", "Migrate database", "Deploy", "Update docs"}, names(planned))
+		assert.ElementsMatch(t, []uuid.UUID{migrate.ID, review.ID}, planned[3].BlockedBy)
+	})
+
+	t.Run("Finished Blockers Are Left Out", func(t *testing.T) {
+		app.finishTask(t, token, backup)
+		planned := plan(t, deploy)
+		assert.Equal(t, []string{"Code review", "Migrate database", "Deploy"}, names(planned))
+		assert.Empty(t, planned[1].BlockedBy)
+	})
+
+	t.Run("Unknown Tasks", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/tasks/plan?ids="+uuid.NewString(), nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodGet, "/api/tasks/plan", nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
@@ -0,0 +1,391 @@
+package tests
+
+import (
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/rrule"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// finishTask moves a task through the workflow to done and returns it
+func (ta *TestApp) finishTask(t *testing.T, token string, task models.Task) models.Task {
+	var resp *http.Response
+	for _, status := range []string{models.TaskStatusInProgress, models.TaskStatusReview, models.TaskStatusDone} {
+		resp = ta.transitionTask(t, token, task, status)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	var finished models.Task
+	ParseResponse(t, resp, &finished)
+	return finished
+}
+
+// recurrence returns a recurrence rule for an update payload
+func recurrence(rule string) *string {
+	return &rule
+}
+
+// occurrences returns the due dates a rule produces after the start, at most n
+func occurrences(t *testing.T, rule string, start time.Time, loc *time.Location, n int) []time.Time {
+	r, err := rrule.Parse(rule)
+	assert.NoError(t, err)
+
+	var dates []time.Time
+	for after := start; len(dates) < n; {
+		next, ok := r.Next(start, after, loc)
+		if !ok {
+			break
+		}
+		dates = append(dates, next)
+		after = next
+	}
+	return dates
+}
+
+func TestRecurrenceRules(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	t.Run("Weekly By Day", func(t *testing.T) {
+		// Monday 2026-03-02
+		start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+		dates := occurrences(t, "FREQ=WEEKLY;BYDAY=MO,WE", start, time.UTC, 3)
+		assert.Equal(t, []time.Time{
+			time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC),
+			time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC),
+			time.Date(2026, 3, 11, 9, 0, 0, 0, time.UTC),
+		}, dates)
+	})
+
+	t.Run("Last Friday Of The Month", func(t *testing.T) {
+		start := time.Date(2026, 1, 30, 17, 0, 0, 0, time.UTC)
+		dates := occurrences(t, "RRULE:FREQ=MONTHLY;BYDAY=-1FR", start, time.UTC, 2)
+		assert.Equal(t, []time.Time{
+			time.Date(2026, 2, 27, 17, 0, 0, 0, time.UTC),
+			time.Date(2026, 3, 27, 17, 0, 0, 0, time.UTC),
+		}, dates)
+	})
+
+	t.Run("Skips Missing Days", func(t *testing.T) {
+		start := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+		dates := occurrences(t, "FREQ=MONTHLY", start, time.UTC, 2)
+		assert.Equal(t, []time.Time{
+			time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC),
+			time.Date(2026, 5, 31, 9, 0, 0, 0, time.UTC),
+		}, dates)
+	})
+
+	t.Run("Count Includes The Start", func(t *testing.T) {
+		start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+		dates := occurrences(t, "FREQ=DAILY;INTERVAL=2;COUNT=3", start, time.UTC, 10)
+		assert.Equal(t, []time.Time{
+			time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC),
+			time.Date(2026, 3, 6, 9, 0, 0, 0, time.UTC),
+		}, dates)
+	})
+
+	t.Run("Count Includes A Start The Rule Does Not Match", func(t *testing.T) {
+		// Tuesday 2026-03-03
+		start := time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC)
+		dates := occurrences(t, "FREQ=WEEKLY;BYDAY=MO;COUNT=2", start, time.UTC, 10)
+		assert.Equal(t, []time.Time{
+			time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC),
+		}, dates)
+	})
+
+	t.Run("Until Date Includes The Whole Day", func(t *testing.T) {
+		start := time.Date(2026, 3, 2, 9, 0, 0, 0, newYork)
+		dates := occurrences(t, "FREQ=DAILY;UNTIL=20260304", start, newYork, 10)
+		assert.Len(t, dates, 2)
+	})
+
+	t.Run("Keeps The Local Time Across DST", func(t *testing.T) {
+		// Clocks in New York move forward on 2026-03-08
+		start := time.Date(2026, 3, 6, 9, 0, 0, 0, newYork)
+		dates := occurrences(t, "FREQ=DAILY", start, newYork, 3)
+		for _, date := range dates {
+			assert.Equal(t, 9, date.Hour())
+		}
+		assert.Equal(t, 23*time.Hour, dates[1].Sub(dates[0]))
+		assert.Equal(t, 24*time.Hour, dates[2].Sub(dates[1]))
+	})
+
+	t.Run("Yearly By Month And Day", func(t *testing.T) {
+		start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+		dates := occurrences(t, "FREQ=YEARLY;BYMONTH=1;BYDAY=1MO", start, time.UTC, 2)
+		assert.Equal(t, []time.Time{
+			time.Date(2027, 1, 4, 9, 0, 0, 0, time.UTC),
+			time.Date(2028, 1, 3, 9, 0, 0, 0, time.UTC),
+		}, dates)
+	})
+
+	t.Run("Invalid Rules", func(t *testing.T) {
+		for _, rule := range []string{
+			"",
+			"INTERVAL=2",
+			"FREQ=HOURLY",
+			"FREQ=DAILY;COUNT=0",
+			"FREQ=DAILY;COUNT=2;UNTIL=20260301",
+			"FREQ=WEEKLY;BYDAY=1MO",
+			"FREQ=YEARLY;BYDAY=20MO",
+			"FREQ=WEEKLY;BYMONTHDAY=1",
+			"FREQ=YEARLY;BYDAY=1MO",
+			"FREQ=YEARLY;BYMONTHDAY=1",
+			"FREQ=MONTHLY;BYMONTHDAY=32",
+		} {
+			_, err := rrule.Parse(rule)
+			assert.Error(t, err, rule)
+		}
+	})
+}
+
+func TestRecurringTasks(t *testing.T) {
+	app := SetupTestApp(t)
+	token := app.RegisterTestUser(t).Token.AccessToken
+
+	createRecurring := func(t *testing.T, name, rule, tz string, dueAt time.Time) models.Task {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/tasks", models.CreateTaskPayload{
+			Name:               name,
+			DueAt:              &dueAt,
+			RecurrenceRule:     rule,
+			RecurrenceTimeZone: tz,
+		}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var task models.Task
+		ParseResponse(t, resp, &task)
+		return task
+	}
+
+	updateTask := func(t *testing.T, task models.Task, scope string, payload models.UpdateTaskPayload) *http.Response {
+		resp, err := app.MakeRequest(http.MethodPut, "/api/tasks/"+task.ID.String()+"?scope="+scope, payload, token)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Recurrence Needs A Valid Rule And A Due Date", func(t *testing.T) {
+		dueAt := time.Now().Add(time.Hour)
+		for _, payload := range []models.CreateTaskPayload{
+			{Name: "No due date", RecurrenceRule: "FREQ=DAILY"},
+			{Name: "Bad rule", DueAt: &dueAt, RecurrenceRule: "FREQ=SOMETIMES"},
+			{Name: "Bad zone", DueAt: &dueAt, RecurrenceRule: "FREQ=DAILY", RecurrenceTimeZone: "Mars/Olympus"},
+			{Name: "Never repeats", DueAt: &dueAt, RecurrenceRule: "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30"},
+			{Name: "Ends with the due date", DueAt: &dueAt, RecurrenceRule: "FREQ=DAILY;COUNT=1"},
+		} {
+			resp, err := app.MakeRequest(http.MethodPost, "/api/tasks", payload, token)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, payload.Name)
+		}
+	})
+
+	t.Run("Finishing Creates The Next Occurrence", func(t *testing.T) {
+		newYork, _ := time.LoadLocation("America/New_York")
+		dueAt := time.Date(2026, 3, 6, 9, 0, 0, 0, newYork)
+		task := createRecurring(t, "Water plants", "FREQ=DAILY", "America/New_York", dueAt)
+		if assert.NotNil(t, task.Series) {
+			assert.Equal(t, "FREQ=DAILY", task.Series.RecurrenceRule)
+			assert.Equal(t, task.Series.ID, *task.SeriesID)
+		}
+
+		finished := app.finishTask(t, token, task)
+		next := finished.NextOccurrence
+		if assert.NotNil(t, next) {
+			assert.Equal(t, "Water plants", next.Name)
+			assert.Equal(t, models.TaskStatusTodo, next.Status)
+			assert.Equal(t, task.SeriesID, next.SeriesID)
+			assert.True(t, time.Date(2026, 3, 7, 9, 0, 0, 0, newYork).Equal(*next.DueAt))
+		}
+
+		// Across the DST change the task stays at 09:00 local time
+		after := app.finishTask(t, token, *next).NextOccurrence
+		if assert.NotNil(t, after) {
+			assert.True(t, time.Date(2026, 3, 8, 9, 0, 0, 0, newYork).Equal(*after.DueAt))
+		}
+	})
+
+	t.Run("Recurring Subtask Stays With Its Parent", func(t *testing.T) {
+		parent := app.createTask(t, token, "Household")
+		dueAt := time.Now().Add(time.Hour).UTC()
+		resp, err := app.MakeRequest(http.MethodPost, "/api/tasks", models.CreateTaskPayload{
+			Name:           "Take out the trash",
+			DueAt:          &dueAt,
+			RecurrenceRule: "FREQ=WEEKLY",
+			ParentID:       &parent.ID,
+		}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		var task models.Task
+		ParseResponse(t, resp, &task)
+
+		next := app.finishTask(t, token, task).NextOccurrence
+		if assert.NotNil(t, next) && assert.NotNil(t, next.ParentID) {
+			assert.Equal(t, parent.ID, *next.ParentID)
+		}
+	})
+
+	t.Run("Finishing Fails Without The Next Occurrence", func(t *testing.T) {
+		task := createRecurring(t, "Pay rent", "FREQ=MONTHLY", "UTC", time.Now().Add(time.Hour).UTC())
+		assert.NoError(t, app.DB.Model(&models.TaskSeries{}).Where("id = ?", task.SeriesID).
+			UpdateColumn("time_zone", "Mars/Olympus").Error)
+
+		resp := app.transitionTask(t, token, task, models.TaskStatusCancelled)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+		var stored models.Task
+		assert.NoError(t, app.DB.First(&stored, "id = ?", task.ID).Error)
+		assert.Equal(t, models.TaskStatusTodo, stored.Status)
+	})
+
+	t.Run("Reopening Does Not Duplicate The Next Occurrence", func(t *testing.T) {
+		task := createRecurring(t, "Stand-up notes", "FREQ=WEEKLY", "", time.Now().Add(time.Hour).UTC())
+		assert.Equal(t, "UTC", task.Series.TimeZone)
+		assert.NotNil(t, app.finishTask(t, token, task).NextOccurrence)
+
+		assert.Equal(t, http.StatusOK, app.transitionTask(t, token, task, models.TaskStatusTodo).StatusCode)
+		assert.Nil(t, app.finishTask(t, token, task).NextOccurrence)
+
+		open := 0
+		for _, name := range app.listTasks(t, token, "status=todo") {
+			if name == "Stand-up notes" {
+				open++
+			}
+		}
+		assert.Equal(t, 1, open)
+	})
+
+	t.Run("Count Ends The Series", func(t *testing.T) {
+		task := createRecurring(t, "Physio", "FREQ=DAILY;COUNT=2", "UTC", time.Now().Add(time.Hour).UTC())
+		second := app.finishTask(t, token, task).NextOccurrence
+		if assert.NotNil(t, second) {
+			assert.Nil(t, app.finishTask(t, token, *second).NextOccurrence)
+		}
+	})
+
+	t.Run("Editing This Occurrence Only", func(t *testing.T) {
+		dueAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		task := createRecurring(t, "Invoice", "FREQ=MONTHLY", "UTC", dueAt)
+
+		// Changing the recurrence needs the future scope
+		resp := updateTask(t, task, models.TaskEditScopeThis, models.UpdateTaskPayload{Name: "Invoice", DueAt: &dueAt, RecurrenceRule: recurrence("FREQ=WEEKLY"), RecurrenceTimeZone: "UTC"})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		// Moving this occurrence keeps the series on its schedule
+		moved := dueAt.Add(24 * time.Hour)
+		resp = updateTask(t, task, models.TaskEditScopeThis, models.UpdateTaskPayload{Name: "Invoice ACME", DueAt: &moved, RecurrenceRule: recurrence("FREQ=MONTHLY"), RecurrenceTimeZone: "UTC"})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		next := app.finishTask(t, token, task).NextOccurrence
+		if assert.NotNil(t, next) {
+			assert.Equal(t, "Invoice", next.Name)
+			assert.True(t, dueAt.AddDate(0, 1, 0).Equal(*next.DueAt))
+		}
+	})
+
+	t.Run("Editing All Future Occurrences", func(t *testing.T) {
+		dueAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		task := createRecurring(t, "Backup", "FREQ=DAILY", "UTC", dueAt)
+		second := app.finishTask(t, token, task).NextOccurrence
+		if !assert.NotNil(t, second) {
+			return
+		}
+
+		resp := updateTask(t, *second, models.TaskEditScopeFuture, models.UpdateTaskPayload{Name: "Offsite backup", DueAt: second.DueAt, RecurrenceRule: recurrence("FREQ=WEEKLY"), RecurrenceTimeZone: "UTC"})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// The occurrence starts a new series
+		var updated models.Task
+		ParseResponse(t, resp, &updated)
+		assert.NotEqual(t, *second.SeriesID, *updated.SeriesID)
+		assert.Equal(t, "FREQ=WEEKLY", updated.Series.RecurrenceRule)
+
+		third := app.finishTask(t, token, updated).NextOccurrence
+		if assert.NotNil(t, third) {
+			assert.Equal(t, "Offsite backup", third.Name)
+			assert.True(t, second.DueAt.AddDate(0, 0, 7).Equal(*third.DueAt))
+		}
+
+		// The finished occurrence keeps its name
+		resp, err := app.MakeRequest(http.MethodGet, "/api/tasks/"+task.ID.String(), nil, token)
+		assert.NoError(t, err)
+		var first models.Task
+		ParseResponse(t, resp, &first)
+		assert.Equal(t, "Backup", first.Name)
+	})
+
+	t.Run("Renaming All Future Occurrences", func(t *testing.T) {
+		task := createRecurring(t, "Report", "FREQ=WEEKLY", "UTC", time.Now().Add(time.Hour).UTC())
+		resp := updateTask(t, task, models.TaskEditScopeFuture, models.UpdateTaskPayload{Name: "Weekly report", DueAt: task.DueAt, RecurrenceRule: recurrence("FREQ=WEEKLY")})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		next := app.finishTask(t, token, task).NextOccurrence
+		if assert.NotNil(t, next) {
+			assert.Equal(t, "Weekly report", next.Name)
+			assert.Equal(t, *task.SeriesID, *next.SeriesID)
+		}
+	})
+
+	t.Run("Omitted Rule Keeps The Recurrence", func(t *testing.T) {
+		task := createRecurring(t, "Standup", "FREQ=DAILY", "America/New_York", time.Now().Add(time.Hour).UTC())
+		resp := updateTask(t, task, models.TaskEditScopeFuture, models.UpdateTaskPayload{Name: "Daily standup", DueAt: task.DueAt})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var updated models.Task
+		ParseResponse(t, resp, &updated)
+		assert.Equal(t, *task.SeriesID, *updated.SeriesID)
+
+		next := app.finishTask(t, token, updated).NextOccurrence
+		if assert.NotNil(t, next) {
+			assert.Equal(t, "Daily standup", next.Name)
+			assert.Equal(t, "America/New_York", next.Series.TimeZone)
+		}
+	})
+
+	t.Run("Moving A Counted Series Keeps Its Count", func(t *testing.T) {
+		dueAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		task := createRecurring(t, "Course", "FREQ=DAILY;COUNT=3", "UTC", dueAt)
+		second := app.finishTask(t, token, task).NextOccurrence
+		if !assert.NotNil(t, second) {
+			return
+		}
+
+		moved := second.DueAt.Add(2 * time.Hour)
+		resp := updateTask(t, *second, models.TaskEditScopeFuture, models.UpdateTaskPayload{Name: "Course", DueAt: &moved})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var updated models.Task
+		ParseResponse(t, resp, &updated)
+		assert.Equal(t, "FREQ=DAILY;COUNT=2", updated.Series.RecurrenceRule)
+
+		third := app.finishTask(t, token, updated).NextOccurrence
+		if assert.NotNil(t, third) {
+			assert.True(t, moved.AddDate(0, 0, 1).Equal(*third.DueAt))
+			assert.Nil(t, app.finishTask(t, token, *third).NextOccurrence)
+		}
+	})
+
+	t.Run("Moving A Recurring Task Needs A Due Date", func(t *testing.T) {
+		task := createRecurring(t, "Review", "FREQ=WEEKLY", "UTC", time.Now().Add(time.Hour).UTC())
+		resp := updateTask(t, task, models.TaskEditScopeFuture, models.UpdateTaskPayload{Name: "Review"})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Stopping The Recurrence", func(t *testing.T) {
+		task := createRecurring(t, "Newsletter", "FREQ=WEEKLY", "UTC", time.Now().Add(time.Hour).UTC())
+		resp := updateTask(t, task, models.TaskEditScopeFuture, models.UpdateTaskPayload{Name: "Newsletter", DueAt: task.DueAt, RecurrenceRule: recurrence("")})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		finished := app.finishTask(t, token, task)
+		assert.Nil(t, finished.Series)
+		assert.Nil(t, finished.NextOccurrence)
+	})
+
+	t.Run("Invalid Scope", func(t *testing.T) {
+		task := app.createTask(t, token, "Single")
+		resp := updateTask(t, task, "everything", models.UpdateTaskPayload{Name: "Single"})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
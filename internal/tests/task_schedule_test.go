@@ -0,0 +1,298 @@
+package tests
+
+import (
+	"errors"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/notifier"
+	"fiber-gorm/internal/services"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createScheduledTask creates a task with a due date and returns it
+func (ta *TestApp) createScheduledTask(t *testing.T, token, name string, dueAt time.Time) models.Task {
+	resp, err := ta.MakeRequest(http.MethodPost, "/api/tasks", models.CreateTaskPayload{Name: name, DueAt: &dueAt}, token)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var task models.Task
+	ParseResponse(t, resp, &task)
+	return task
+}
+
+// listTasks returns the names of the tasks matched by the query string
+func (ta *TestApp) listTasks(t *testing.T, token, query string) []string {
+	resp, err := ta.MakeRequest(http.MethodGet, "/api/tasks?"+query, nil, token)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var tasks []models.Task
+	ParseResponse(t, resp, &tasks)
+	names := []string{}
+	for _, task := range tasks {
+		names = append(names, task.Name)
+	}
+	return names
+}
+
+func TestTaskScheduling(t *testing.T) {
+	app := SetupTestApp(t)
+	token := app.RegisterTestUser(t).Token.AccessToken
+
+	t.Run("Create With Schedule", func(t *testing.T) {
+		startAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		dueAt := startAt.Add(48 * time.Hour)
+		minutes := 90
+		resp, err := app.MakeRequest(http.MethodPost, "/api/tasks", models.CreateTaskPayload{
+			Name:             "Plan sprint",
+			Priority:         models.TaskPriorityHigh,
+			StartAt:          &startAt,
+			DueAt:            &dueAt,
+			EstimatedMinutes: &minutes,
+		}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var task models.Task
+		ParseResponse(t, resp, &task)
+		assert.Equal(t, models.TaskPriorityHigh, task.Priority)
+		assert.True(t, dueAt.Equal(*task.DueAt))
+		assert.Equal(t, 90, *task.EstimatedMinutes)
+	})
+
+	t.Run("Priority Defaults To Medium", func(t *testing.T) {
+		task := app.createTask(t, token, "Tidy desk")
+		assert.Equal(t, models.TaskPriorityMedium, task.Priority)
+	})
+
+	t.Run("Invalid Schedule Is Rejected", func(t *testing.T) {
+		dueAt := time.Now()
+		startAt := dueAt.Add(time.Hour)
+		minutes := 0
+		for _, payload := range []models.CreateTaskPayload{
+			{Name: "Backwards", StartAt: &startAt, DueAt: &dueAt},
+			{Name: "Bad priority", Priority: "whenever"},
+			{Name: "No effort", EstimatedMinutes: &minutes},
+		} {
+			resp, err := app.MakeRequest(http.MethodPost, "/api/tasks", payload, token)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, payload.Name)
+		}
+	})
+}
+
+func TestTaskDueFilters(t *testing.T) {
+	app := SetupTestApp(t)
+	token := app.RegisterTestUser(t).Token.AccessToken
+
+	now := time.Now().UTC()
+	weekStart := time.Date(now.Year(), now.Month(), now.Day()-(int(now.Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+
+	app.createScheduledTask(t, token, "Overdue", now.Add(-time.Minute))
+	finished := app.createScheduledTask(t, token, "Overdue but done", now.Add(-time.Minute))
+	app.transitionTask(t, token, finished, models.TaskStatusCancelled)
+	app.createScheduledTask(t, token, "Later this week", weekStart.Add(7*24*time.Hour-time.Minute))
+	app.createScheduledTask(t, token, "Next week", weekStart.Add(7*24*time.Hour+time.Minute))
+	app.createScheduledTask(t, token, "Last week", weekStart.Add(-time.Minute))
+	app.createTask(t, token, "No due date")
+
+	t.Run("Overdue", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{"Overdue", "Last week"}, app.listTasks(t, token, "due=overdue"))
+	})
+
+	t.Run("Due This Week", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{"Overdue", "Overdue but done", "Later this week"}, app.listTasks(t, token, "due=this_week"))
+	})
+
+	t.Run("Combined With Status", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{"Overdue but done"}, app.listTasks(t, token, "due=this_week&status=cancelled"))
+	})
+
+	t.Run("Invalid Filters Are Rejected", func(t *testing.T) {
+		for _, query := range []string{"due=someday", "tz=Mars/Olympus", "priority=whenever"} {
+			resp, err := app.MakeRequest(http.MethodGet, "/api/tasks?"+query, nil, token)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+		}
+	})
+}
+
+// failingNotifier fails every delivery
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(notifier.Reminder) error {
+	return errors.New("unavailable")
+}
+
+func TestTaskReminders(t *testing.T) {
+	app := SetupTestApp(t)
+	userResp := app.RegisterTestUser(t)
+	token := userResp.Token.AccessToken
+	now := time.Now()
+
+	soon := app.createScheduledTask(t, token, "Due soon", now.Add(30*time.Minute))
+	app.createScheduledTask(t, token, "Due tomorrow", now.Add(24*time.Hour))
+	done := app.createScheduledTask(t, token, "Done already", now.Add(10*time.Minute))
+	app.transitionTask(t, token, done, models.TaskStatusCancelled)
+
+	t.Run("Reminds Of Tasks Due Soon", func(t *testing.T) {
+		sent, err := app.Reminders.SendDue(now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+
+		reminders := app.Notifier.Sent()
+		if assert.Len(t, reminders, 1) {
+			assert.Equal(t, soon.ID, reminders[0].TaskID)
+			assert.Equal(t, userResp.User.Email, reminders[0].Email)
+		}
+	})
+
+	t.Run("Reminds Only Once Across Instances", func(t *testing.T) {
+		other := *app.Reminders
+		other.Notifier = notifier.NewLogNotifier()
+
+		sent, err := app.Reminders.SendDue(now)
+		assert.NoError(t, err)
+		assert.Zero(t, sent)
+		sent, err = other.SendDue(now)
+		assert.NoError(t, err)
+		assert.Zero(t, sent)
+	})
+
+	t.Run("New Due Date Gets A New Reminder", func(t *testing.T) {
+		dueAt := now.Add(45 * time.Minute)
+		resp, err := app.MakeRequest(http.MethodPut, "/api/tasks/"+soon.ID.String(), models.UpdateTaskPayload{
+			Name:  soon.Name,
+			DueAt: &dueAt,
+		}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		sent, err := app.Reminders.SendDue(now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+	})
+
+	t.Run("Failed Delivery Is Retried After A Delay", func(t *testing.T) {
+		later := now.Add(23*time.Hour + 30*time.Minute)
+		failing := *app.Reminders
+		failing.Notifier = failingNotifier{}
+
+		sent, err := failing.SendDue(later)
+		assert.NoError(t, err)
+		assert.Zero(t, sent)
+
+		sent, err = app.Reminders.SendDue(later)
+		assert.NoError(t, err)
+		assert.Zero(t, sent)
+
+		sent, err = app.Reminders.SendDue(later.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+	})
+
+	t.Run("Overdue Tasks Are Not Reminded", func(t *testing.T) {
+		overdue := app.createScheduledTask(t, token, "Overdue", now.Add(-time.Hour))
+
+		sent, err := app.Reminders.SendDue(now)
+		assert.NoError(t, err)
+		assert.Zero(t, sent)
+
+		// Retries that run past the due date are dropped too
+		failing := *app.Reminders
+		failing.Notifier = failingNotifier{}
+		late := app.createScheduledTask(t, token, "Due in a moment", now.Add(30*time.Second))
+		sent, err = failing.SendDue(now)
+		assert.NoError(t, err)
+		assert.Zero(t, sent)
+
+		sent, err = app.Reminders.SendDue(now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Zero(t, sent)
+
+		for _, task := range []models.Task{overdue, late} {
+			var stored models.Task
+			assert.NoError(t, app.DB.First(&stored, "id = ?", task.ID).Error)
+			assert.Nil(t, stored.ReminderSentAt)
+		}
+	})
+}
+
+func TestTaskReminderRetries(t *testing.T) {
+	app := SetupTestApp(t)
+	token := app.RegisterTestUser(t).Token.AccessToken
+	now := time.Now()
+	task := app.createScheduledTask(t, token, "Due soon", now.Add(30*time.Minute))
+
+	failing := *app.Reminders
+	failing.Notifier = failingNotifier{}
+
+	t.Run("Failing Reminder Is Given Up", func(t *testing.T) {
+		// Keep the task due soon, so only the attempts limit the retries
+		dueSoon := func(at time.Time) {
+			assert.NoError(t, app.DB.Model(&models.Task{}).Where("id = ?", task.ID).
+				UpdateColumn("due_at", at.Add(30*time.Minute)).Error)
+		}
+
+		at := now
+		for i := 0; i < services.MaxReminderAttempts; i++ {
+			dueSoon(at)
+			sent, err := failing.SendDue(at)
+			assert.NoError(t, err)
+			assert.Zero(t, sent)
+			at = at.Add(24 * time.Hour)
+		}
+
+		var stored models.Task
+		assert.NoError(t, app.DB.First(&stored, "id = ?", task.ID).Error)
+		assert.Equal(t, services.MaxReminderAttempts, stored.ReminderAttempts)
+
+		dueSoon(at)
+		sent, err := app.Reminders.SendDue(at)
+		assert.NoError(t, err)
+		assert.Zero(t, sent)
+	})
+
+	t.Run("Does Not Crowd Out Other Reminders", func(t *testing.T) {
+		app.createScheduledTask(t, token, "Due later", now.Add(40*time.Minute))
+
+		sent, err := app.Reminders.SendDue(now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+	})
+
+	t.Run("New Due Date Restarts The Attempts", func(t *testing.T) {
+		dueAt := now.Add(50 * time.Minute)
+		resp, err := app.MakeRequest(http.MethodPut, "/api/tasks/"+task.ID.String(), models.UpdateTaskPayload{
+			Name:  task.Name,
+			DueAt: &dueAt,
+		}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		sent, err := app.Reminders.SendDue(now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+	})
+}
+
+func TestTaskReminderNotifiers(t *testing.T) {
+	app := SetupTestApp(t)
+	email := &notifier.EmailNotifier{Mailer: app.Mailer, BaseURL: "http://localhost:3000"}
+	reminders := &services.TaskReminderScheduler{Repo: app.Reminders.Repo, UserRepo: app.UserRepo, Notifier: email, Before: time.Hour}
+
+	userResp := app.RegisterTestUser(t)
+	app.createScheduledTask(t, userResp.Token.AccessToken, "Send invoice", time.Now().Add(time.Minute))
+
+	sent, err := reminders.SendDue(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	msg, ok := app.Mailer.Last(userResp.User.Email)
+	if assert.True(t, ok) {
+		assert.Contains(t, msg.Subject, "Send invoice")
+	}
+}
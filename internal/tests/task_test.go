@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/services"
+	"fiber-gorm/pkg/svcauth"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// createTask creates a task with the given token and returns it
+func (ta *TestApp) createTask(t *testing.T, token, name string) models.Task {
+	resp, err := ta.MakeRequest(http.MethodPost, "/api/tasks", models.CreateTaskPayload{Name: name}, token)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var task models.Task
+	ParseResponse(t, resp, &task)
+	return task
+}
+
+func TestTasks(t *testing.T) {
+	app := SetupTestApp(t)
+	token := app.RegisterTestUser(t).Token.AccessToken
+
+	var task models.Task
+
+	t.Run("Requires Authentication", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/tasks", nil, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		task = app.createTask(t, token, "Write report")
+		assert.NotEqual(t, uuid.Nil, task.ID)
+		assert.Equal(t, "Write report", task.Name)
+		assert.Equal(t, models.TaskStatusTodo, task.Status)
+		assert.Nil(t, task.FinishedAt)
+	})
+
+	t.Run("Create Validates The Payload", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/tasks", models.CreateTaskPayload{}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		app.createTask(t, token, "Review report")
+
+		resp, err := app.MakeRequest(http.MethodGet, "/api/tasks", nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var tasks []models.Task
+		ParseResponse(t, resp, &tasks)
+		if assert.Len(t, tasks, 2) {
+			assert.Equal(t, task.ID, tasks[0].ID)
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/tasks/"+task.ID.String(), nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var fetched models.Task
+		ParseResponse(t, resp, &fetched)
+		assert.Equal(t, task.Name, fetched.Name)
+
+		for _, id := range []string{uuid.NewString(), "not-a-uuid"} {
+			resp, err = app.MakeRequest(http.MethodGet, "/api/tasks/"+id, nil, token)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode, id)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPut, "/api/tasks/"+task.ID.String(), models.UpdateTaskPayload{
+			Name: "Write final report",
+		}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var updated models.Task
+		ParseResponse(t, resp, &updated)
+		assert.Equal(t, "Write final report", updated.Name)
+	})
+
+	t.Run("Update Cannot Bypass The Workflow", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPut, "/api/tasks/"+task.ID.String(), map[string]interface{}{
+			"name":        "Write final report",
+			"status":      models.TaskStatusDone,
+			"finished_at": time.Now(),
+		}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var updated models.Task
+		ParseResponse(t, resp, &updated)
+		assert.Equal(t, models.TaskStatusTodo, updated.Status)
+		assert.Nil(t, updated.FinishedAt)
+	})
+
+	t.Run("Update Validates The Payload", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPut, "/api/tasks/"+task.ID.String(), models.UpdateTaskPayload{}, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodDelete, "/api/tasks/"+task.ID.String(), nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodGet, "/api/tasks/"+task.ID.String(), nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodDelete, "/api/tasks/"+task.ID.String(), nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestTaskPermissions(t *testing.T) {
+	app := SetupTestApp(t)
+	token := app.RegisterTestUser(t).Token.AccessToken
+
+	// A read-only API key can list but not create tasks
+	resp, err := app.MakeRequest(http.MethodPost, "/api/me/tokens", models.CreateAPIKeyPayload{
+		Name:   "reader",
+		Scopes: []string{models.PermissionTasksRead},
+	}, token)
+	assert.NoError(t, err)
+	var created createAPIKeyResponse
+	ParseResponse(t, resp, &created)
+
+	resp, err = app.MakeRequest(http.MethodGet, "/api/tasks", nil, created.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = app.MakeRequest(http.MethodPost, "/api/tasks", models.CreateTaskPayload{Name: "Nope"}, created.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestTaskOwnership(t *testing.T) {
+	app := SetupTestApp(t)
+	alice := app.RegisterTestUser(t)
+	bob := app.RegisterTestUser(t)
+
+	aliceTask := app.createTask(t, alice.Token.AccessToken, "Alice's task")
+	bobTask := app.createTask(t, bob.Token.AccessToken, "Bob's task")
+	assert.Equal(t, alice.User.ID, aliceTask.UserID)
+
+	t.Run("List Only Returns Own Tasks", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/tasks", nil, alice.Token.AccessToken)
+		assert.NoError(t, err)
+
+		var tasks []models.Task
+		ParseResponse(t, resp, &tasks)
+		if assert.Len(t, tasks, 1) {
+			assert.Equal(t, aliceTask.ID, tasks[0].ID)
+		}
+	})
+
+	t.Run("Other Users' Tasks Are Not Found", func(t *testing.T) {
+		path := "/api/tasks/" + bobTask.ID.String()
+
+		resp, err := app.MakeRequest(http.MethodGet, path, nil, alice.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodPut, path, models.UpdateTaskPayload{Name: "Taken over"}, alice.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, err = app.MakeRequest(http.MethodDelete, path, nil, alice.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		// Bob's task is unchanged
+		resp, err = app.MakeRequest(http.MethodGet, path, nil, bob.Token.AccessToken)
+		assert.NoError(t, err)
+		var task models.Task
+		ParseResponse(t, resp, &task)
+		assert.Equal(t, "Bob's task", task.Name)
+	})
+
+	t.Run("Deleting A User Deletes Their Tasks", func(t *testing.T) {
+		user, err := app.UserSvc.FindUserById(alice.User.ID.String())
+		assert.NoError(t, err)
+		assert.NoError(t, app.UserSvc.DeleteUser(user))
+
+		var count int64
+		app.DB.Model(&models.Task{}).Where("user_id = ?", alice.User.ID).Count(&count)
+		assert.Zero(t, count)
+
+		app.DB.Model(&models.Task{}).Where("user_id = ?", bob.User.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+}
+
+func TestTaskPrincipals(t *testing.T) {
+	app := SetupTestApp(t)
+
+	t.Run("Client Credentials Cannot Own Tasks", func(t *testing.T) {
+		adminEmail := randomEmail()
+		admin, err := app.AuthSvc.EnsureAdmin("Admin User", adminEmail, "Sup3rSecret!Pass")
+		assert.NoError(t, err)
+		app.EnableTestMFA(t, admin.ID)
+		adminResp := app.LoginTestUserMFA(t, adminEmail, "Sup3rSecret!Pass")
+
+		resp, err := app.MakeRequest(http.MethodPost, "/api/admin/oauth/clients", models.CreateOAuthClientPayload{
+			Name:       "Sync Service",
+			GrantTypes: []string{models.GrantTypeClientCredentials},
+			Scopes:     []string{models.PermissionTasksRead, models.PermissionTasksWrite},
+		}, adminResp.Token.AccessToken)
+		assert.NoError(t, err)
+		var created struct {
+			Client       models.OAuthClient `json:"client"`
+			ClientSecret string             `json:"client_secret"`
+		}
+		ParseResponse(t, resp, &created)
+
+		resp = app.postForm(t, "/api/oauth/token", url.Values{"grant_type": {models.GrantTypeClientCredentials}},
+			created.Client.ID.String(), created.ClientSecret)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var tokens services.OAuthTokenResponse
+		ParseResponse(t, resp, &tokens)
+
+		resp, err = app.MakeRequest(http.MethodPost, "/api/tasks", models.CreateTaskPayload{Name: "Orphan"}, tokens.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Signed Services Cannot Reach Tasks", func(t *testing.T) {
+		signer := &svcauth.Signer{KeyID: "billing", Secret: []byte("billing-secret")}
+		resp, err := app.App.Test(signedRequest(t, signer, http.MethodGet, "/api/tasks", ""))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"fiber-gorm/internal/config"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/services"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// transitionTask moves a task to another status and returns the response
+func (ta *TestApp) transitionTask(t *testing.T, token string, task models.Task, status string) *http.Response {
+	resp, err := ta.MakeRequest(http.MethodPost, "/api/tasks/"+task.ID.String()+"/transitions", models.TransitionTaskPayload{
+		Status: status,
+	}, token)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestTaskWorkflow(t *testing.T) {
+	app := SetupTestApp(t)
+	userResp := app.RegisterTestUser(t)
+	token := userResp.Token.AccessToken
+	task := app.createTask(t, token, "Ship feature")
+
+	t.Run("Illegal Transition Is Rejected", func(t *testing.T) {
+		resp := app.transitionTask(t, token, task, models.TaskStatusDone)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		var body map[string]interface{}
+		ParseResponse(t, resp, &body)
+		assert.Contains(t, body["error"], "Cannot move task from todo to done")
+	})
+
+	t.Run("Unknown Status Is Rejected", func(t *testing.T) {
+		resp := app.transitionTask(t, token, task, "shipped")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Task Moves Through The Workflow", func(t *testing.T) {
+		for _, status := range []string{models.TaskStatusInProgress, models.TaskStatusReview} {
+			resp := app.transitionTask(t, token, task, status)
+			assert.Equal(t, http.StatusOK, resp.StatusCode, status)
+
+			var moved models.Task
+			ParseResponse(t, resp, &moved)
+			assert.Equal(t, status, moved.Status)
+			assert.Nil(t, moved.FinishedAt)
+		}
+	})
+
+	t.Run("Terminal Status Stamps FinishedAt", func(t *testing.T) {
+		resp := app.transitionTask(t, token, task, models.TaskStatusDone)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var done models.Task
+		ParseResponse(t, resp, &done)
+		assert.Equal(t, models.TaskStatusDone, done.Status)
+		assert.NotNil(t, done.FinishedAt)
+	})
+
+	t.Run("Reopening Clears FinishedAt", func(t *testing.T) {
+		resp := app.transitionTask(t, token, task, models.TaskStatusTodo)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var reopened models.Task
+		ParseResponse(t, resp, &reopened)
+		assert.Nil(t, reopened.FinishedAt)
+	})
+
+	t.Run("History Records Every Transition", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/tasks/"+task.ID.String()+"/transitions", nil, token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var history []models.TaskTransition
+		ParseResponse(t, resp, &history)
+		if assert.Len(t, history, 4) {
+			assert.Equal(t, models.TaskStatusTodo, history[0].FromStatus)
+			assert.Equal(t, models.TaskStatusInProgress, history[0].ToStatus)
+			assert.Equal(t, models.TaskStatusDone, history[3].FromStatus)
+			assert.Equal(t, models.TaskStatusTodo, history[3].ToStatus)
+			assert.Equal(t, userResp.User.ID, history[0].ActorID)
+		}
+	})
+
+	t.Run("Other Users Cannot Transition The Task", func(t *testing.T) {
+		other := app.RegisterTestUser(t)
+		resp := app.transitionTask(t, other.Token.AccessToken, task, models.TaskStatusInProgress)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, err := app.MakeRequest(http.MethodGet, "/api/tasks/"+task.ID.String()+"/transitions", nil, other.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Stale Status Is A Conflict", func(t *testing.T) {
+		stale, err := app.TaskSvc.GetTask(userResp.User.ID.String(), task.ID.String())
+		assert.NoError(t, err)
+
+		resp := app.transitionTask(t, token, task, models.TaskStatusBlocked)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// The stale copy still says todo, the conditional update must not apply
+		moved, err := app.TaskSvc.Repo.TransitionTask(stale, &models.TaskTransition{
+			TaskID:     stale.ID,
+			FromStatus: stale.Status,
+			ToStatus:   models.TaskStatusInProgress,
+			ActorID:    stale.UserID,
+		}, nil)
+		assert.NoError(t, err)
+		assert.False(t, moved)
+	})
+}
+
+func TestTaskWorkflowConfig(t *testing.T) {
+	workflow, err := services.NewTaskWorkflow(config.Config{TaskTransitions: "todo>in_progress, in_progress>done"})
+	assert.NoError(t, err)
+	assert.NoError(t, workflow.Check(models.TaskStatusTodo, models.TaskStatusInProgress))
+	assert.Error(t, workflow.Check(models.TaskStatusInProgress, models.TaskStatusReview))
+
+	for _, spec := range []string{"todo>shipped", "todo", "todo>todo"} {
+		_, err := services.NewTaskWorkflow(config.Config{TaskTransitions: spec})
+		assert.Error(t, err, spec)
+	}
+}
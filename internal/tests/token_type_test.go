@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenTypeSeparation(t *testing.T) {
+	app := SetupTestApp(t)
+	authResp := app.RegisterTestUser(t)
+
+	t.Run("Refresh Token Cannot Access API", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodGet, "/api/me", nil, authResp.Token.RefreshToken)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Access Token Cannot Refresh", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/refresh", map[string]string{
+			"refresh_token": authResp.Token.AccessToken,
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Access Token Carries Typed Claims", func(t *testing.T) {
+		claims, err := app.AuthSvc.ValidateAccessToken(authResp.Token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "access", claims.Type)
+		assert.Equal(t, authResp.User.Email, claims.Email)
+		assert.NotEmpty(t, claims.ID)
+	})
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/oidc"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteUser(t *testing.T) {
+	app := SetupTestApp(t)
+	alice := app.RegisterTestUser(t)
+	bob := app.RegisterTestUser(t)
+	token := alice.Token.AccessToken
+
+	resp, err := app.MakeRequest(http.MethodPost, "/api/me/tokens", models.CreateAPIKeyPayload{
+		Name:   "CI",
+		Scopes: []string{models.PermissionTasksRead},
+	}, token)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	app.EnableTestMFA(t, alice.User.ID)
+	assert.NoError(t, app.DB.Create(&models.Identity{UserID: alice.User.ID, Provider: "stub", Subject: "alice"}).Error)
+	assert.NoError(t, app.DB.Create(&models.OIDCState{
+		StateHash:    "pending-link",
+		Provider:     "stub",
+		Nonce:        "nonce",
+		CodeVerifier: "verifier",
+		LinkUserID:   &alice.User.ID,
+		ExpiresAt:    time.Now().Add(time.Minute),
+	}).Error)
+
+	client, _ := app.createOAuthClient(t, models.CreateOAuthClientPayload{
+		Name:         "Partner App",
+		RedirectURIs: []string{testRedirectURI},
+		GrantTypes:   []string{models.GrantTypeAuthorizationCode},
+		Scopes:       []string{models.PermissionTasksRead},
+	})
+	verifier, err := oidc.RandomString()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, app.authorizeCode(t, client.ID.String(), token, models.PermissionTasksRead, verifier))
+
+	owned := map[string]interface{}{
+		"refresh tokens":      &models.RefreshToken{},
+		"sessions":            &models.Session{},
+		"API keys":            &models.APIKey{},
+		"identities":          &models.Identity{},
+		"recovery codes":      &models.RecoveryCode{},
+		"password history":    &models.PasswordHistory{},
+		"one-time tokens":     &models.OneTimeToken{},
+		"OAuth consents":      &models.OAuthConsent{},
+		"authorization codes": &models.OAuthAuthorizationCode{},
+	}
+	count := func(model interface{}, query string, userID interface{}) int64 {
+		var n int64
+		assert.NoError(t, app.DB.Model(model).Where(query, userID).Count(&n).Error)
+		return n
+	}
+	for name, model := range owned {
+		assert.NotZero(t, count(model, "user_id = ?", alice.User.ID), name)
+	}
+
+	user, err := app.UserSvc.FindUserById(alice.User.ID.String())
+	assert.NoError(t, err)
+	assert.NoError(t, app.UserSvc.DeleteUser(user))
+
+	t.Run("Nothing Of The User Is Left", func(t *testing.T) {
+		for name, model := range owned {
+			assert.Zero(t, count(model, "user_id = ?", alice.User.ID), name)
+		}
+		assert.Zero(t, count(&models.OIDCState{}, "link_user_id = ?", alice.User.ID))
+	})
+
+	t.Run("Other Users Keep Theirs", func(t *testing.T) {
+		assert.NotZero(t, count(&models.RefreshToken{}, "user_id = ?", bob.User.ID))
+		assert.NotZero(t, count(&models.Session{}, "user_id = ?", bob.User.ID))
+	})
+
+	t.Run("Refresh Token Stops Working", func(t *testing.T) {
+		resp, err := app.MakeRequest(http.MethodPost, "/api/auth/refresh", map[string]string{
+			"refresh_token": alice.Token.RefreshToken,
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
@@ -2,38 +2,107 @@ package validators
 
 import (
 	"errors"
+	"fiber-gorm/internal/config"
 	"fiber-gorm/internal/models"
-	"regexp"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// ValidateLogin only checks the shape of the payload. Password rules apply
+// when a password is chosen, so changing them never locks out existing users.
 func ValidateLogin(payload *models.LoginUserPayload) error {
-	if err := Validate(payload); err != nil {
-		return err
+	return Validate(payload)
+}
+
+// PasswordPolicy holds the rules a new password must satisfy
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// DisallowPersonalInfo rejects passwords containing the user's email or name
+	DisallowPersonalInfo bool
+}
+
+// NewPasswordPolicy reads the password rules from configuration
+func NewPasswordPolicy(cfg config.Config) PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:            cfg.PasswordMinLength,
+		MaxLength:            cfg.PasswordMaxLength,
+		RequireUpper:         cfg.PasswordRequireUpper,
+		RequireLower:         cfg.PasswordRequireLower,
+		RequireDigit:         cfg.PasswordRequireDigit,
+		RequireSymbol:        cfg.PasswordRequireSymbol,
+		DisallowPersonalInfo: cfg.PasswordDisallowPersonalInfo,
 	}
+}
 
-	if err := ValidatePassword(payload.Password); err != nil {
-		return err
+// ValidatePassword checks a new password against the policy. email and name
+// belong to the account the password is for and may be empty.
+func (p PasswordPolicy) ValidatePassword(password, email, name string) error {
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("password must be at most %d characters long", p.MaxLength)
 	}
 
-	return nil
-}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
 
-// validatePassword checks if password meets security requirements
-func ValidatePassword(password string) error {
-	// Check for at least one uppercase letter
-	if !regexp.MustCompile(`[A-Z]`).MatchString(password) {
+	if p.RequireUpper && !upper {
 		return errors.New("password must contain at least one uppercase letter")
 	}
-
-	// Check for at least one digit
-	if !regexp.MustCompile(`[0-9]`).MatchString(password) {
+	if p.RequireLower && !lower {
+		return errors.New("password must contain at least one lowercase letter")
+	}
+	if p.RequireDigit && !digit {
 		return errors.New("password must contain at least one digit")
 	}
-
-	// Check for at least one special character
-	if !regexp.MustCompile(`[!@#$%^&*(),.?":{}|<>]`).MatchString(password) {
+	if p.RequireSymbol && !symbol {
 		return errors.New("password must contain at least one special character")
 	}
 
+	if p.DisallowPersonalInfo && containsPersonalInfo(password, email, name) {
+		return errors.New("password must not contain your email address or name")
+	}
+
 	return nil
 }
+
+// containsPersonalInfo reports whether the password contains the local part
+// of the email address, a word of it or a word of the name. Fragments shorter
+// than three characters are ignored since they appear in passwords by chance.
+func containsPersonalInfo(password, email, name string) bool {
+	password = strings.ToLower(password)
+	isSeparator := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }
+
+	fragments := strings.FieldsFunc(strings.ToLower(name), isSeparator)
+	if local, _, ok := strings.Cut(strings.ToLower(email), "@"); ok {
+		fragments = append(fragments, local)
+		fragments = append(fragments, strings.FieldsFunc(local, isSeparator)...)
+	}
+
+	for _, fragment := range fragments {
+		if utf8.RuneCountInString(fragment) >= 3 && strings.Contains(password, fragment) {
+			return true
+		}
+	}
+	return false
+}
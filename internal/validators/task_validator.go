@@ -0,0 +1,76 @@
+package validators
+
+import (
+	"errors"
+	"fiber-gorm/internal/models"
+	"fiber-gorm/internal/rrule"
+	"fmt"
+	"time"
+)
+
+// ValidateTaskCreation performs custom validations beyond the struct tags
+func ValidateTaskCreation(payload *models.CreateTaskPayload) error {
+	if err := Validate(payload); err != nil {
+		return err
+	}
+
+	if err := validateTaskSchedule(payload.StartAt, payload.DueAt); err != nil {
+		return err
+	}
+
+	return validateRecurrence(payload.RecurrenceRule, payload.RecurrenceTimeZone, payload.DueAt)
+}
+
+// ValidateTaskUpdate performs custom validations beyond the struct tags
+func ValidateTaskUpdate(payload *models.UpdateTaskPayload) error {
+	if err := Validate(payload); err != nil {
+		return err
+	}
+
+	if err := validateTaskSchedule(payload.StartAt, payload.DueAt); err != nil {
+		return err
+	}
+
+	if payload.RecurrenceRule == nil {
+		return nil
+	}
+	return validateRecurrence(*payload.RecurrenceRule, payload.RecurrenceTimeZone, payload.DueAt)
+}
+
+// validateTaskSchedule checks that a task does not start after it is due
+func validateTaskSchedule(startAt, dueAt *time.Time) error {
+	if startAt != nil && dueAt != nil && startAt.After(*dueAt) {
+		return errors.New("start_at must not be after due_at")
+	}
+
+	return nil
+}
+
+// validateRecurrence checks that a recurrence rule parses, has a due date
+// to start from and repeats at least once after it
+func validateRecurrence(rule, tz string, dueAt *time.Time) error {
+	if rule == "" {
+		return nil
+	}
+
+	parsed, err := rrule.Parse(rule)
+	if err != nil {
+		return fmt.Errorf("recurrence_rule: %w", err)
+	}
+	if dueAt == nil {
+		return errors.New("recurrence_rule needs a due_at")
+	}
+
+	if tz == "" {
+		tz = "UTC"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return fmt.Errorf("recurrence_tz: %w", err)
+	}
+	if _, ok := parsed.Next(*dueAt, *dueAt, loc); !ok {
+		return errors.New("recurrence_rule has no occurrence after due_at")
+	}
+
+	return nil
+}
@@ -10,14 +10,14 @@ import (
 )
 
 // ValidateUserCreation performs custom validations beyond the struct tags
-func ValidateUserCreation(payload *models.CreateUserPayload) error {
+func ValidateUserCreation(payload *models.CreateUserPayload, policy PasswordPolicy) error {
 	// Basic validation using struct tags
 	if err := Validate(payload); err != nil {
 		return err
 	}
 
 	// Custom validations
-	if err := ValidatePassword(payload.Password); err != nil {
+	if err := policy.ValidatePassword(payload.Password, payload.Email, payload.Name); err != nil {
 		return err
 	}
 